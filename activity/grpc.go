@@ -1,11 +1,17 @@
 package activity
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"sync"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
 
 	"github.com/project-flogo/core/activity"
 	"github.com/project-flogo/core/data/metadata"
@@ -18,9 +24,11 @@ func init() {
 	activity.Register(&Activity{}, New)
 }
 
+// mashGRPCClienConn is a pooled connection, ready is closed once its dial ends
 type mashGRPCClienConn struct {
 	conn  *grpc.ClientConn
-	count int
+	err   error
+	ready chan struct{}
 }
 
 type mashGRPCClinetConns struct {
@@ -28,13 +36,19 @@ type mashGRPCClinetConns struct {
 	sync.Mutex
 }
 
+// reconnectWait bounds the wait for a pooled connection in failure to start reconnecting
+const reconnectWait = time.Second
+
 var conns = mashGRPCClinetConns{
 	connMap: make(map[string]*mashGRPCClienConn),
 }
 
 // Activity is a GRPC activity
 type Activity struct {
-	settings *Settings
+	settings    *Settings
+	dialOptions []grpc.DialOption
+	// poolKey identifies the pooled connections dialed with the same options
+	poolKey string
 }
 
 // New creates a new javascript activity
@@ -48,13 +62,87 @@ func New(ctx activity.InitContext) (activity.Activity, error) {
 	logger := ctx.Logger()
 	logger.Debugf("Setting: %b", settings)
 
+	dialOptions, err := newDialOptions(&settings)
+	if err != nil {
+		return nil, err
+	}
+
 	act := Activity{
-		settings: &settings,
+		settings:    &settings,
+		dialOptions: dialOptions,
+		poolKey: fmt.Sprintf("%s|%t|%s|%d|%d|%t|%d|%d|%s|%t|%s|%s|%d", settings.HostURL, settings.EnableTLS, settings.ClientCert,
+			settings.KeepaliveTime, settings.KeepaliveTimeout, settings.KeepalivePermitWithoutStream, settings.MaxCallSendMsgSize,
+			settings.MaxCallRecvMsgSize, settings.Compression, settings.WaitForReady, settings.UserAgent, settings.Authority,
+			settings.ConnectTimeout),
 	}
 
 	return &act, nil
 }
 
+// newDialOptions returns the dial options of the connections of the activity
+func newDialOptions(settings *Settings) ([]grpc.DialOption, error) {
+	for name, value := range map[string]int{
+		"keepaliveTime":      settings.KeepaliveTime,
+		"keepaliveTimeout":   settings.KeepaliveTimeout,
+		"maxCallSendMsgSize": settings.MaxCallSendMsgSize,
+		"maxCallRecvMsgSize": settings.MaxCallRecvMsgSize,
+		"connectTimeout":     settings.ConnectTimeout,
+	} {
+		if value < 0 {
+			return nil, fmt.Errorf("Invalid %s [%d]", name, value)
+		}
+	}
+
+	opts := []grpc.DialOption{grpc.WithInsecure()}
+	if settings.EnableTLS {
+		creds, err := credentials.NewClientTLSFromFile(settings.ClientCert, "")
+		if err != nil {
+			return nil, err
+		}
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	}
+
+	if settings.KeepaliveTime != 0 || settings.KeepaliveTimeout != 0 || settings.KeepalivePermitWithoutStream {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                time.Duration(settings.KeepaliveTime) * time.Millisecond,
+			Timeout:             time.Duration(settings.KeepaliveTimeout) * time.Millisecond,
+			PermitWithoutStream: settings.KeepalivePermitWithoutStream,
+		}))
+	}
+
+	var callOpts []grpc.CallOption
+	if settings.MaxCallSendMsgSize != 0 {
+		callOpts = append(callOpts, grpc.MaxCallSendMsgSize(settings.MaxCallSendMsgSize))
+	}
+	if settings.MaxCallRecvMsgSize != 0 {
+		callOpts = append(callOpts, grpc.MaxCallRecvMsgSize(settings.MaxCallRecvMsgSize))
+	}
+	switch settings.Compression {
+	case "", "none":
+	case gzip.Name:
+		callOpts = append(callOpts, grpc.UseCompressor(gzip.Name))
+	default:
+		return nil, fmt.Errorf("Unsupported compression [%s]", settings.Compression)
+	}
+	if settings.WaitForReady {
+		callOpts = append(callOpts, grpc.WaitForReady(true))
+	}
+	if len(callOpts) > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(callOpts...))
+	}
+
+	if settings.UserAgent != "" {
+		opts = append(opts, grpc.WithUserAgent(settings.UserAgent))
+	}
+	if settings.Authority != "" {
+		opts = append(opts, grpc.WithAuthority(settings.Authority))
+	}
+	if settings.ConnectTimeout != 0 {
+		opts = append(opts, grpc.WithBlock())
+	}
+	return opts, nil
+}
+
 // Metadata return the metadata for the activity
 func (a *Activity) Metadata() *activity.Metadata {
 	return activityMetadata
@@ -70,26 +158,11 @@ func (a *Activity) Eval(ctx activity.Context) (done bool, err error) {
 		return false, err
 	}
 
-	opts := []grpc.DialOption{}
 	logger.Debug("enableTLS: ", a.settings.EnableTLS)
-	if a.settings.EnableTLS {
-		logger.Debug("ClientCert: ", a.settings.ClientCert)
-		creds, err := credentials.NewClientTLSFromFile(a.settings.ClientCert, "")
-		if err != nil {
-			logger.Error(err)
-		}
-
-		opts = []grpc.DialOption{grpc.WithTransportCredentials(creds)}
-
-	} else {
-		opts = []grpc.DialOption{grpc.WithInsecure()}
-	}
-
-	conn, err := getConnection(a.settings.HostURL, logger, opts)
+	conn, err := getConnection(a.poolKey, a.settings.HostURL, logger, a.dialOptions, time.Duration(a.settings.ConnectTimeout)*time.Millisecond)
 	if err != nil {
 		return false, err
 	}
-	defer releaseConnection(a.settings.HostURL)
 
 	logger.Debug("operating mode: ", a.settings.OperatingMode)
 
@@ -121,35 +194,52 @@ func (a *Activity) Eval(ctx activity.Context) (done bool, err error) {
 	return false, errors.New("Invalid use of service , OperatingMode not recognised")
 }
 
-// getconnection returns single client connection object per hostaddress
-func getConnection(hostAdds string, logger log.Logger, opts []grpc.DialOption) (*grpc.ClientConn, error) {
+// getconnection returns single client connection object per host address and dial options, a connect timeout makes
+// the dial block until the connection is ready. The connection is dialed outside the lock of the pool by the first
+// call, the concurrent calls for the same key wait for its dial and the calls for other keys are not held up. The
+// connections stay open for the next calls, gRPC reconnects them when they break, and a failed dial is retried by
+// the next call
+func getConnection(key, hostAdds string, logger log.Logger, opts []grpc.DialOption, connectTimeout time.Duration) (*grpc.ClientConn, error) {
 	conns.Lock()
-	defer conns.Unlock()
-	conn := conns.connMap[hostAdds]
-	if conn == nil {
-		c, err := grpc.Dial(hostAdds, opts...)
-		if err != nil {
-			logger.Error(err)
-			return nil, err
-		}
-		conn = &mashGRPCClienConn{
-			conn:  c,
-			count: 0,
+	conn, ok := conns.connMap[key]
+	if !ok {
+		conn = &mashGRPCClienConn{ready: make(chan struct{})}
+		conns.connMap[key] = conn
+	}
+	conns.Unlock()
+	if ok {
+		<-conn.ready
+		if conn.err == nil {
+			reconnect(conn.conn)
 		}
-		conns.connMap[hostAdds] = conn
+		return conn.conn, conn.err
+	}
+
+	ctx := context.Background()
+	if connectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, connectTimeout)
+		defer cancel()
 	}
-	conn.count++
-	return conn.conn, nil
+	conn.conn, conn.err = grpc.DialContext(ctx, hostAdds, opts...)
+	if conn.err != nil {
+		logger.Error(conn.err)
+		conns.Lock()
+		delete(conns.connMap, key)
+		conns.Unlock()
+	}
+	close(conn.ready)
+	return conn.conn, conn.err
 }
 
-// releaseConnection closes created client connection per hostaddress
-func releaseConnection(hostAdds string) {
-	conns.Lock()
-	defer conns.Unlock()
-	conn := conns.connMap[hostAdds]
-	conn.count--
-	if conn.count <= 0 {
-		conn.conn.Close()
-		delete(conns.connMap, hostAdds)
+// reconnect makes a pooled connection waiting to reconnect after a failure, such as a restart of the server, try
+// again at once instead of failing the calls fast until its backoff expires
+func reconnect(conn *grpc.ClientConn) {
+	if conn.GetState() != connectivity.TransientFailure {
+		return
 	}
+	conn.ResetConnectBackoff()
+	ctx, cancel := context.WithTimeout(context.Background(), reconnectWait)
+	defer cancel()
+	conn.WaitForStateChange(ctx, connectivity.TransientFailure)
 }
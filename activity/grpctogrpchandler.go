@@ -60,9 +60,24 @@ func (a *Activity) gRPCTogRPCHandler(input *Input, output *Output, logger log.Lo
 					InvokeMethodData["MethodName"] = input.GRPCMthdParamtrs["methodName"]
 					InvokeMethodData["reqdata"] = input.GRPCMthdParamtrs["reqdata"]
 					InvokeMethodData["strmReq"] = input.GRPCMthdParamtrs["strmReq"]
+					if input.GRPCMthdParamtrs["strmReq"] == nil && input.Content != nil {
+						// without a stream to pass through the messages come from the content input
+						InvokeMethodData["Content"] = input.Content
+					}
+					InvokeMethodData["MaxStreamMessages"] = a.settings.MaxStreamMessages
+					InvokeMethodData["StreamTimeout"] = a.settings.StreamTimeout
+					if input.StreamWriter != nil {
+						InvokeMethodData["StreamWriter"] = input.StreamWriter
+					}
 
 					resMap := service.InvokeMethod(InvokeMethodData)
 
+					if resMap["Response"] != nil {
+						err := json.Unmarshal(resMap["Response"].([]byte), &output.Body)
+						if err != nil {
+							return err
+						}
+					}
 					if resMap["Error"] != nil {
 						logger.Errorf("Error occured:%v", resMap["Error"])
 						erroString := fmt.Sprintf("%v", resMap["Error"])
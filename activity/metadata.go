@@ -10,6 +10,31 @@ type Settings struct {
 	HostURL       string `md:"hosturl"`
 	EnableTLS     bool   `md:"enableTLS"`
 	ClientCert    string `md:"clientCert"`
+	// MaxStreamMessages limits the responses collected from a response stream, zero means no limit
+	MaxStreamMessages int `md:"maxStreamMessages"`
+	// StreamTimeout limits in milliseconds the time responses are collected from a response stream, zero means no limit
+	StreamTimeout int `md:"streamTimeout"`
+
+	// KeepaliveTime is the idle time in milliseconds after which the connection is pinged, zero means no ping
+	KeepaliveTime int `md:"keepaliveTime"`
+	// KeepaliveTimeout is the time in milliseconds waited for the answer to a ping before closing the connection
+	KeepaliveTimeout int `md:"keepaliveTimeout"`
+	// KeepalivePermitWithoutStream pings the connection even without active call
+	KeepalivePermitWithoutStream bool `md:"keepalivePermitWithoutStream"`
+	// MaxCallSendMsgSize limits in bytes the request messages, zero keeps the default of gRPC
+	MaxCallSendMsgSize int `md:"maxCallSendMsgSize"`
+	// MaxCallRecvMsgSize limits in bytes the response messages, zero keeps the default of gRPC
+	MaxCallRecvMsgSize int `md:"maxCallRecvMsgSize"`
+	// Compression is the compressor of the request messages, gzip or none
+	Compression string `md:"compression"`
+	// WaitForReady makes the calls wait for the connection to be ready instead of failing fast
+	WaitForReady bool `md:"waitForReady"`
+	// UserAgent is prepended to the user agent of the calls
+	UserAgent string `md:"userAgent"`
+	// Authority overrides the :authority of the calls, the host of hosturl by default
+	Authority string `md:"authority"`
+	// ConnectTimeout makes the dial block until the connection is ready, for at most this time in milliseconds
+	ConnectTimeout int `md:"connectTimeout"`
 }
 
 // Input is the input into the javascript engine
@@ -23,6 +48,7 @@ type Input struct {
 	QueryParams      map[string]string      `md:"queryParams"`
 	Content          interface{}            `md:"content"`
 	PathParams       map[string]string      `md:"pathParams"`
+	StreamWriter     interface{}            `md:"streamWriter"`
 }
 
 // FromMap converts the values from a map into the struct Input
@@ -68,6 +94,7 @@ func (r *Input) FromMap(values map[string]interface{}) error {
 		return err
 	}
 	r.PathParams = pathParams
+	r.StreamWriter = values["streamWriter"]
 	return nil
 }
 
@@ -83,6 +110,7 @@ func (r *Input) ToMap() map[string]interface{} {
 		"queryParams":      r.QueryParams,
 		"content":          r.Content,
 		"pathParams":       r.PathParams,
+		"streamWriter":     r.StreamWriter,
 	}
 }
 
@@ -44,6 +44,11 @@ func (a *Activity) restTogRPCHandler(input *Input, output *Output, logger log.Lo
 					InvokeMethodData["Content"] = input.Content
 				}
 				InvokeMethodData["Mode"] = "rest-to-grpc"
+				InvokeMethodData["MaxStreamMessages"] = a.settings.MaxStreamMessages
+				InvokeMethodData["StreamTimeout"] = a.settings.StreamTimeout
+				if input.StreamWriter != nil {
+					InvokeMethodData["StreamWriter"] = input.StreamWriter
+				}
 				resMap := service.InvokeMethod(InvokeMethodData)
 				if resMap["Response"] != nil && strings.Compare(string(resMap["Response"].([]byte)), "null") != 0 {
 					err := json.Unmarshal(resMap["Response"].([]byte), &output.Body)
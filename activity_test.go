@@ -5,18 +5,23 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 
 	"github.com/project-flogo/core/activity"
 	"github.com/project-flogo/core/data"
 	"github.com/project-flogo/core/data/mapper"
-	"github.com/project-flogo/core/data/metadata"
+	coremetadata "github.com/project-flogo/core/data/metadata"
 	logger "github.com/project-flogo/core/support/log"
 	grpcactivity "github.com/project-flogo/grpc/activity"
+	"github.com/project-flogo/grpc/proto/grpc2grpc"
 	"github.com/project-flogo/grpc/proto/rest2grpc"
 )
 
@@ -172,7 +177,7 @@ func (a *activityContext) ID() string {
 	return "test"
 }
 
-func (a *activityContext) IOMetadata() *metadata.IOMetadata {
+func (a *activityContext) IOMetadata() *coremetadata.IOMetadata {
 	return nil
 }
 
@@ -309,3 +314,161 @@ func TestGRPC(t *testing.T) {
 		t.Fatal("name should be equal to cat2")
 	}
 }
+
+func TestGRPCStreamsFromContent(t *testing.T) {
+	harness, err := grpc2grpc.NewPetStoreServiceHarness(filepath.FromSlash("./proto/grpc2grpc/petstore.PetStoreService.fixtures.json"))
+	assert.Nil(t, err)
+	addr, err := harness.Start("localhost:0")
+	assert.Nil(t, err)
+	defer harness.Stop()
+
+	activity, err := grpcactivity.New(newInitContext(map[string]interface{}{
+		"operatingMode":     "rest-to-grpc",
+		"hosturl":           addr,
+		"maxStreamMessages": 2,
+	}))
+	assert.Nil(t, err)
+	eval := func(methodName string, content interface{}) interface{} {
+		ctx := newActivityContext(map[string]interface{}{
+			"protoName":   "petstore",
+			"serviceName": "PetStoreService",
+			"methodName":  methodName,
+			"content":     content,
+		})
+		_, err := activity.Eval(ctx)
+		assert.Nil(t, err)
+		return ctx.output["body"]
+	}
+
+	body := eval("StoreUsers", []interface{}{
+		map[string]interface{}{"id": 22, "username": "user22"},
+		map[string]interface{}{"id": 23, "username": "user23"},
+	})
+	assert.Equal(t, map[string]interface{}{"msg": "users stored"}, body)
+
+	body = eval("ListUsers", map[string]interface{}{})
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"id": float64(2), "username": "user2"},
+		map[string]interface{}{"id": float64(3), "username": "user3"},
+	}, body)
+
+	body = eval("BulkUsers", []interface{}{
+		map[string]interface{}{"username": "user22c"},
+		map[string]interface{}{"username": "user23c"},
+	})
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"id": float64(32), "username": "user32s"},
+		map[string]interface{}{"id": float64(33), "username": "user33s"},
+	}, body)
+}
+
+func TestGRPCClientSettings(t *testing.T) {
+	petMapArr[5] = rest2grpc.Pet{Id: 5, Name: "cat5"}
+
+	var md metadata.MD
+	var client net.Addr
+	socket, err := net.Listen("tcp", "localhost:0")
+	assert.Nil(t, err)
+	server := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ = metadata.FromIncomingContext(ctx)
+		if p, ok := peer.FromContext(ctx); ok {
+			client = p.Addr
+		}
+		return handler(ctx, req)
+	}))
+	rest2grpc.RegisterRest2GRPCPetStoreServiceServer(server, &ServerStrct{})
+	go server.Serve(socket)
+	defer server.Stop()
+
+	_, err = grpcactivity.New(newInitContext(map[string]interface{}{
+		"operatingMode": "grpc-to-grpc",
+		"hosturl":       socket.Addr().String(),
+		"compression":   "snappy",
+	}))
+	assert.NotNil(t, err)
+
+	eval := func(settings map[string]interface{}) (*rest2grpc.PetResponse, error) {
+		settings["operatingMode"] = "grpc-to-grpc"
+		act, err := grpcactivity.New(newInitContext(settings))
+		assert.Nil(t, err)
+		ctx := newActivityContext(map[string]interface{}{
+			"grpcMthdParamtrs": map[string]interface{}{
+				"methodName":  "PetById",
+				"contextdata": context.Background(),
+				"reqdata":     &rest2grpc.PetByIdRequest{Id: 5},
+				"serviceName": "Rest2GRPCPetStoreService",
+				"protoName":   "petstore",
+			},
+		})
+		_, err = act.Eval(ctx)
+		pet, _ := ctx.output["body"].(*rest2grpc.PetResponse)
+		return pet, err
+	}
+
+	pet, err := eval(map[string]interface{}{
+		"hosturl":                      socket.Addr().String(),
+		"keepaliveTime":                60000,
+		"keepaliveTimeout":             10000,
+		"keepalivePermitWithoutStream": true,
+		"maxCallSendMsgSize":           1024,
+		"maxCallRecvMsgSize":           1024,
+		"compression":                  "gzip",
+		"waitForReady":                 true,
+		"userAgent":                    "petstore-gateway/1.0",
+		"authority":                    "petstore.example.com",
+		"connectTimeout":               1000,
+	})
+	assert.Nil(t, err)
+	if assert.NotNil(t, pet) {
+		assert.Equal(t, "cat5", pet.Pet.Name)
+	}
+	assert.True(t, strings.HasPrefix(md.Get("user-agent")[0], "petstore-gateway/1.0"))
+	assert.Equal(t, []string{"petstore.example.com"}, md.Get(":authority"))
+
+	// responses over the receive limit are refused
+	pet, err = eval(map[string]interface{}{
+		"hosturl":            socket.Addr().String(),
+		"maxCallRecvMsgSize": 4,
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, pet)
+
+	// the blocking dial fails once the connect timeout expires
+	closed, err := net.Listen("tcp", "localhost:0")
+	assert.Nil(t, err)
+	closed.Close()
+	start := time.Now()
+	_, err = eval(map[string]interface{}{
+		"hosturl":        closed.Addr().String(),
+		"connectTimeout": 200,
+	})
+	assert.NotNil(t, err)
+	assert.True(t, time.Since(start) < 5*time.Second)
+
+	// the pooled connection stays open between the calls
+	_, err = eval(map[string]interface{}{"hosturl": socket.Addr().String()})
+	assert.Nil(t, err)
+	first := client
+	_, err = eval(map[string]interface{}{"hosturl": socket.Addr().String()})
+	assert.Nil(t, err)
+	assert.Equal(t, first.String(), client.String())
+
+	// a dial in progress does not hold up the calls of the other connections
+	dialed := make(chan struct{})
+	go func() {
+		defer close(dialed)
+		eval(map[string]interface{}{
+			"hosturl":        closed.Addr().String(),
+			"connectTimeout": 1000,
+		})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	start = time.Now()
+	_, err = eval(map[string]interface{}{
+		"hosturl":   socket.Addr().String(),
+		"userAgent": "petstore-gateway/2.0",
+	})
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) < 500*time.Millisecond, time.Since(start).String())
+	<-dialed
+}
@@ -10,6 +10,7 @@ require (
 	github.com/project-flogo/microgateway v0.0.0-20190607162005-6e2aefe19808
 	github.com/stretchr/testify v1.3.0
 	golang.org/x/net v0.0.0-20190227160552-c95aed5357e7
+	google.golang.org/genproto v0.0.0-20180831171423-11092d34479b
 	google.golang.org/grpc v1.2.1-0.20190227180446-5878d965b223
 	gopkg.in/yaml.v2 v2.2.2 // indirect
 )
@@ -1,23 +1,223 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/project-flogo/grpc/support"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
 )
 
 var (
 	packageName = flag.String("package", "main", "package name")
 	output      = flag.String("output", ".", "name of output directory")
 	input       = flag.String("input", "", "location of the proto file")
+	include     = flag.String("include", "", "comma separated list of additional proto import paths")
+	harness     = flag.Bool("harness", false, "generate a fixture backed mock server and client helpers")
+	mode        = flag.String("mode", support.ModeGRPCToGRPC, "one of grpc-to-grpc, grpc-to-rest or rest-to-grpc, rest-to-grpc also generates the OpenAPI document")
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "scaffold":
+			scaffold(os.Args[2:])
+			return
+		case "call":
+			call(os.Args[2:])
+			return
+		}
+	}
+
 	flag.Parse()
 
 	support.AssignValues(*output)
+	support.AssignHarness(*harness)
+	support.AssignMode(*mode)
+	if *include != "" {
+		support.AssignIncludePaths(strings.Split(*include, ",")...)
+	}
 	err := support.GenerateSupportFiles(*packageName, *input)
 	if err != nil {
-		panic(err)
+		fatal(err)
+	}
+}
+
+// fatal prints the error and exits with 1
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	os.Exit(1)
+}
+
+// scaffold generates a flogo.json application from a proto file
+func scaffold(args []string) {
+	flags := flag.NewFlagSet("scaffold", flag.ExitOnError)
+	input := flags.String("input", "", "location of the proto file")
+	output := flags.String("output", "flogo.json", "location of the generated application")
+	mode := flags.String("mode", support.ModeGRPCToGRPC, "one of grpc-to-grpc, grpc-to-rest or rest-to-grpc")
+	backend := flags.String("backend", "", "backend url, or a comma separated list of service=url entries")
+	port := flags.Int("port", 9096, "port the gateway listens on")
+	name := flags.String("name", "", "name of the application")
+	include := flags.String("include", "", "comma separated list of additional proto import paths")
+	flags.Parse(args)
+
+	var includePaths []string
+	if *include != "" {
+		includePaths = strings.Split(*include, ",")
+	}
+	err := support.GenerateScaffold(support.ScaffoldConfig{
+		ProtoPath:    *input,
+		Mode:         *mode,
+		Backends:     support.ParseBackends(*backend),
+		Port:         *port,
+		AppName:      *name,
+		IncludePaths: includePaths,
+	}, *output)
+	if err != nil {
+		fatal(err)
+	}
+}
+
+// headerFlags collects repeated -H flags
+type headerFlags []string
+
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlags) Set(value string) error {
+	if !strings.Contains(value, ":") {
+		return fmt.Errorf("header [%s] is not of the form name: value", value)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+// call invokes a method of a server and prints the headers, responses, trailers and status as JSON
+func call(args []string) {
+	flags := flag.NewFlagSet("call", flag.ExitOnError)
+	protoFile := flags.String("proto", "", "proto file describing the service, the reflection service of the server is used otherwise")
+	include := flags.String("include", "", "comma separated list of additional proto import paths")
+	data := flags.String("d", "{}", "JSON request, newline delimited JSON requests for client streaming methods, @file to read a file or @- to read stdin")
+	enableTLS := flags.Bool("tls", false, "use TLS")
+	caCert := flags.String("cacert", "", "CA certificate file in PEM format to verify the server")
+	cert := flags.String("cert", "", "client certificate file in PEM format")
+	key := flags.String("key", "", "client private key file in PEM format")
+	serverName := flags.String("servername", "", "server name to verify the server certificate against")
+	insecure := flags.Bool("insecure", false, "skip the verification of the server certificate")
+	timeout := flags.Duration("timeout", 30*time.Second, "timeout of the call")
+	var headers headerFlags
+	flags.Var(&headers, "H", "request header of the form name: value, can be repeated")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: grpc call [flags] target Service/Method")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	target, method := flags.Arg(0), flags.Arg(1)
+
+	var opts []grpc.DialOption
+	if *enableTLS || *caCert != "" || *cert != "" || *insecure {
+		config, err := clientTLSConfig(*caCert, *cert, *key, *serverName, *insecure)
+		if err != nil {
+			fatal(err)
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(config)))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+	conn, err := grpc.Dial(target, opts...)
+	if err != nil {
+		fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var descriptors *support.Descriptors
+	if *protoFile != "" {
+		var includes []string
+		if *include != "" {
+			includes = strings.Split(*include, ",")
+		}
+		descriptors, err = support.LoadDescriptors(*protoFile, includes...)
+	} else {
+		descriptors, err = support.ReflectionDescriptors(ctx, conn, "")
+	}
+	if err != nil {
+		fatal(err)
+	}
+
+	var body io.Reader = strings.NewReader(*data)
+	if *data == "@-" {
+		body = os.Stdin
+	} else if strings.HasPrefix(*data, "@") {
+		f, err := os.Open((*data)[1:])
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		body = f
+	}
+	requests, err := support.ReadRequests(body)
+	if err != nil {
+		fatal(err)
+	}
+
+	md := metadata.MD{}
+	for _, header := range headers {
+		index := strings.Index(header, ":")
+		md.Append(strings.TrimSpace(header[:index]), strings.TrimSpace(header[index+1:]))
+	}
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	result, err := support.Invoke(ctx, conn, descriptors, method, requests)
+	if err != nil {
+		fatal(err)
+	}
+	b, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Println(string(b))
+	if result.Status.Code != "OK" {
+		os.Exit(1)
+	}
+}
+
+func clientTLSConfig(caCert, cert, key, serverName string, insecure bool) (*tls.Config, error) {
+	config := &tls.Config{ServerName: serverName, InsecureSkipVerify: insecure}
+	if caCert != "" {
+		b, err := ioutil.ReadFile(caCert)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in [%s]", caCert)
+		}
+	}
+	if cert != "" {
+		certificate, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{certificate}
 	}
+	return config, nil
 }
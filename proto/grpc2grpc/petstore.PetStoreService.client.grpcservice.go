@@ -6,12 +6,12 @@
 		"context"
 		
 		"encoding/json"
-		"github.com/project-flogo/grpc/support"
 		
+		"github.com/project-flogo/grpc/support"
 		"errors"
 		
-		"strings"
 		"io"
+		"github.com/golang/protobuf/proto"
 		
 		"log"
 		
@@ -103,12 +103,27 @@
 	func ListUsers(client PetStoreServiceClient, reqArr map[string]interface{}) map[string]interface{} {
 		resMap := make(map[string]interface{}, 1)
 
-		if reqArr["Mode"] != nil {
-			mode := reqArr["Mode"].(string)
-			if strings.Compare(mode,"rest-to-grpc") == 0 {
-				resMap["Error"] = errors.New("streaming operation is not allowed in rest to grpc case")
+		sReq, ok := reqArr["strmReq"].(PetStoreService_ListUsersServer)
+		if !ok {
+			// without a stream to pass through the request comes from flow data and the responses are collected
+			req := &EmptyReq{}
+			support.AssignStructValues(req, reqArr)
+			ctx, cancel := support.StreamContext(reqArr)
+			defer cancel()
+			stream, err := client.ListUsers(ctx, req)
+			if err != nil {
+				log.Println("erorr while getting stream object for ListUsers:", err)
+				resMap["Error"] = err
+				return resMap
+			}
+			b, err := support.RecvMessages(ctx, stream, func() proto.Message { return &User{} }, reqArr)
+			if err != nil {
+				log.Println("erorr occured in ListUsers Recv():", err)
+				resMap["Error"] = err
 				return resMap
 			}
+			resMap["Response"] = b
+			return resMap
 		}
 
 		req := &EmptyReq{}
@@ -118,8 +133,6 @@
 			return resMap
 		}
 
-		sReq := reqArr["strmReq"].(PetStoreService_ListUsersServer)
-
 		stream, err := client.ListUsers(context.Background(), req)
 		if err != nil {
 			log.Println("erorr while getting stream object for ListUsers:", err)
@@ -150,12 +163,35 @@
 	func StoreUsers(client PetStoreServiceClient, reqArr map[string]interface{}) map[string]interface{} {
 		resMap := make(map[string]interface{}, 1)
 
-		if reqArr["Mode"] != nil {
-			mode := reqArr["Mode"].(string)
-			if strings.Compare(mode,"rest-to-grpc") == 0 {
-				resMap["Error"] = errors.New("streaming operation is not allowed in rest to grpc case")
+		cReq, ok := reqArr["strmReq"].(PetStoreService_StoreUsersServer)
+		if !ok {
+			// without a stream to pass through the messages come from flow data
+			ctx, cancel := support.StreamContext(reqArr)
+			defer cancel()
+			stream, err := client.StoreUsers(ctx)
+			if err != nil {
+				log.Println("erorr while getting stream object for StoreUsers:", err)
+				resMap["Error"] = err
+				return resMap
+			}
+			if err = support.SendMessages(stream, reqArr, func() proto.Message { return &User{} }); err != nil {
+				log.Println("error while sending flow data with client stream:", err)
+				resMap["Error"] = err
+				return resMap
+			}
+			obj, err := stream.CloseAndRecv()
+			if err != nil {
+				log.Println("erorr occured in StoreUsers CloseAndRecv():", err)
+				resMap["Error"] = err
 				return resMap
 			}
+			b, err := json.Marshal(obj)
+			if err != nil {
+				resMap["Error"] = err
+				return resMap
+			}
+			resMap["Response"] = b
+			return resMap
 		}
 
 		stream, err := client.StoreUsers(context.Background())
@@ -165,8 +201,6 @@
 			return resMap
 		}
 
-		cReq := reqArr["strmReq"].(PetStoreService_StoreUsersServer)
-
 		for {
 			dataObj, err := cReq.Recv()
 			if err == io.EOF {
@@ -196,16 +230,29 @@
 	func BulkUsers(client PetStoreServiceClient, reqArr map[string]interface{}) map[string]interface{} {
 		resMap := make(map[string]interface{}, 1)
 
-		if reqArr["Mode"] != nil {
-			mode := reqArr["Mode"].(string)
-			if strings.Compare(mode,"rest-to-grpc") == 0 {
-				resMap["Error"] = errors.New("streaming operation is not allowed in rest to grpc case")
+		bReq, ok := reqArr["strmReq"].(PetStoreService_BulkUsersServer)
+		if !ok {
+			// without a stream to pass through the messages come from flow data and the responses are collected
+			ctx, cancel := support.StreamContext(reqArr)
+			defer cancel()
+			stream, err := client.BulkUsers(ctx)
+			if err != nil {
+				log.Println("error while getting stream object for BulkUsers:", err)
+				resMap["Error"] = err
+				return resMap
+			}
+			// a failed send ends the call, its status is returned by Recv
+			go support.SendMessages(stream, reqArr, func() proto.Message { return &User{} })
+			b, err := support.RecvMessages(ctx, stream, func() proto.Message { return &User{} }, reqArr)
+			if err != nil {
+				log.Println("erorr occured in BulkUsers stream Recv():", err)
+				resMap["Error"] = err
 				return resMap
 			}
+			resMap["Response"] = b
+			return resMap
 		}
 
-		bReq := reqArr["strmReq"].(PetStoreService_BulkUsersServer)
-
 		stream, err := client.BulkUsers(context.Background())
 		if err != nil {
 			log.Println("error while getting stream object for BulkUsers:", err)
@@ -0,0 +1,318 @@
+// This file provides a mock server and client helpers. This file was auto-generated by mashling at
+// 2026-10-19 14:27:21.985312914 +0000 UTC m=+0.000826095
+package grpc2grpc
+
+import (
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/project-flogo/grpc/support"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// PetStoreServiceHarness serves the methods of PetStoreService from fixtures
+type PetStoreServiceHarness struct {
+	Server   *grpc.Server
+	Fixtures support.Fixtures
+}
+
+// NewPetStoreServiceHarness creates a mock server of PetStoreService serving the fixtures of the given JSON file
+func NewPetStoreServiceHarness(fixturesPath string, opts ...grpc.ServerOption) (*PetStoreServiceHarness, error) {
+	fixtures, err := support.LoadFixtures(fixturesPath)
+	if err != nil {
+		return nil, err
+	}
+	harness := &PetStoreServiceHarness{
+		Server:   grpc.NewServer(opts...),
+		Fixtures: fixtures,
+	}
+	RegisterPetStoreServiceServer(harness.Server, harness)
+	return harness, nil
+}
+
+// Start listens on the given address and serves in the background, the listening address is returned
+func (h *PetStoreServiceHarness) Start(addr string) (string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	go h.Server.Serve(listener)
+	return listener.Addr().String(), nil
+}
+
+// Stop stops the mock server
+func (h *PetStoreServiceHarness) Stop() {
+	h.Server.Stop()
+}
+
+func (h *PetStoreServiceHarness) PetById(ctx context.Context, req *PetByIdRequest) (*PetResponse, error) {
+	fixture, err := h.Fixtures.Match("PetById", req)
+	if err != nil {
+		return nil, err
+	}
+	if err := fixture.Err(); err != nil {
+		return nil, err
+	}
+	res := &PetResponse{}
+	if err := support.UnmarshalJSONMessage(fixture.Response, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (h *PetStoreServiceHarness) UserByName(ctx context.Context, req *UserByNameRequest) (*UserResponse, error) {
+	fixture, err := h.Fixtures.Match("UserByName", req)
+	if err != nil {
+		return nil, err
+	}
+	if err := fixture.Err(); err != nil {
+		return nil, err
+	}
+	res := &UserResponse{}
+	if err := support.UnmarshalJSONMessage(fixture.Response, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (h *PetStoreServiceHarness) ListUsers(req *EmptyReq, stream PetStoreService_ListUsersServer) error {
+	fixture, err := h.Fixtures.Match("ListUsers", req)
+	if err != nil {
+		return err
+	}
+	for _, data := range fixture.Replies() {
+		res := &User{}
+		if err := support.UnmarshalJSONMessage(data, res); err != nil {
+			return err
+		}
+		if err := stream.Send(res); err != nil {
+			return err
+		}
+	}
+	return fixture.Err()
+}
+
+func (h *PetStoreServiceHarness) StoreUsers(stream PetStoreService_StoreUsersServer) error {
+	var first *User
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if first == nil {
+			first = req
+		}
+	}
+	if first == nil {
+		first = &User{}
+	}
+
+	// the first message of the stream selects the fixture
+	fixture, err := h.Fixtures.Match("StoreUsers", first)
+	if err != nil {
+		return err
+	}
+	if err := fixture.Err(); err != nil {
+		return err
+	}
+	res := &EmptyRes{}
+	if err := support.UnmarshalJSONMessage(fixture.Response, res); err != nil {
+		return err
+	}
+	return stream.SendAndClose(res)
+}
+
+func (h *PetStoreServiceHarness) BulkUsers(stream PetStoreService_BulkUsersServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fixture, err := h.Fixtures.Match("BulkUsers", req)
+		if err != nil {
+			return err
+		}
+		for _, data := range fixture.Replies() {
+			res := &User{}
+			if err := support.UnmarshalJSONMessage(data, res); err != nil {
+				return err
+			}
+			if err := stream.Send(res); err != nil {
+				return err
+			}
+		}
+		if err := fixture.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// PetStoreServiceHarnessClient calls the methods of PetStoreService with requests and responses in JSON
+type PetStoreServiceHarnessClient struct {
+	client PetStoreServiceClient
+}
+
+// NewPetStoreServiceHarnessClient creates a client helper of PetStoreService on the given connection
+func NewPetStoreServiceHarnessClient(conn *grpc.ClientConn) *PetStoreServiceHarnessClient {
+	return &PetStoreServiceHarnessClient{client: NewPetStoreServiceClient(conn)}
+}
+
+// Call invokes a method by name, unary and server streaming methods take exactly one request
+func (c *PetStoreServiceHarnessClient) Call(ctx context.Context, methodName string, requests ...string) ([]string, error) {
+	switch methodName {
+	case "PetById":
+		if len(requests) != 1 {
+			return nil, fmt.Errorf("method [PetById] takes one request")
+		}
+		res, err := c.PetById(ctx, requests[0])
+		if err != nil {
+			return nil, err
+		}
+		return []string{res}, nil
+	case "UserByName":
+		if len(requests) != 1 {
+			return nil, fmt.Errorf("method [UserByName] takes one request")
+		}
+		res, err := c.UserByName(ctx, requests[0])
+		if err != nil {
+			return nil, err
+		}
+		return []string{res}, nil
+	case "ListUsers":
+		if len(requests) != 1 {
+			return nil, fmt.Errorf("method [ListUsers] takes one request")
+		}
+		return c.ListUsers(ctx, requests[0])
+	case "StoreUsers":
+		res, err := c.StoreUsers(ctx, requests)
+		if err != nil {
+			return nil, err
+		}
+		return []string{res}, nil
+	case "BulkUsers":
+		return c.BulkUsers(ctx, requests)
+	}
+	return nil, fmt.Errorf("method [%s] not available in service [PetStoreService]", methodName)
+}
+
+// PetById calls PetById with a JSON request and returns the JSON response
+func (c *PetStoreServiceHarnessClient) PetById(ctx context.Context, request string) (string, error) {
+	req := &PetByIdRequest{}
+	if err := support.UnmarshalJSONMessage([]byte(request), req); err != nil {
+		return "", err
+	}
+	res, err := c.client.PetById(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	return support.MarshalJSONMessage(res)
+}
+
+// UserByName calls UserByName with a JSON request and returns the JSON response
+func (c *PetStoreServiceHarnessClient) UserByName(ctx context.Context, request string) (string, error) {
+	req := &UserByNameRequest{}
+	if err := support.UnmarshalJSONMessage([]byte(request), req); err != nil {
+		return "", err
+	}
+	res, err := c.client.UserByName(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	return support.MarshalJSONMessage(res)
+}
+
+// ListUsers calls ListUsers with a JSON request and returns the streamed JSON responses
+func (c *PetStoreServiceHarnessClient) ListUsers(ctx context.Context, request string) ([]string, error) {
+	req := &EmptyReq{}
+	if err := support.UnmarshalJSONMessage([]byte(request), req); err != nil {
+		return nil, err
+	}
+	stream, err := c.client.ListUsers(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	var responses []string
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return responses, nil
+		}
+		if err != nil {
+			return responses, err
+		}
+		data, err := support.MarshalJSONMessage(res)
+		if err != nil {
+			return responses, err
+		}
+		responses = append(responses, data)
+	}
+}
+
+// StoreUsers streams JSON requests to StoreUsers and returns the JSON response
+func (c *PetStoreServiceHarnessClient) StoreUsers(ctx context.Context, requests []string) (string, error) {
+	stream, err := c.client.StoreUsers(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, request := range requests {
+		req := &User{}
+		if err := support.UnmarshalJSONMessage([]byte(request), req); err != nil {
+			return "", err
+		}
+		if err := stream.Send(req); err != nil {
+			return "", err
+		}
+	}
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		return "", err
+	}
+	return support.MarshalJSONMessage(res)
+}
+
+// BulkUsers streams JSON requests to BulkUsers and returns the streamed JSON responses
+func (c *PetStoreServiceHarnessClient) BulkUsers(ctx context.Context, requests []string) ([]string, error) {
+	stream, err := c.client.BulkUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	done := make(chan error, 1)
+	go func() {
+		for _, request := range requests {
+			req := &User{}
+			if err := support.UnmarshalJSONMessage([]byte(request), req); err != nil {
+				done <- err
+				return
+			}
+			if err := stream.Send(req); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- stream.CloseSend()
+	}()
+
+	var responses []string
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return responses, <-done
+		}
+		if err != nil {
+			return responses, err
+		}
+		data, err := support.MarshalJSONMessage(res)
+		if err != nil {
+			return responses, err
+		}
+		responses = append(responses, data)
+	}
+}
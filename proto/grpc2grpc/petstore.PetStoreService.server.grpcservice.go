@@ -13,6 +13,7 @@ import (
 	"log"
 
 	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
 	servInfo "github.com/project-flogo/grpc/trigger/grpc"
 	"google.golang.org/grpc"
 )
@@ -149,11 +150,15 @@ func (s *serviceImplpetstorePetStoreServiceserver) ListUsers(req *EmptyReq, sReq
 	methodName := "ListUsers"
 	serviceName := "PetStoreService"
 
+	writer := servInfo.NewServerStreamWriter(sReq, func() proto.Message { return &User{} })
+	defer writer.Close()
+
 	grpcData := make(map[string]interface{})
 	grpcData["methodName"] = methodName
 	grpcData["serviceName"] = serviceName
 	grpcData["reqdata"] = req
 	grpcData["strmReq"] = sReq
+	grpcData["streamWriter"] = writer
 
 	_, data, err := s.trigger.CallHandler(grpcData)
 
@@ -162,11 +167,13 @@ func (s *serviceImplpetstorePetStoreServiceserver) ListUsers(req *EmptyReq, sReq
 		return err
 	}
 
-	if data != nil && data.(map[string]interface{})["error"] != nil {
-		log.Println("error from end server: ", data.(map[string]interface{})["error"])
-		return errors.New(data.(map[string]interface{})["error"].(string))
+	if err = servInfo.ReplyError(data); err != nil {
+		log.Println("error from end server: ", err)
+		return err
 	}
-	return nil
+
+	// each element of a reply array is sent as a message of the stream
+	return writer.SendReply(data)
 }
 
 func (s *serviceImplpetstorePetStoreServiceserver) StoreUsers(cReq PetStoreService_StoreUsersServer) error {
@@ -174,10 +181,13 @@ func (s *serviceImplpetstorePetStoreServiceserver) StoreUsers(cReq PetStoreServi
 	methodName := "StoreUsers"
 	serviceName := "PetStoreService"
 
+	reader := servInfo.NewClientStreamReader(cReq, func() proto.Message { return &User{} })
+
 	grpcData := make(map[string]interface{})
 	grpcData["methodName"] = methodName
 	grpcData["serviceName"] = serviceName
 	grpcData["strmReq"] = cReq
+	grpcData["streamReader"] = reader
 
 	_, data, err := s.trigger.CallHandler(grpcData)
 
@@ -186,11 +196,21 @@ func (s *serviceImplpetstorePetStoreServiceserver) StoreUsers(cReq PetStoreServi
 		return err
 	}
 
-	if data != nil && data.(map[string]interface{})["error"] != nil {
-		log.Println("error from end server: ", data.(map[string]interface{})["error"])
-		return errors.New(data.(map[string]interface{})["error"].(string))
+	if err = servInfo.ReplyError(data); err != nil {
+		log.Println("error from end server: ", err)
+		return err
 	}
-	return nil
+
+	if !reader.Drained() {
+		// the stream was passed through and the response was sent by the handler
+		return nil
+	}
+	res, err := servInfo.ToMessage(data, &EmptyRes{})
+	if err != nil {
+		log.Println("error: ", err)
+		return err
+	}
+	return cReq.SendAndClose(res.(*EmptyRes))
 }
 
 func (s *serviceImplpetstorePetStoreServiceserver) BulkUsers(bdReq PetStoreService_BulkUsersServer) error {
@@ -198,10 +218,16 @@ func (s *serviceImplpetstorePetStoreServiceserver) BulkUsers(bdReq PetStoreServi
 	methodName := "BulkUsers"
 	serviceName := "PetStoreService"
 
+	reader := servInfo.NewClientStreamReader(bdReq, func() proto.Message { return &User{} })
+	writer := servInfo.NewServerStreamWriter(bdReq, func() proto.Message { return &User{} })
+	defer writer.Close()
+
 	grpcData := make(map[string]interface{})
 	grpcData["methodName"] = methodName
 	grpcData["serviceName"] = serviceName
 	grpcData["strmReq"] = bdReq
+	grpcData["streamReader"] = reader
+	grpcData["streamWriter"] = writer
 
 	_, data, err := s.trigger.CallHandler(grpcData)
 
@@ -210,9 +236,9 @@ func (s *serviceImplpetstorePetStoreServiceserver) BulkUsers(bdReq PetStoreServi
 		return err
 	}
 
-	if data != nil && data.(map[string]interface{})["error"] != nil {
-		log.Println("error from end server: ", data.(map[string]interface{})["error"])
-		return errors.New(data.(map[string]interface{})["error"].(string))
+	if err = servInfo.ReplyError(data); err != nil {
+		log.Println("error from end server: ", err)
+		return err
 	}
 	return nil
 }
@@ -220,3 +246,18 @@ func (s *serviceImplpetstorePetStoreServiceserver) BulkUsers(bdReq PetStoreServi
 func (s *serviceImplpetstorePetStoreServiceserver) ServiceInfo() *servInfo.ServiceInfo {
 	return s.serviceInfo
 }
+
+// FileDescriptor returns the compressed descriptor of the proto file
+func (s *serviceImplpetstorePetStoreServiceserver) FileDescriptor() []byte {
+	descriptor, _ := (&PetByIdRequest{}).Descriptor()
+	return descriptor
+}
+
+// ServiceDesc returns the description of the service and its implementation for the trigger, whose stream handlers
+// are invoked in-process by the WebSocket bridges
+func (s *serviceImplpetstorePetStoreServiceserver) ServiceDesc(trigger *servInfo.Trigger) (*grpc.ServiceDesc, interface{}) {
+	return &_PetStoreService_serviceDesc, &serviceImplpetstorePetStoreServiceserver{
+		trigger:     trigger,
+		serviceInfo: serviceInfopetstorePetStoreServiceserver,
+	}
+}
@@ -251,3 +251,18 @@ func (s *serviceImplpetstoreGRPC2RestPetStoreServiceserver) UserPUT(ctx context.
 func (s *serviceImplpetstoreGRPC2RestPetStoreServiceserver) ServiceInfo() *servInfo.ServiceInfo {
 	return s.serviceInfo
 }
+
+// FileDescriptor returns the compressed descriptor of the proto file
+func (s *serviceImplpetstoreGRPC2RestPetStoreServiceserver) FileDescriptor() []byte {
+	descriptor, _ := (&PetByIdRequest{}).Descriptor()
+	return descriptor
+}
+
+// ServiceDesc returns the description of the service and its implementation for the trigger, whose stream handlers
+// are invoked in-process by the WebSocket bridges
+func (s *serviceImplpetstoreGRPC2RestPetStoreServiceserver) ServiceDesc(trigger *servInfo.Trigger) (*grpc.ServiceDesc, interface{}) {
+	return &_GRPC2RestPetStoreService_serviceDesc, &serviceImplpetstoreGRPC2RestPetStoreServiceserver{
+		trigger:     trigger,
+		serviceInfo: serviceInfopetstoreGRPC2RestPetStoreServiceserver,
+	}
+}
@@ -250,3 +250,18 @@ func (s *serviceImplpetstoreRest2GRPCPetStoreServiceserver) UserPUT(ctx context.
 func (s *serviceImplpetstoreRest2GRPCPetStoreServiceserver) ServiceInfo() *servInfo.ServiceInfo {
 	return s.serviceInfo
 }
+
+// FileDescriptor returns the compressed descriptor of the proto file
+func (s *serviceImplpetstoreRest2GRPCPetStoreServiceserver) FileDescriptor() []byte {
+	descriptor, _ := (&PetByIdRequest{}).Descriptor()
+	return descriptor
+}
+
+// ServiceDesc returns the description of the service and its implementation for the trigger, whose stream handlers
+// are invoked in-process by the WebSocket bridges
+func (s *serviceImplpetstoreRest2GRPCPetStoreServiceserver) ServiceDesc(trigger *servInfo.Trigger) (*grpc.ServiceDesc, interface{}) {
+	return &_Rest2GRPCPetStoreService_serviceDesc, &serviceImplpetstoreRest2GRPCPetStoreServiceserver{
+		trigger:     trigger,
+		serviceInfo: serviceInfopetstoreRest2GRPCPetStoreServiceserver,
+	}
+}
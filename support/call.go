@@ -0,0 +1,224 @@
+package support
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+)
+
+// CallResult holds everything received from a call
+type CallResult struct {
+	Headers   metadata.MD   `json:"headers"`
+	Responses []interface{} `json:"responses"`
+	Trailers  metadata.MD   `json:"trailers"`
+	Status    CallStatus    `json:"status"`
+}
+
+// CallStatus is the final status of a call
+type CallStatus struct {
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// Invoke calls the given Service/Method through its descriptors, requests and responses are JSON values
+func Invoke(ctx context.Context, conn *grpc.ClientConn, d *Descriptors, method string, requests []interface{}) (*CallResult, error) {
+	index := strings.LastIndex(method, "/")
+	if index < 0 {
+		return nil, fmt.Errorf("method [%s] is not of the form Service/Method", method)
+	}
+	serviceName, methodName := strings.TrimPrefix(method[:index], "/"), method[index+1:]
+	serviceName, _ = d.Service(serviceName)
+	methodDesc := d.Method(serviceName, methodName)
+	if methodDesc == nil {
+		return nil, fmt.Errorf("method [%s] not found", method)
+	}
+	if !methodDesc.GetClientStreaming() && len(requests) != 1 {
+		return nil, fmt.Errorf("method [%s] takes exactly one request, got %d", method, len(requests))
+	}
+
+	desc := &grpc.StreamDesc{
+		StreamName:    methodName,
+		ServerStreams: methodDesc.GetServerStreaming(),
+		ClientStreams: methodDesc.GetClientStreaming(),
+	}
+	stream, err := conn.NewStream(ctx, desc, "/"+serviceName+"/"+methodName)
+	if err != nil {
+		return callResult(nil, nil, err), nil
+	}
+
+	for _, request := range requests {
+		req, err := d.NewDynamicMessage(methodDesc.GetInputType())
+		if err != nil {
+			return nil, err
+		}
+		req.Value = request
+		if err := stream.SendMsg(req); err != nil {
+			if err == io.EOF {
+				// the server ended the call, its status is returned by RecvMsg
+				break
+			}
+			return nil, err
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+
+	var responses []interface{}
+	for {
+		res, err := d.NewDynamicMessage(methodDesc.GetOutputType())
+		if err != nil {
+			return nil, err
+		}
+		if err = stream.RecvMsg(res); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			headers, _ := stream.Header()
+			return callResult(headers, stream.Trailer(), err, responses...), nil
+		}
+		responses = append(responses, res.Value)
+		if !desc.ServerStreams {
+			// the status of a call without server stream is received along with the response
+			headers, _ := stream.Header()
+			return callResult(headers, stream.Trailer(), nil, responses...), nil
+		}
+	}
+}
+
+func callResult(headers, trailers metadata.MD, err error, responses ...interface{}) *CallResult {
+	if responses == nil {
+		responses = []interface{}{}
+	}
+	s, _ := status.FromError(err)
+	return &CallResult{
+		Headers:   headers,
+		Responses: responses,
+		Trailers:  trailers,
+		Status:    CallStatus{Code: s.Code().String(), Message: s.Message()},
+	}
+}
+
+// ReadRequests reads a single JSON value or a stream of newline delimited JSON values
+func ReadRequests(r io.Reader) ([]interface{}, error) {
+	var requests []interface{}
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	decoder.UseNumber()
+	for {
+		var request interface{}
+		err := decoder.Decode(&request)
+		if err == io.EOF {
+			return requests, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid request: %s", err.Error())
+		}
+		requests = append(requests, request)
+	}
+}
+
+// ReflectionDescriptors loads the descriptors of the given service, or of all services
+// when the name is empty, from the reflection service of a server
+func ReflectionDescriptors(ctx context.Context, conn *grpc.ClientConn, serviceName string) (*Descriptors, error) {
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.CloseSend()
+
+	request := func(req *rpb.ServerReflectionRequest) (*rpb.ServerReflectionResponse, error) {
+		if err := stream.Send(req); err != nil {
+			return nil, err
+		}
+		res, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		if res.GetErrorResponse() != nil {
+			return nil, status.Error(codes.Code(res.GetErrorResponse().GetErrorCode()), res.GetErrorResponse().GetErrorMessage())
+		}
+		return res, nil
+	}
+
+	services := []string{serviceName}
+	if serviceName == "" {
+		res, err := request(&rpb.ServerReflectionRequest{MessageRequest: &rpb.ServerReflectionRequest_ListServices{}})
+		if err != nil {
+			return nil, err
+		}
+		services = nil
+		for _, service := range res.GetListServicesResponse().GetService() {
+			services = append(services, service.GetName())
+		}
+	}
+
+	d := NewDescriptors()
+	// files of the same name may belong to different packages, dependencies are resolved by name
+	loaded := make(map[string]bool)
+	added := make(map[string]bool)
+	add := func(res *rpb.ServerReflectionResponse) ([]string, error) {
+		var dependencies []string
+		for _, b := range res.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			fd := &descriptor.FileDescriptorProto{}
+			if err := proto.Unmarshal(b, fd); err != nil {
+				return nil, err
+			}
+			key := fd.GetPackage() + "/" + fd.GetName()
+			if added[key] {
+				continue
+			}
+			added[key] = true
+			loaded[fd.GetName()] = true
+			d.Add(fd)
+			dependencies = append(dependencies, fd.Dependency...)
+		}
+		return dependencies, nil
+	}
+
+	var pending []string
+	for _, service := range services {
+		res, err := request(&rpb.ServerReflectionRequest{
+			MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve service [%s]: %s", service, err.Error())
+		}
+		dependencies, err := add(res)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, dependencies...)
+	}
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if loaded[name] {
+			continue
+		}
+		res, err := request(&rpb.ServerReflectionRequest{
+			MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: name},
+		})
+		if err != nil {
+			// well known types are resolved by name when they are not served
+			loaded[name] = true
+			continue
+		}
+		dependencies, err := add(res)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, dependencies...)
+	}
+	return d, nil
+}
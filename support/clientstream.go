@@ -0,0 +1,117 @@
+package support
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/project-flogo/core/data/coerce"
+	"google.golang.org/grpc"
+)
+
+// MessageSender receives the messages of a response stream as they arrive, a sender with a
+// Context() context.Context method cancels the call when its context ends
+type MessageSender interface {
+	Send(data interface{}) error
+}
+
+// StreamContext returns the context of a streaming call made from flow data,
+// it ends after the StreamTimeout milliseconds of the values when set or with the context of the StreamWriter
+func StreamContext(values map[string]interface{}) (context.Context, context.CancelFunc) {
+	parent := context.Background()
+	if sender, ok := values["StreamWriter"].(interface{ Context() context.Context }); ok {
+		parent = sender.Context()
+	}
+	timeout, _ := coerce.ToInt(values["StreamTimeout"])
+	if timeout > 0 {
+		return context.WithTimeout(parent, time.Duration(timeout)*time.Millisecond)
+	}
+	return context.WithCancel(parent)
+}
+
+// maxStreamMessages returns the maximum number of responses collected by a streaming call made from flow data,
+// zero means no limit
+func maxStreamMessages(values map[string]interface{}) int {
+	maxMessages, _ := coerce.ToInt(values["MaxStreamMessages"])
+	return maxMessages
+}
+
+// SendMessages sends each element of the Content array of the values as a message created by newMessage
+// and closes the sending side of the stream, any other content is sent as a single message
+func SendMessages(stream grpc.ClientStream, values map[string]interface{}, newMessage func() proto.Message) error {
+	var messages []interface{}
+	switch content := values["Content"].(type) {
+	case nil:
+	case []interface{}:
+		messages = content
+	default:
+		messages = []interface{}{content}
+	}
+
+	unmarshaler := jsonpb.Unmarshaler{AllowUnknownFields: true}
+	for _, value := range messages {
+		msg := newMessage()
+		b, err := marshalContent(value)
+		if err != nil {
+			return err
+		}
+		if err = unmarshaler.Unmarshal(bytes.NewReader(b), msg); err != nil {
+			return err
+		}
+		if err = stream.SendMsg(msg); err != nil {
+			if err == io.EOF {
+				// the server ended the call, its status is returned by RecvMsg
+				return nil
+			}
+			return err
+		}
+	}
+	return stream.CloseSend()
+}
+
+// marshalContent returns the JSON of a message of the content, messages are marshalled with the field names of their
+// proto file as the proto JSON mapping does
+func marshalContent(value interface{}) ([]byte, error) {
+	if msg, ok := value.(proto.Message); ok {
+		var buf bytes.Buffer
+		err := (&jsonpb.Marshaler{OrigName: true}).Marshal(&buf, msg)
+		return buf.Bytes(), err
+	}
+	return json.Marshal(value)
+}
+
+// RecvMessages receives messages created by newMessage until the end of the stream, until MaxStreamMessages are received
+// or until the context of the stream times out, the messages are returned as a JSON array in the proto JSON mapping with
+// the field names of the proto file, or forwarded as they arrive to the StreamWriter of the values when set, leaving the
+// array empty
+func RecvMessages(ctx context.Context, stream grpc.ClientStream, newMessage func() proto.Message, values map[string]interface{}) ([]byte, error) {
+	sender, forward := values["StreamWriter"].(MessageSender)
+	maxMessages := maxStreamMessages(values)
+	messages := make([]json.RawMessage, 0)
+	for count := 0; maxMessages <= 0 || count < maxMessages; count++ {
+		msg := newMessage()
+		err := stream.RecvMsg(msg)
+		if err == io.EOF || (err != nil && ctx.Err() == context.DeadlineExceeded) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !forward {
+			b, err := marshalContent(msg)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, b)
+			continue
+		}
+		if err = sender.Send(msg); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(messages)
+}
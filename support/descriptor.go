@@ -0,0 +1,227 @@
+package support
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Descriptors indexes the messages, enums and services of a set of proto files by their full names
+type Descriptors struct {
+	Files    []*descriptor.FileDescriptorProto
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	services map[string]*descriptor.ServiceDescriptorProto
+	proto2   map[*descriptor.DescriptorProto]bool
+}
+
+// NewDescriptors creates an index of the given proto files
+func NewDescriptors(files ...*descriptor.FileDescriptorProto) *Descriptors {
+	d := &Descriptors{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		services: make(map[string]*descriptor.ServiceDescriptorProto),
+		proto2:   make(map[*descriptor.DescriptorProto]bool),
+	}
+	for _, file := range files {
+		d.Add(file)
+	}
+	return d
+}
+
+// Add indexes a proto file, files which are already indexed are ignored
+func (d *Descriptors) Add(file *descriptor.FileDescriptorProto) {
+	for _, f := range d.Files {
+		if f.GetName() == file.GetName() && f.GetPackage() == file.GetPackage() {
+			return
+		}
+	}
+	d.Files = append(d.Files, file)
+
+	prefix := ""
+	if file.GetPackage() != "" {
+		prefix = "." + file.GetPackage()
+	}
+	for _, message := range file.MessageType {
+		d.addMessage(prefix, message, file.GetSyntax() != "proto3")
+	}
+	for _, enum := range file.EnumType {
+		d.enums[prefix+"."+enum.GetName()] = enum
+	}
+	for _, service := range file.Service {
+		d.services[prefix+"."+service.GetName()] = service
+	}
+}
+
+func (d *Descriptors) addMessage(prefix string, message *descriptor.DescriptorProto, proto2 bool) {
+	name := prefix + "." + message.GetName()
+	d.messages[name] = message
+	d.proto2[message] = proto2
+	for _, nested := range message.NestedType {
+		d.addMessage(name, nested, proto2)
+	}
+	for _, enum := range message.EnumType {
+		d.enums[name+"."+enum.GetName()] = enum
+	}
+}
+
+// Message returns the message with the given full name, the leading dot is optional
+func (d *Descriptors) Message(name string) *descriptor.DescriptorProto {
+	return d.messages[fullName(name)]
+}
+
+// Enum returns the enum with the given full name, the leading dot is optional
+func (d *Descriptors) Enum(name string) *descriptor.EnumDescriptorProto {
+	return d.enums[fullName(name)]
+}
+
+// Service returns the service with the given full name or, when it is unique, the given simple name
+func (d *Descriptors) Service(name string) (string, *descriptor.ServiceDescriptorProto) {
+	if service, ok := d.services[fullName(name)]; ok {
+		return strings.TrimPrefix(fullName(name), "."), service
+	}
+	var found string
+	for full := range d.services {
+		if strings.HasSuffix(full, "."+name) {
+			if found != "" {
+				return "", nil
+			}
+			found = full
+		}
+	}
+	if found == "" {
+		return "", nil
+	}
+	return strings.TrimPrefix(found, "."), d.services[found]
+}
+
+// Services returns the full names of all indexed services
+func (d *Descriptors) Services() []string {
+	var names []string
+	for _, file := range d.Files {
+		for _, service := range file.Service {
+			name := service.GetName()
+			if file.GetPackage() != "" {
+				name = file.GetPackage() + "." + name
+			}
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Method returns the method of the given service
+func (d *Descriptors) Method(serviceName, methodName string) *descriptor.MethodDescriptorProto {
+	_, service := d.Service(serviceName)
+	if service == nil {
+		return nil
+	}
+	for _, method := range service.Method {
+		if method.GetName() == methodName {
+			return method
+		}
+	}
+	return nil
+}
+
+func fullName(name string) string {
+	if strings.HasPrefix(name, ".") {
+		return name
+	}
+	return "." + name
+}
+
+// DecodeFileDescriptor decodes a gzipped file descriptor as embedded in generated pb files
+func DecodeFileDescriptor(gz []byte) (*descriptor.FileDescriptorProto, error) {
+	r, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gzip reader: %v", err)
+	}
+	defer r.Close()
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to uncompress descriptor: %v", err)
+	}
+
+	fd := &descriptor.FileDescriptorProto{}
+	if err := proto.Unmarshal(b, fd); err != nil {
+		return nil, fmt.Errorf("malformed FileDescriptorProto: %v", err)
+	}
+	return fd, nil
+}
+
+// RegisteredDescriptors decodes a gzipped file descriptor and loads its dependencies from the proto registry
+func RegisteredDescriptors(gz []byte) (*Descriptors, error) {
+	fd, err := DecodeFileDescriptor(gz)
+	if err != nil {
+		return nil, err
+	}
+
+	d := NewDescriptors(fd)
+	pending := fd.Dependency
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+
+		dep := proto.FileDescriptor(name)
+		if dep == nil {
+			// well known types are resolved by name when they are not linked in
+			continue
+		}
+		depFd, err := DecodeFileDescriptor(dep)
+		if err != nil {
+			return nil, err
+		}
+		d.Add(depFd)
+		pending = append(pending, depFd.Dependency...)
+	}
+	return d, nil
+}
+
+// LoadDescriptors runs protoc on the given proto file and returns the descriptors of it and its imports
+func LoadDescriptors(path string, importPaths ...string) (*Descriptors, error) {
+	if _, err := exec.LookPath("protoc"); err != nil {
+		return nil, fmt.Errorf("protoc is not available: %s", err.Error())
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := ioutil.TempFile("", "descriptor")
+	if err != nil {
+		return nil, err
+	}
+	out.Close()
+	defer os.Remove(out.Name())
+
+	args := []string{"-I", filepath.Dir(path)}
+	for _, importPath := range importPaths {
+		args = append(args, "-I", importPath)
+	}
+	args = append(args, "--include_imports", "--descriptor_set_out="+out.Name(), path)
+	output, err := exec.Command("protoc", args...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("error executing protoc: %s %s", string(output), err.Error())
+	}
+
+	b, err := ioutil.ReadFile(out.Name())
+	if err != nil {
+		return nil, err
+	}
+	set := &descriptor.FileDescriptorSet{}
+	if err := proto.Unmarshal(b, set); err != nil {
+		return nil, err
+	}
+	return NewDescriptors(set.File...), nil
+}
@@ -0,0 +1,1073 @@
+package support
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// DynamicMessage is a message known only by its descriptor, it is converted between
+// the protobuf wire format and the proto3 JSON mapping with the original field names
+type DynamicMessage struct {
+	descriptors *Descriptors
+	message     *descriptor.DescriptorProto
+	name        string
+	// Value holds the JSON value of the message
+	Value interface{}
+}
+
+// NewDynamicMessage creates an empty message of the given type
+func (d *Descriptors) NewDynamicMessage(name string) (*DynamicMessage, error) {
+	message := d.Message(name)
+	if _, ok := wellKnownTypes[definitionName(name)]; message == nil && !ok {
+		return nil, fmt.Errorf("message [%s] not found", definitionName(name))
+	}
+	return &DynamicMessage{descriptors: d, message: message, name: definitionName(name)}, nil
+}
+
+// Reset clears the message
+func (m *DynamicMessage) Reset() {
+	m.Value = nil
+}
+
+// String returns the JSON value of the message
+func (m *DynamicMessage) String() string {
+	b, _ := json.Marshal(m.Value)
+	return string(b)
+}
+
+// ProtoMessage marks the message as proto.Message
+func (*DynamicMessage) ProtoMessage() {}
+
+// Marshal encodes the message in the protobuf wire format
+func (m *DynamicMessage) Marshal() ([]byte, error) {
+	return m.codec().encodeMessage(m.name, m.Value)
+}
+
+// Unmarshal decodes the message from the protobuf wire format
+func (m *DynamicMessage) Unmarshal(b []byte) error {
+	value, err := m.codec().decodeMessage(m.name, b)
+	if err != nil {
+		return err
+	}
+	m.Value = value
+	return nil
+}
+
+// MarshalJSON returns the JSON value of the message
+func (m *DynamicMessage) MarshalJSON() ([]byte, error) {
+	if m.Value == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(m.Value)
+}
+
+// UnmarshalJSON sets the JSON value of the message
+func (m *DynamicMessage) UnmarshalJSON(b []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+	m.Value = value
+	return nil
+}
+
+func (m *DynamicMessage) codec() dynamicCodec {
+	return dynamicCodec{descriptors: m.descriptors}
+}
+
+// wellKnownTypes describes the fields of the google.protobuf types with a special JSON mapping
+var wellKnownTypes = map[string][]*descriptor.FieldDescriptorProto{
+	"google.protobuf.Timestamp":   {wireField(1, descriptor.FieldDescriptorProto_TYPE_INT64), wireField(2, descriptor.FieldDescriptorProto_TYPE_INT32)},
+	"google.protobuf.Duration":    {wireField(1, descriptor.FieldDescriptorProto_TYPE_INT64), wireField(2, descriptor.FieldDescriptorProto_TYPE_INT32)},
+	"google.protobuf.DoubleValue": {wireField(1, descriptor.FieldDescriptorProto_TYPE_DOUBLE)},
+	"google.protobuf.FloatValue":  {wireField(1, descriptor.FieldDescriptorProto_TYPE_FLOAT)},
+	"google.protobuf.Int64Value":  {wireField(1, descriptor.FieldDescriptorProto_TYPE_INT64)},
+	"google.protobuf.UInt64Value": {wireField(1, descriptor.FieldDescriptorProto_TYPE_UINT64)},
+	"google.protobuf.Int32Value":  {wireField(1, descriptor.FieldDescriptorProto_TYPE_INT32)},
+	"google.protobuf.UInt32Value": {wireField(1, descriptor.FieldDescriptorProto_TYPE_UINT32)},
+	"google.protobuf.BoolValue":   {wireField(1, descriptor.FieldDescriptorProto_TYPE_BOOL)},
+	"google.protobuf.StringValue": {wireField(1, descriptor.FieldDescriptorProto_TYPE_STRING)},
+	"google.protobuf.BytesValue":  {wireField(1, descriptor.FieldDescriptorProto_TYPE_BYTES)},
+	"google.protobuf.Empty":       {},
+	"google.protobuf.FieldMask":   {wireField(1, descriptor.FieldDescriptorProto_TYPE_STRING)},
+	"google.protobuf.Struct":      {},
+	"google.protobuf.Value":       {},
+	"google.protobuf.ListValue":   {},
+	"google.protobuf.Any":         {wireField(1, descriptor.FieldDescriptorProto_TYPE_STRING), wireField(2, descriptor.FieldDescriptorProto_TYPE_BYTES)},
+}
+
+func wireField(number int32, typ descriptor.FieldDescriptorProto_Type) *descriptor.FieldDescriptorProto {
+	return &descriptor.FieldDescriptorProto{
+		Name:   proto.String(strconv.Itoa(int(number))),
+		Number: proto.Int32(number),
+		Type:   typ.Enum(),
+		Label:  descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+	}
+}
+
+const (
+	wireVarint     = 0
+	wireFixed64    = 1
+	wireBytes      = 2
+	wireStartGroup = 3
+	wireEndGroup   = 4
+	wireFixed32    = 5
+)
+
+// dynamicCodec converts JSON values of messages from and to the protobuf wire format
+type dynamicCodec struct {
+	descriptors *Descriptors
+}
+
+func (c dynamicCodec) encodeMessage(name string, value interface{}) ([]byte, error) {
+	buf := proto.NewBuffer(nil)
+	name = definitionName(name)
+	if _, ok := wellKnownTypes[name]; ok {
+		if err := c.encodeWellKnown(buf, name, value); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	message := c.descriptors.Message(name)
+	if message == nil {
+		return nil, fmt.Errorf("message [%s] not found", name)
+	}
+	if value == nil {
+		return nil, nil
+	}
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("message [%s] expects an object, got [%v]", name, value)
+	}
+
+	// the fields are written in the order of their numbers, like the generated messages do
+	ordered := append([]*descriptor.FieldDescriptorProto(nil), message.Field...)
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].GetNumber() < ordered[j].GetNumber()
+	})
+	known := make(map[string]bool)
+	oneofs := make(map[int32]string)
+	for _, field := range ordered {
+		known[field.GetName()], known[field.GetJsonName()] = true, true
+		v, ok := fields[field.GetName()]
+		if !ok {
+			v, ok = fields[field.GetJsonName()]
+		}
+		if !ok || v == nil {
+			continue
+		}
+		if field.OneofIndex != nil {
+			if other, ok := oneofs[field.GetOneofIndex()]; ok {
+				return nil, fmt.Errorf("fields [%s] and [%s] of message [%s] belong to the same oneof", other, field.GetName(), name)
+			}
+			oneofs[field.GetOneofIndex()] = field.GetName()
+		}
+		if err := c.encodeField(buf, field, v, c.packed(message, field)); err != nil {
+			return nil, err
+		}
+	}
+	for key := range fields {
+		if !known[key] {
+			return nil, fmt.Errorf("unknown field [%s] in message [%s]", key, name)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (c dynamicCodec) encodeField(buf *proto.Buffer, field *descriptor.FieldDescriptorProto, value interface{}, packed bool) error {
+	if entry := c.mapEntry(field); entry != nil {
+		entries, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("field [%s] expects an object, got [%v]", field.GetName(), value)
+		}
+		keys := make([]string, 0, len(entries))
+		for key := range entries {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			entryBuf := proto.NewBuffer(nil)
+			if err := c.encodeSingle(entryBuf, entry.Field[0], key); err != nil {
+				return err
+			}
+			if entries[key] != nil {
+				if err := c.encodeSingle(entryBuf, entry.Field[1], entries[key]); err != nil {
+					return err
+				}
+			}
+			buf.EncodeVarint(uint64(field.GetNumber())<<3 | wireBytes)
+			buf.EncodeRawBytes(entryBuf.Bytes())
+		}
+		return nil
+	}
+
+	if field.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return c.encodeSingle(buf, field, value)
+	}
+	values, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("field [%s] expects an array, got [%v]", field.GetName(), value)
+	}
+	if packed {
+		scalars := proto.NewBuffer(nil)
+		for _, v := range values {
+			if err := c.encodeScalar(scalars, field, v); err != nil {
+				return err
+			}
+		}
+		buf.EncodeVarint(uint64(field.GetNumber())<<3 | wireBytes)
+		return buf.EncodeRawBytes(scalars.Bytes())
+	}
+	for _, v := range values {
+		if err := c.encodeSingle(buf, field, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c dynamicCodec) encodeSingle(buf *proto.Buffer, field *descriptor.FieldDescriptorProto, value interface{}) error {
+	buf.EncodeVarint(uint64(field.GetNumber())<<3 | uint64(wireType(field.GetType())))
+	return c.encodeScalar(buf, field, value)
+}
+
+// encodeScalar encodes a value without its tag
+func (c dynamicCodec) encodeScalar(buf *proto.Buffer, field *descriptor.FieldDescriptorProto, value interface{}) error {
+	var err error
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_INT64:
+		var v int64
+		if v, err = jsonInt(value); err == nil {
+			buf.EncodeVarint(uint64(v))
+		}
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_UINT64:
+		var v uint64
+		if v, err = jsonUint(value); err == nil {
+			buf.EncodeVarint(v)
+		}
+	case descriptor.FieldDescriptorProto_TYPE_SINT32:
+		var v int64
+		if v, err = jsonInt(value); err == nil {
+			buf.EncodeZigzag32(uint64(v))
+		}
+	case descriptor.FieldDescriptorProto_TYPE_SINT64:
+		var v int64
+		if v, err = jsonInt(value); err == nil {
+			buf.EncodeZigzag64(uint64(v))
+		}
+	case descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		var v uint64
+		if v, err = jsonUint(value); err == nil {
+			buf.EncodeFixed32(v)
+		}
+	case descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		var v int64
+		if v, err = jsonInt(value); err == nil {
+			buf.EncodeFixed32(uint64(uint32(v)))
+		}
+	case descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		var v uint64
+		if v, err = jsonUint(value); err == nil {
+			buf.EncodeFixed64(v)
+		}
+	case descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		var v int64
+		if v, err = jsonInt(value); err == nil {
+			buf.EncodeFixed64(uint64(v))
+		}
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		var v float64
+		if v, err = jsonFloat(value); err == nil {
+			buf.EncodeFixed32(uint64(math.Float32bits(float32(v))))
+		}
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		var v float64
+		if v, err = jsonFloat(value); err == nil {
+			buf.EncodeFixed64(math.Float64bits(v))
+		}
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		var v bool
+		if v, err = jsonBool(value); err == nil {
+			if v {
+				buf.EncodeVarint(1)
+			} else {
+				buf.EncodeVarint(0)
+			}
+		}
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		var v int64
+		if v, err = c.enumNumber(field.GetTypeName(), value); err == nil {
+			buf.EncodeVarint(uint64(v))
+		}
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("field [%s] expects a string, got [%v]", field.GetName(), value)
+		}
+		buf.EncodeStringBytes(s)
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		var v []byte
+		if v, err = jsonBytes(value); err == nil {
+			buf.EncodeRawBytes(v)
+		}
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		var v []byte
+		if v, err = c.encodeMessage(field.GetTypeName(), value); err == nil {
+			buf.EncodeRawBytes(v)
+		}
+	case descriptor.FieldDescriptorProto_TYPE_GROUP:
+		// the fields of a group are delimited by the end group tag instead of a length
+		var v []byte
+		if v, err = c.encodeMessage(field.GetTypeName(), value); err == nil {
+			buf.SetBuf(append(buf.Bytes(), v...))
+			buf.EncodeVarint(uint64(field.GetNumber())<<3 | wireEndGroup)
+		}
+	default:
+		return fmt.Errorf("field [%s] has unsupported type [%s]", field.GetName(), field.GetType())
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value of field [%s]: %s", field.GetName(), err.Error())
+	}
+	return nil
+}
+
+func (c dynamicCodec) encodeWellKnown(buf *proto.Buffer, name string, value interface{}) error {
+	fields := wellKnownTypes[name]
+	switch name {
+	case "google.protobuf.Timestamp":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("timestamp expects a string, got [%v]", value)
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return err
+		}
+		return c.encodeFields(buf, fields, t.Unix(), int64(t.Nanosecond()))
+	case "google.protobuf.Duration":
+		s, ok := value.(string)
+		if !ok || !strings.HasSuffix(s, "s") {
+			return fmt.Errorf("duration expects a string in seconds, got [%v]", value)
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		return c.encodeFields(buf, fields, int64(d/time.Second), int64(d%time.Second))
+	case "google.protobuf.Empty":
+		return nil
+	case "google.protobuf.FieldMask":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("field mask expects a string, got [%v]", value)
+		}
+		for _, path := range strings.Split(s, ",") {
+			if path != "" {
+				c.encodeSingle(buf, fields[0], path)
+			}
+		}
+		return nil
+	case "google.protobuf.Struct":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("struct expects an object, got [%v]", value)
+		}
+		return c.encodeStruct(buf, object)
+	case "google.protobuf.ListValue":
+		list, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("list value expects an array, got [%v]", value)
+		}
+		return c.encodeList(buf, list)
+	case "google.protobuf.Value":
+		return c.encodeValue(buf, value)
+	case "google.protobuf.Any":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("any expects an object, got [%v]", value)
+		}
+		typeURL, _ := object["@type"].(string)
+		typeName := typeURL[strings.LastIndex(typeURL, "/")+1:]
+		var content interface{} = object
+		if _, ok := wellKnownTypes[typeName]; ok {
+			content = object["value"]
+		} else {
+			fields := make(map[string]interface{}, len(object))
+			for key, v := range object {
+				if key != "@type" {
+					fields[key] = v
+				}
+			}
+			content = fields
+		}
+		b, err := c.encodeMessage(typeName, content)
+		if err != nil {
+			return err
+		}
+		return c.encodeFields(buf, fields, typeURL, base64.StdEncoding.EncodeToString(b))
+	}
+	// wrappers
+	return c.encodeSingle(buf, fields[0], value)
+}
+
+func (c dynamicCodec) encodeFields(buf *proto.Buffer, fields []*descriptor.FieldDescriptorProto, values ...interface{}) error {
+	for i, value := range values {
+		if err := c.encodeSingle(buf, fields[i], value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c dynamicCodec) encodeStruct(buf *proto.Buffer, object map[string]interface{}) error {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := proto.NewBuffer(nil)
+		if err := c.encodeValue(value, object[key]); err != nil {
+			return err
+		}
+		entry := proto.NewBuffer(nil)
+		entry.EncodeVarint(1<<3 | wireBytes)
+		entry.EncodeStringBytes(key)
+		entry.EncodeVarint(2<<3 | wireBytes)
+		entry.EncodeRawBytes(value.Bytes())
+		buf.EncodeVarint(1<<3 | wireBytes)
+		buf.EncodeRawBytes(entry.Bytes())
+	}
+	return nil
+}
+
+func (c dynamicCodec) encodeList(buf *proto.Buffer, list []interface{}) error {
+	for _, v := range list {
+		value := proto.NewBuffer(nil)
+		if err := c.encodeValue(value, v); err != nil {
+			return err
+		}
+		buf.EncodeVarint(1<<3 | wireBytes)
+		buf.EncodeRawBytes(value.Bytes())
+	}
+	return nil
+}
+
+func (c dynamicCodec) encodeValue(buf *proto.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buf.EncodeVarint(1<<3 | wireVarint)
+		buf.EncodeVarint(0)
+	case json.Number, float64:
+		f, err := jsonFloat(v)
+		if err != nil {
+			return err
+		}
+		buf.EncodeVarint(2<<3 | wireFixed64)
+		buf.EncodeFixed64(math.Float64bits(f))
+	case string:
+		buf.EncodeVarint(3<<3 | wireBytes)
+		buf.EncodeStringBytes(v)
+	case bool:
+		buf.EncodeVarint(4<<3 | wireVarint)
+		if v {
+			buf.EncodeVarint(1)
+		} else {
+			buf.EncodeVarint(0)
+		}
+	case map[string]interface{}:
+		object := proto.NewBuffer(nil)
+		if err := c.encodeStruct(object, v); err != nil {
+			return err
+		}
+		buf.EncodeVarint(5<<3 | wireBytes)
+		buf.EncodeRawBytes(object.Bytes())
+	case []interface{}:
+		list := proto.NewBuffer(nil)
+		if err := c.encodeList(list, v); err != nil {
+			return err
+		}
+		buf.EncodeVarint(6<<3 | wireBytes)
+		buf.EncodeRawBytes(list.Bytes())
+	default:
+		return fmt.Errorf("unsupported value [%v]", value)
+	}
+	return nil
+}
+
+func (c dynamicCodec) mapEntry(field *descriptor.FieldDescriptorProto) *descriptor.DescriptorProto {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE || c.descriptors == nil {
+		return nil
+	}
+	entry := c.descriptors.Message(field.GetTypeName())
+	if entry != nil && entry.GetOptions().GetMapEntry() && len(entry.Field) == 2 {
+		return entry
+	}
+	return nil
+}
+
+func (c dynamicCodec) enumNumber(name string, value interface{}) (int64, error) {
+	if s, ok := value.(string); ok {
+		enum := c.descriptors.Enum(name)
+		if enum == nil {
+			return 0, fmt.Errorf("enum [%s] not found", definitionName(name))
+		}
+		for _, v := range enum.Value {
+			if v.GetName() == s {
+				return int64(v.GetNumber()), nil
+			}
+		}
+		return 0, fmt.Errorf("unknown value [%s] of enum [%s]", s, definitionName(name))
+	}
+	return jsonInt(value)
+}
+
+// packed tells whether the values of a repeated field are encoded in a single length delimited field, the scalars of
+// proto3 messages are packed unless the packed option disables it and those of proto2 messages when it enables it
+func (c dynamicCodec) packed(message *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto) bool {
+	if field.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED || !packable(field.GetType()) {
+		return false
+	}
+	if field.GetOptions() != nil && field.GetOptions().Packed != nil {
+		return field.GetOptions().GetPacked()
+	}
+	return !c.descriptors.proto2[message]
+}
+
+// packable tells whether the repeated fields of a type can be packed
+func packable(typ descriptor.FieldDescriptorProto_Type) bool {
+	switch wireType(typ) {
+	case wireVarint, wireFixed32, wireFixed64:
+		return true
+	}
+	return false
+}
+
+// wireType returns the wire type of a field type
+func wireType(typ descriptor.FieldDescriptorProto_Type) int {
+	switch typ {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE, descriptor.FieldDescriptorProto_TYPE_FIXED64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return wireFixed64
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT, descriptor.FieldDescriptorProto_TYPE_FIXED32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return wireFixed32
+	case descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES,
+		descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		return wireBytes
+	case descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return wireStartGroup
+	}
+	return wireVarint
+}
+
+func jsonInt(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return strconv.ParseInt(string(v), 10, 64)
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case float64:
+		return int64(v), nil
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	}
+	return 0, fmt.Errorf("expected an integer, got [%v]", value)
+}
+
+func jsonUint(value interface{}) (uint64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return strconv.ParseUint(string(v), 10, 64)
+	case string:
+		return strconv.ParseUint(v, 10, 64)
+	case float64:
+		return uint64(v), nil
+	case int:
+		return uint64(v), nil
+	case uint32:
+		return uint64(v), nil
+	case uint64:
+		return v, nil
+	}
+	return 0, fmt.Errorf("expected an unsigned integer, got [%v]", value)
+}
+
+func jsonFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.Float64()
+	case float64:
+		return v, nil
+	case string:
+		switch v {
+		case "NaN":
+			return math.NaN(), nil
+		case "Infinity":
+			return math.Inf(1), nil
+		case "-Infinity":
+			return math.Inf(-1), nil
+		}
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, fmt.Errorf("expected a number, got [%v]", value)
+}
+
+func jsonBool(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		return strconv.ParseBool(v)
+	}
+	return false, fmt.Errorf("expected a boolean, got [%v]", value)
+}
+
+func jsonBytes(value interface{}) ([]byte, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected a base64 string, got [%v]", value)
+	}
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	return base64.URLEncoding.DecodeString(s)
+}
+
+// wireReader reads fields of the protobuf wire format
+type wireReader struct {
+	b []byte
+}
+
+var errTruncated = errors.New("truncated message")
+
+func (r *wireReader) varint() (uint64, error) {
+	x, n := proto.DecodeVarint(r.b)
+	if n == 0 {
+		return 0, errTruncated
+	}
+	r.b = r.b[n:]
+	return x, nil
+}
+
+func (r *wireReader) fixed(size int) (uint64, error) {
+	if len(r.b) < size {
+		return 0, errTruncated
+	}
+	var x uint64
+	if size == 4 {
+		x = uint64(binary.LittleEndian.Uint32(r.b))
+	} else {
+		x = binary.LittleEndian.Uint64(r.b)
+	}
+	r.b = r.b[size:]
+	return x, nil
+}
+
+func (r *wireReader) bytes() ([]byte, error) {
+	n, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(r.b)) < n {
+		return nil, errTruncated
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b, nil
+}
+
+// group reads the fields of a group up to its end group tag and returns them without the tag
+func (r *wireReader) group(number int32) ([]byte, error) {
+	start := r.b
+	for {
+		rest := r.b
+		key, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		if key&7 == wireEndGroup {
+			if int32(key>>3) != number {
+				return nil, fmt.Errorf("group [%d] ended by the end group tag of field [%d]", number, key>>3)
+			}
+			return start[:len(start)-len(rest)], nil
+		}
+		r.b = rest
+		if _, _, _, _, err = r.next(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// next reads the next field, bytes fields and groups are returned as data and other fields as raw
+func (r *wireReader) next() (number int32, wire int, raw uint64, data []byte, err error) {
+	key, err := r.varint()
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+	number, wire = int32(key>>3), int(key&7)
+	switch wire {
+	case wireVarint:
+		raw, err = r.varint()
+	case wireFixed64:
+		raw, err = r.fixed(8)
+	case wireFixed32:
+		raw, err = r.fixed(4)
+	case wireBytes:
+		data, err = r.bytes()
+	case wireStartGroup:
+		data, err = r.group(number)
+	case wireEndGroup:
+		err = fmt.Errorf("end group tag of field [%d] without a group", number)
+	default:
+		err = fmt.Errorf("unsupported wire type [%d] of field [%d]", wire, number)
+	}
+	return number, wire, raw, data, err
+}
+
+func (c dynamicCodec) decodeMessage(name string, b []byte) (interface{}, error) {
+	name = definitionName(name)
+	if _, ok := wellKnownTypes[name]; ok {
+		return c.decodeWellKnown(name, b)
+	}
+	message := c.descriptors.Message(name)
+	if message == nil {
+		return nil, fmt.Errorf("message [%s] not found", name)
+	}
+
+	fields := make(map[int32]*descriptor.FieldDescriptorProto, len(message.Field))
+	for _, field := range message.Field {
+		fields[field.GetNumber()] = field
+	}
+	value := make(map[string]interface{})
+	r := &wireReader{b: b}
+	for len(r.b) > 0 {
+		number, wire, raw, data, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		field := fields[number]
+		if field == nil || !matchesWire(field, wire) {
+			// unknown fields are dropped, as are the fields with another wire type than the one of their type
+			continue
+		}
+		if err := c.decodeField(value, field, wire, raw, data); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+// matchesWire tells whether a field may be encoded with a wire type, repeated scalars either packed or not
+func matchesWire(field *descriptor.FieldDescriptorProto, wire int) bool {
+	if wire == wireType(field.GetType()) {
+		return true
+	}
+	return wire == wireBytes && field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED && packable(field.GetType())
+}
+
+func (c dynamicCodec) decodeField(value map[string]interface{}, field *descriptor.FieldDescriptorProto, wire int, raw uint64, data []byte) error {
+	name := field.GetName()
+	if entry := c.mapEntry(field); entry != nil {
+		entryValue, err := c.decodeMessage(field.GetTypeName(), data)
+		if err != nil {
+			return err
+		}
+		entries, ok := value[name].(map[string]interface{})
+		if !ok {
+			entries = make(map[string]interface{})
+			value[name] = entries
+		}
+		key := entryValue.(map[string]interface{})[entry.Field[0].GetName()]
+		if key == nil {
+			key = c.defaultValue(entry.Field[0])
+		}
+		v, ok := entryValue.(map[string]interface{})[entry.Field[1].GetName()]
+		if !ok {
+			v = c.defaultValue(entry.Field[1])
+		}
+		entries[fmt.Sprint(key)] = v
+		return nil
+	}
+
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		values, _ := value[name].([]interface{})
+		if wire == wireBytes && packable(field.GetType()) {
+			// packed scalars
+			r := &wireReader{b: data}
+			for len(r.b) > 0 {
+				var x uint64
+				var err error
+				switch wireType(field.GetType()) {
+				case wireFixed32:
+					x, err = r.fixed(4)
+				case wireFixed64:
+					x, err = r.fixed(8)
+				default:
+					x, err = r.varint()
+				}
+				if err != nil {
+					return err
+				}
+				v, err := c.decodeScalar(field, x, nil)
+				if err != nil {
+					return err
+				}
+				values = append(values, v)
+			}
+		} else {
+			v, err := c.decodeScalar(field, raw, data)
+			if err != nil {
+				return err
+			}
+			values = append(values, v)
+		}
+		value[name] = values
+		return nil
+	}
+
+	v, err := c.decodeScalar(field, raw, data)
+	if err != nil {
+		return err
+	}
+	value[name] = v
+	return nil
+}
+
+func (c dynamicCodec) decodeScalar(field *descriptor.FieldDescriptorProto, raw uint64, data []byte) (interface{}, error) {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return int32(raw), nil
+	case descriptor.FieldDescriptorProto_TYPE_SINT32:
+		return int32(uint32(raw>>1) ^ uint32(int32(raw&1)<<31>>31)), nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return uint32(raw), nil
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return strconv.FormatInt(int64(raw), 10), nil
+	case descriptor.FieldDescriptorProto_TYPE_SINT64:
+		return strconv.FormatInt(int64(raw>>1)^int64(raw)<<63>>63, 10), nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return strconv.FormatUint(raw, 10), nil
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return jsonFloatValue(float64(math.Float32frombits(uint32(raw)))), nil
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return jsonFloatValue(math.Float64frombits(raw)), nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return raw != 0, nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		if enum := c.descriptors.Enum(field.GetTypeName()); enum != nil {
+			for _, v := range enum.Value {
+				if v.GetNumber() == int32(raw) {
+					return v.GetName(), nil
+				}
+			}
+		}
+		return int32(raw), nil
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return string(data), nil
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return base64.StdEncoding.EncodeToString(data), nil
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return c.decodeMessage(field.GetTypeName(), data)
+	}
+	return nil, fmt.Errorf("field [%s] has unsupported type [%s]", field.GetName(), field.GetType())
+}
+
+// defaultValue returns the JSON value of a field which is not present
+func (c dynamicCodec) defaultValue(field *descriptor.FieldDescriptorProto) interface{} {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return ""
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return false
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return nil
+	}
+	v, _ := c.decodeScalar(field, 0, nil)
+	return v
+}
+
+func jsonFloatValue(f float64) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return f
+}
+
+func (c dynamicCodec) decodeWellKnown(name string, b []byte) (interface{}, error) {
+	switch name {
+	case "google.protobuf.Struct":
+		return c.decodeStruct(b)
+	case "google.protobuf.ListValue":
+		return c.decodeList(b)
+	case "google.protobuf.Value":
+		return c.decodeValue(b)
+	}
+
+	values := make(map[int32]interface{})
+	var paths []string
+	r := &wireReader{b: b}
+	for len(r.b) > 0 {
+		number, _, raw, data, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		fields := wellKnownTypes[name]
+		if number < 1 || int(number) > len(fields) {
+			continue
+		}
+		v, err := c.decodeScalar(fields[number-1], raw, data)
+		if err != nil {
+			return nil, err
+		}
+		if name == "google.protobuf.Any" && number == 2 {
+			v = data
+		}
+		values[number] = v
+		if name == "google.protobuf.FieldMask" {
+			paths = append(paths, v.(string))
+		}
+	}
+
+	switch name {
+	case "google.protobuf.Timestamp":
+		seconds, _ := jsonInt(values[1])
+		nanos, _ := values[2].(int32)
+		return time.Unix(seconds, int64(nanos)).UTC().Format(time.RFC3339Nano), nil
+	case "google.protobuf.Duration":
+		seconds, _ := jsonInt(values[1])
+		nanos, _ := values[2].(int32)
+		d := time.Duration(seconds)*time.Second + time.Duration(nanos)
+		return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s", nil
+	case "google.protobuf.Empty":
+		return map[string]interface{}{}, nil
+	case "google.protobuf.FieldMask":
+		return strings.Join(paths, ","), nil
+	case "google.protobuf.Any":
+		typeURL, _ := values[1].(string)
+		data, _ := values[2].([]byte)
+		typeName := typeURL[strings.LastIndex(typeURL, "/")+1:]
+		content, err := c.decodeMessage(typeName, data)
+		if err != nil {
+			return nil, err
+		}
+		object, ok := content.(map[string]interface{})
+		if _, wellKnown := wellKnownTypes[typeName]; wellKnown || !ok {
+			object = map[string]interface{}{"value": content}
+		}
+		object["@type"] = typeURL
+		return object, nil
+	}
+	// wrappers
+	if v, ok := values[1]; ok {
+		return v, nil
+	}
+	return c.defaultValue(wellKnownTypes[name][0]), nil
+}
+
+func (c dynamicCodec) decodeStruct(b []byte) (interface{}, error) {
+	object := make(map[string]interface{})
+	r := &wireReader{b: b}
+	for len(r.b) > 0 {
+		number, _, _, data, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if number != 1 {
+			continue
+		}
+		var key string
+		var value interface{}
+		entry := &wireReader{b: data}
+		for len(entry.b) > 0 {
+			number, _, _, data, err := entry.next()
+			if err != nil {
+				return nil, err
+			}
+			switch number {
+			case 1:
+				key = string(data)
+			case 2:
+				if value, err = c.decodeValue(data); err != nil {
+					return nil, err
+				}
+			}
+		}
+		object[key] = value
+	}
+	return object, nil
+}
+
+func (c dynamicCodec) decodeList(b []byte) (interface{}, error) {
+	list := []interface{}{}
+	r := &wireReader{b: b}
+	for len(r.b) > 0 {
+		number, _, _, data, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if number != 1 {
+			continue
+		}
+		value, err := c.decodeValue(data)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+	}
+	return list, nil
+}
+
+func (c dynamicCodec) decodeValue(b []byte) (interface{}, error) {
+	var value interface{}
+	r := &wireReader{b: b}
+	for len(r.b) > 0 {
+		number, _, raw, data, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		switch number {
+		case 1:
+			value = nil
+		case 2:
+			value = jsonFloatValue(math.Float64frombits(raw))
+		case 3:
+			value = string(data)
+		case 4:
+			value = raw != 0
+		case 5:
+			if value, err = c.decodeStruct(data); err != nil {
+				return nil, err
+			}
+		case 6:
+			if value, err = c.decodeList(data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return value, nil
+}
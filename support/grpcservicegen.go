@@ -1,6 +1,7 @@
 package support
 
 import (
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
@@ -23,6 +24,9 @@ var (
 	protoImpPath  string
 	appPath       string
 	cmdExePath    string
+	includePaths  []string
+	harness       bool
+	mode          string
 )
 
 // MethodInfoTree holds method information
@@ -47,6 +51,7 @@ type ProtoData struct {
 	ProtoName              string
 	Option                 string
 	Stream                 bool
+	DescriptorMessage      string
 }
 
 // AssignValues will set fullpath value
@@ -54,6 +59,22 @@ func AssignValues(path string) {
 	appPath = path
 }
 
+// AssignIncludePaths sets additional import paths of the proto file, e.g. the location of google/api/annotations.proto
+func AssignIncludePaths(paths ...string) {
+	includePaths = paths
+}
+
+// AssignHarness enables the generation of a fixture backed mock server and client helpers for every service
+func AssignHarness(enabled bool) {
+	harness = enabled
+}
+
+// AssignMode sets the gateway mode the support files are generated for, rest-to-grpc also creates the OpenAPI document
+// of the REST endpoints
+func AssignMode(value string) {
+	mode = value
+}
+
 // GenerateSupportFiles creates auto genearted code
 func GenerateSupportFiles(packageName, path string) error {
 
@@ -98,6 +119,38 @@ func GenerateSupportFiles(packageName, path string) error {
 		return err
 	}
 
+	if harness {
+		log.Println("creating harness files")
+		err = generateHarnessFiles(pdArr)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Println("creating json schema files")
+	descriptors, err := LoadDescriptors(path, includePaths...)
+	if err != nil {
+		// the descriptors are only needed by the schemas and the OpenAPI document, the support files are usable without
+		if mode == ModeRestToGRPC {
+			return fmt.Errorf("openapi file not created: %s", err.Error())
+		}
+		log.Println("json schema files not created:", err.Error())
+		log.Println("support files created")
+		return nil
+	}
+	err = generateJSONSchemaFiles(descriptors)
+	if err != nil {
+		return err
+	}
+
+	if mode == ModeRestToGRPC {
+		log.Println("creating openapi file")
+		err = generateOpenAPIFile(descriptors)
+		if err != nil {
+			return err
+		}
+	}
+
 	log.Println("support files created")
 	return nil
 }
@@ -115,8 +168,9 @@ import (
 	"golang.org/x/net/context"
 	{{end}}
 	"log"
-	"errors"
+	{{if .UnaryMethodInfo}}"errors"{{end}}
 	servInfo "github.com/project-flogo/grpc/trigger/grpc"
+	{{if or .ServerStreamMethodInfo .ClientStreamMethodInfo .BiDiStreamMethodInfo}}"github.com/golang/protobuf/proto"{{end}}
 	"github.com/golang/protobuf/jsonpb"
 	"google.golang.org/grpc"
 )
@@ -205,16 +259,20 @@ func (s *serviceImpl{{$protoName}}{{$serviceName}}{{$option}}) {{.MethodName}}(c
 
 {{- range .ServerStreamMethodInfo }}
 
-func (s *serviceImpl{{$protoName}}{{$serviceName}}{{$option}}) {{.MethodName}}(req *EmptyReq, sReq {{$serviceName}}_{{.MethodName}}Server) error {
+func (s *serviceImpl{{$protoName}}{{$serviceName}}{{$option}}) {{.MethodName}}(req *{{.MethodReqName}}, sReq {{$serviceName}}_{{.MethodName}}Server) error {
 
 	methodName := "{{.MethodName}}"
 	serviceName := "{{$serviceName}}"
 
+	writer := servInfo.NewServerStreamWriter(sReq, func() proto.Message { return &{{.MethodResName}}{} })
+	defer writer.Close()
+
 	grpcData := make(map[string]interface{})
 	grpcData["methodName"] = methodName
 	grpcData["serviceName"] = serviceName
 	grpcData["reqdata"] = req
 	grpcData["strmReq"] = sReq
+	grpcData["streamWriter"] = writer
 
 	_, data, err := s.trigger.CallHandler(grpcData)
 
@@ -223,11 +281,13 @@ func (s *serviceImpl{{$protoName}}{{$serviceName}}{{$option}}) {{.MethodName}}(r
 		return err
 	}
 
-	if data != nil && data.(map[string]interface{})["error"] != nil {
-		log.Println("error from end server: ", data.(map[string]interface{})["error"])
-		return errors.New(data.(map[string]interface{})["error"].(string))
+	if err = servInfo.ReplyError(data); err != nil {
+		log.Println("error from end server: ", err)
+		return err
 	}
-	return nil
+
+	// each element of a reply array is sent as a message of the stream
+	return writer.SendReply(data)
 }
 
 {{- end }}
@@ -239,10 +299,13 @@ func (s *serviceImpl{{$protoName}}{{$serviceName}}{{$option}}) {{.MethodName}}(c
 	methodName := "{{.MethodName}}"
 	serviceName := "{{$serviceName}}"
 
+	reader := servInfo.NewClientStreamReader(cReq, func() proto.Message { return &{{.MethodReqName}}{} })
+
 	grpcData := make(map[string]interface{})
 	grpcData["methodName"] = methodName
 	grpcData["serviceName"] = serviceName
 	grpcData["strmReq"] = cReq
+	grpcData["streamReader"] = reader
 
 	_, data, err := s.trigger.CallHandler(grpcData)
 
@@ -251,11 +314,21 @@ func (s *serviceImpl{{$protoName}}{{$serviceName}}{{$option}}) {{.MethodName}}(c
 		return err
 	}
 
-	if data != nil && data.(map[string]interface{})["error"] != nil {
-		log.Println("error from end server: ", data.(map[string]interface{})["error"])
-		return errors.New(data.(map[string]interface{})["error"].(string))
+	if err = servInfo.ReplyError(data); err != nil {
+		log.Println("error from end server: ", err)
+		return err
 	}
-	return nil
+
+	if !reader.Drained() {
+		// the stream was passed through and the response was sent by the handler
+		return nil
+	}
+	res, err := servInfo.ToMessage(data, &{{.MethodResName}}{})
+	if err != nil {
+		log.Println("error: ", err)
+		return err
+	}
+	return cReq.SendAndClose(res.(*{{.MethodResName}}))
 }
 
 {{- end }}
@@ -267,10 +340,16 @@ func (s *serviceImpl{{$protoName}}{{$serviceName}}{{$option}}) {{.MethodName}}(b
 	methodName := "{{.MethodName}}"
 	serviceName := "{{$serviceName}}"
 
+	reader := servInfo.NewClientStreamReader(bdReq, func() proto.Message { return &{{.MethodReqName}}{} })
+	writer := servInfo.NewServerStreamWriter(bdReq, func() proto.Message { return &{{.MethodResName}}{} })
+	defer writer.Close()
+
 	grpcData := make(map[string]interface{})
 	grpcData["methodName"] = methodName
 	grpcData["serviceName"] = serviceName
 	grpcData["strmReq"] = bdReq
+	grpcData["streamReader"] = reader
+	grpcData["streamWriter"] = writer
 
 	_, data, err := s.trigger.CallHandler(grpcData)
 
@@ -279,9 +358,9 @@ func (s *serviceImpl{{$protoName}}{{$serviceName}}{{$option}}) {{.MethodName}}(b
 		return err
 	}
 
-	if data != nil && data.(map[string]interface{})["error"] != nil {
-		log.Println("error from end server: ", data.(map[string]interface{})["error"])
-		return errors.New(data.(map[string]interface{})["error"].(string))
+	if err = servInfo.ReplyError(data); err != nil {
+		log.Println("error from end server: ", err)
+		return err
 	}
 	return nil
 }
@@ -292,6 +371,21 @@ func (s *serviceImpl{{$protoName}}{{$serviceName}}{{$option}}) ServiceInfo() *se
 	return s.serviceInfo
 }
 
+// FileDescriptor returns the compressed descriptor of the proto file
+func (s *serviceImpl{{$protoName}}{{$serviceName}}{{$option}}) FileDescriptor() []byte {
+	descriptor, _ := (&{{.DescriptorMessage}}{}).Descriptor()
+	return descriptor
+}
+
+// ServiceDesc returns the description of the service and its implementation for the trigger, whose stream handlers
+// are invoked in-process by the WebSocket bridges
+func (s *serviceImpl{{$protoName}}{{$serviceName}}{{$option}}) ServiceDesc(trigger *servInfo.Trigger) (*grpc.ServiceDesc, interface{}) {
+	return &_{{$serviceName}}_serviceDesc, &serviceImpl{{$protoName}}{{$serviceName}}{{$option}}{
+		trigger: trigger,
+		serviceInfo: serviceInfo{{$protoName}}{{$serviceName}}{{$option}},
+	}
+}
+
 `))
 
 //client template to create grpc service support file
@@ -301,14 +395,14 @@ var registryClientTemplate = template.Must(template.New("").Parse(`// This file
 
 	import (
 		"context"
-		{{if .UnaryMethodInfo}}
+		{{if or .UnaryMethodInfo .ClientStreamMethodInfo}}
 		"encoding/json"
-		"github.com/project-flogo/grpc/support"
 		{{end}}
+		"github.com/project-flogo/grpc/support"
 		"errors"
 		{{if .Stream}}
-		"strings"
 		"io"
+		"github.com/golang/protobuf/proto"
 		{{end}}
 		"log"
 		{{if .ServerStreamMethodInfo}}
@@ -384,12 +478,27 @@ var registryClientTemplate = template.Must(template.New("").Parse(`// This file
 	func {{.MethodName}}(client {{$serviceName}}Client, reqArr map[string]interface{}) map[string]interface{} {
 		resMap := make(map[string]interface{}, 1)
 
-		if reqArr["Mode"] != nil {
-			mode := reqArr["Mode"].(string)
-			if strings.Compare(mode,"rest-to-grpc") == 0 {
-				resMap["Error"] = errors.New("streaming operation is not allowed in rest to grpc case")
+		sReq, ok := reqArr["strmReq"].({{$serviceName}}_{{.MethodName}}Server)
+		if !ok {
+			// without a stream to pass through the request comes from flow data and the responses are collected
+			req := &{{.MethodReqName}}{}
+			support.AssignStructValues(req, reqArr)
+			ctx, cancel := support.StreamContext(reqArr)
+			defer cancel()
+			stream, err := client.{{.MethodName}}(ctx, req)
+			if err != nil {
+				log.Println("erorr while getting stream object for {{.MethodName}}:", err)
+				resMap["Error"] = err
+				return resMap
+			}
+			b, err := support.RecvMessages(ctx, stream, func() proto.Message { return &{{.MethodResName}}{} }, reqArr)
+			if err != nil {
+				log.Println("erorr occured in {{.MethodName}} Recv():", err)
+				resMap["Error"] = err
 				return resMap
 			}
+			resMap["Response"] = b
+			return resMap
 		}
 
 		req := &{{.MethodReqName}}{}
@@ -399,8 +508,6 @@ var registryClientTemplate = template.Must(template.New("").Parse(`// This file
 			return resMap
 		}
 
-		sReq := reqArr["strmReq"].({{$serviceName}}_{{.MethodName}}Server)
-
 		stream, err := client.{{.MethodName}}(context.Background(), req)
 		if err != nil {
 			log.Println("erorr while getting stream object for {{.MethodName}}:", err)
@@ -434,12 +541,35 @@ var registryClientTemplate = template.Must(template.New("").Parse(`// This file
 	func {{.MethodName}}(client {{$serviceName}}Client, reqArr map[string]interface{}) map[string]interface{} {
 		resMap := make(map[string]interface{}, 1)
 
-		if reqArr["Mode"] != nil {
-			mode := reqArr["Mode"].(string)
-			if strings.Compare(mode,"rest-to-grpc") == 0 {
-				resMap["Error"] = errors.New("streaming operation is not allowed in rest to grpc case")
+		cReq, ok := reqArr["strmReq"].({{$serviceName}}_{{.MethodName}}Server)
+		if !ok {
+			// without a stream to pass through the messages come from flow data
+			ctx, cancel := support.StreamContext(reqArr)
+			defer cancel()
+			stream, err := client.{{.MethodName}}(ctx)
+			if err != nil {
+				log.Println("erorr while getting stream object for {{.MethodName}}:", err)
+				resMap["Error"] = err
+				return resMap
+			}
+			if err = support.SendMessages(stream, reqArr, func() proto.Message { return &{{.MethodReqName}}{} }); err != nil {
+				log.Println("error while sending flow data with client stream:", err)
+				resMap["Error"] = err
+				return resMap
+			}
+			obj, err := stream.CloseAndRecv()
+			if err != nil {
+				log.Println("erorr occured in {{.MethodName}} CloseAndRecv():", err)
+				resMap["Error"] = err
+				return resMap
+			}
+			b, err := json.Marshal(obj)
+			if err != nil {
+				resMap["Error"] = err
 				return resMap
 			}
+			resMap["Response"] = b
+			return resMap
 		}
 
 		stream, err := client.{{.MethodName}}(context.Background())
@@ -449,8 +579,6 @@ var registryClientTemplate = template.Must(template.New("").Parse(`// This file
 			return resMap
 		}
 
-		cReq := reqArr["strmReq"].({{$serviceName}}_{{.MethodName}}Server)
-
 		for {
 			dataObj, err := cReq.Recv()
 			if err == io.EOF {
@@ -484,16 +612,29 @@ var registryClientTemplate = template.Must(template.New("").Parse(`// This file
 	func {{.MethodName}}(client {{$serviceName}}Client, reqArr map[string]interface{}) map[string]interface{} {
 		resMap := make(map[string]interface{}, 1)
 
-		if reqArr["Mode"] != nil {
-			mode := reqArr["Mode"].(string)
-			if strings.Compare(mode,"rest-to-grpc") == 0 {
-				resMap["Error"] = errors.New("streaming operation is not allowed in rest to grpc case")
+		bReq, ok := reqArr["strmReq"].({{$serviceName}}_{{.MethodName}}Server)
+		if !ok {
+			// without a stream to pass through the messages come from flow data and the responses are collected
+			ctx, cancel := support.StreamContext(reqArr)
+			defer cancel()
+			stream, err := client.{{.MethodName}}(ctx)
+			if err != nil {
+				log.Println("error while getting stream object for {{.MethodName}}:", err)
+				resMap["Error"] = err
+				return resMap
+			}
+			// a failed send ends the call, its status is returned by Recv
+			go support.SendMessages(stream, reqArr, func() proto.Message { return &{{.MethodReqName}}{} })
+			b, err := support.RecvMessages(ctx, stream, func() proto.Message { return &{{.MethodResName}}{} }, reqArr)
+			if err != nil {
+				log.Println("erorr occured in {{.MethodName}} stream Recv():", err)
+				resMap["Error"] = err
 				return resMap
 			}
+			resMap["Response"] = b
+			return resMap
 		}
 
-		bReq := reqArr["strmReq"].({{$serviceName}}_{{.MethodName}}Server)
-
 		stream, err := client.{{.MethodName}}(context.Background())
 		if err != nil {
 			log.Println("error while getting stream object for {{.MethodName}}:", err)
@@ -589,7 +730,12 @@ func generatePbFiles() error {
 		return err
 	}
 
-	err = Exec("protoc", "-I", protoPath, protoPath+string(filepath.Separator)+protoFileName, "--go_out=plugins=grpc:"+fullPath)
+	args := []string{"-I", protoPath}
+	for _, includePath := range includePaths {
+		args = append(args, "-I", includePath)
+	}
+	args = append(args, protoPath+string(filepath.Separator)+protoFileName, "--go_out=plugins=grpc:"+fullPath)
+	err = Exec("protoc", args...)
 	if err != nil {
 		_, statErr := os.Stat(fullPath)
 		if statErr == nil {
@@ -619,6 +765,9 @@ func arrangeProtoData(pdArr []ProtoData) []ProtoData {
 				servrStrm = true
 				protoData.Stream = true
 			}
+			if protoData.DescriptorMessage == "" {
+				protoData.DescriptorMessage = mthdInfo.MethodReqName
+			}
 			if !clientStrm && !servrStrm {
 				protoData.UnaryMethodInfo = append(protoData.UnaryMethodInfo, mthdInfo)
 			} else if clientStrm && servrStrm {
@@ -657,7 +806,7 @@ func getProtoData(packageName, protoPath string) ([]ProtoData, error) {
 		tempString = tempString[strings.Index(tempString, serviceName):]
 
 		//getting entire service declaration
-		temp := tempString[:strings.Index(tempString, "}")+1]
+		temp := serviceDeclaration(tempString)
 
 		regServiceName = strings.TrimSpace(temp[strings.Index(temp, serviceName)+len(serviceName) : strings.Index(temp, "{")])
 		regServiceName = generator.CamelCase(regServiceName)
@@ -670,8 +819,8 @@ func getProtoData(packageName, protoPath string) ([]ProtoData, error) {
 			methodInfo := MethodInfoTree{}
 			mthdDtls := strings.Split(mthd, "(")
 			methodInfo.MethodName = generator.CamelCase(strings.TrimSpace(mthdDtls[0]))
-			methodInfo.MethodReqName = generator.CamelCase(strings.TrimSpace(strings.Split(mthdDtls[1], ")")[0]))
-			methodInfo.MethodResName = generator.CamelCase(strings.TrimSpace(strings.Split(mthdDtls[2], ")")[0]))
+			methodInfo.MethodReqName = rpcTypeName(strings.Split(mthdDtls[1], ")")[0])
+			methodInfo.MethodResName = rpcTypeName(strings.Split(mthdDtls[2], ")")[0])
 			methodInfo.serviceName = regServiceName
 			methodInfoList = append(methodInfoList, methodInfo)
 		}
@@ -694,6 +843,42 @@ func getProtoData(packageName, protoPath string) ([]ProtoData, error) {
 	return ProtodataArr, nil
 }
 
+// serviceDeclaration returns the service declaration at the start of the given string,
+// the option blocks of its rpc methods are left out
+func serviceDeclaration(value string) string {
+	var declaration strings.Builder
+	depth := 0
+	for _, r := range value {
+		switch r {
+		case '{':
+			depth++
+			if depth == 1 {
+				declaration.WriteRune(r)
+			}
+		case '}':
+			depth--
+			if depth == 0 {
+				declaration.WriteRune(r)
+				return declaration.String()
+			}
+		default:
+			if depth <= 1 {
+				declaration.WriteRune(r)
+			}
+		}
+	}
+	return declaration.String()
+}
+
+// rpcTypeName camel cases a rpc request or response type while keeping the stream keyword
+func rpcTypeName(value string) string {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "stream ") {
+		return "stream " + generator.CamelCase(strings.TrimSpace(value[len("stream "):]))
+	}
+	return generator.CamelCase(value)
+}
+
 // generateServiceImplFile creates implementation files supported for grpc trigger and grpc service
 func generateServiceImplFile(pdArr []ProtoData, option string) error {
 	dirPath := filepath.Join(appPath)
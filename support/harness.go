@@ -0,0 +1,490 @@
+package support
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Fixture is a canned reply of a mocked method
+type Fixture struct {
+	// Request is matched against the request, only the given fields have to be equal
+	Request json.RawMessage `json:"request,omitempty"`
+	// Response is the reply of unary and client streaming methods
+	Response json.RawMessage `json:"response,omitempty"`
+	// Responses are the replies of server streaming and bidirectional streaming methods
+	Responses []json.RawMessage `json:"responses,omitempty"`
+	// Error is returned after the responses have been sent
+	Error *FixtureError `json:"error,omitempty"`
+}
+
+// FixtureError is the status returned by a fixture
+type FixtureError struct {
+	Code    codes.Code `json:"code"`
+	Message string     `json:"message"`
+}
+
+// Fixtures holds the fixtures of the mocked methods by method name
+type Fixtures map[string][]Fixture
+
+// LoadFixtures reads fixtures from a JSON file
+func LoadFixtures(path string) (Fixtures, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fixtures := Fixtures{}
+	if err := json.Unmarshal(b, &fixtures); err != nil {
+		return nil, fmt.Errorf("invalid fixtures file [%s]: %s", path, err.Error())
+	}
+	return fixtures, nil
+}
+
+// Match returns the first fixture of a method whose request matches the given message
+func (f Fixtures) Match(methodName string, msg proto.Message) (*Fixture, error) {
+	value, err := messageValue(msg)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	for i := range f[methodName] {
+		fixture := &f[methodName][i]
+		if len(fixture.Request) == 0 {
+			return fixture, nil
+		}
+		var request interface{}
+		if err := json.Unmarshal(fixture.Request, &request); err != nil {
+			return nil, status.Errorf(codes.Internal, "invalid request of fixture of method [%s]: %s", methodName, err.Error())
+		}
+		if matchValue(request, value) {
+			return fixture, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "no fixture of method [%s] matches the request", methodName)
+}
+
+// Replies returns the responses of the fixture, a single response is returned as a one element list
+func (f *Fixture) Replies() []json.RawMessage {
+	if len(f.Response) != 0 {
+		return append([]json.RawMessage{f.Response}, f.Responses...)
+	}
+	return f.Responses
+}
+
+// Err returns the status error of the fixture or nil
+func (f *Fixture) Err() error {
+	if f.Error == nil {
+		return nil
+	}
+	return status.Error(f.Error.Code, f.Error.Message)
+}
+
+// UnmarshalJSONMessage unmarshals a message following the proto3 JSON mapping
+func UnmarshalJSONMessage(data []byte, msg proto.Message) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return jsonpb.Unmarshal(bytes.NewReader(data), msg)
+}
+
+// MarshalJSONMessage marshals a message following the proto3 JSON mapping with the original field names
+func MarshalJSONMessage(msg proto.Message) (string, error) {
+	return (&jsonpb.Marshaler{OrigName: true}).MarshalToString(msg)
+}
+
+func messageValue(msg proto.Message) (interface{}, error) {
+	s, err := MarshalJSONMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	err = json.Unmarshal([]byte(s), &value)
+	return value, err
+}
+
+// matchValue reports whether the expected value is contained in the actual value,
+// scalars are compared by their text so that 64 bit integers given as numbers match their string encoding
+func matchValue(expected, actual interface{}) bool {
+	switch expected := expected.(type) {
+	case map[string]interface{}:
+		actual, ok := actual.(map[string]interface{})
+		if !ok {
+			return len(expected) == 0 && actual == nil
+		}
+		for key, value := range expected {
+			if !matchValue(value, actual[key]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		actual, ok := actual.([]interface{})
+		if !ok || len(actual) != len(expected) {
+			return len(expected) == 0 && actual == nil
+		}
+		for i := range expected {
+			if !matchValue(expected[i], actual[i]) {
+				return false
+			}
+		}
+		return true
+	case nil:
+		return actual == nil
+	}
+	if actual == nil {
+		// proto3 leaves out default values
+		return reflect.ValueOf(expected).IsZero()
+	}
+	return fmt.Sprint(expected) == fmt.Sprint(actual)
+}
+
+// generateHarnessFiles creates the mock server and client helpers of every service
+func generateHarnessFiles(pdArr []ProtoData) error {
+	for _, pd := range pdArr {
+		harnessFile := filepath.Join(appPath, strings.Split(protoFileName, ".")[0]+"."+pd.RegServiceName+".harness.go")
+		f, err := os.Create(harnessFile)
+		if err != nil {
+			return err
+		}
+		err = harnessTemplate.Execute(f, pd)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//harness template to create a fixture backed mock server and client helpers
+var harnessTemplate = template.Must(template.New("").Parse(`// This file provides a mock server and client helpers. This file was auto-generated by mashling at
+// {{ .Timestamp }}
+package {{.Package}}
+
+import (
+	"fmt"
+	{{if .Stream}}"io"{{end}}
+	"net"
+
+	"github.com/project-flogo/grpc/support"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+{{$serviceName := .RegServiceName}}
+// {{$serviceName}}Harness serves the methods of {{$serviceName}} from fixtures
+type {{$serviceName}}Harness struct {
+	Server   *grpc.Server
+	Fixtures support.Fixtures
+}
+
+// New{{$serviceName}}Harness creates a mock server of {{$serviceName}} serving the fixtures of the given JSON file
+func New{{$serviceName}}Harness(fixturesPath string, opts ...grpc.ServerOption) (*{{$serviceName}}Harness, error) {
+	fixtures, err := support.LoadFixtures(fixturesPath)
+	if err != nil {
+		return nil, err
+	}
+	harness := &{{$serviceName}}Harness{
+		Server:   grpc.NewServer(opts...),
+		Fixtures: fixtures,
+	}
+	Register{{$serviceName}}Server(harness.Server, harness)
+	return harness, nil
+}
+
+// Start listens on the given address and serves in the background, the listening address is returned
+func (h *{{$serviceName}}Harness) Start(addr string) (string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	go h.Server.Serve(listener)
+	return listener.Addr().String(), nil
+}
+
+// Stop stops the mock server
+func (h *{{$serviceName}}Harness) Stop() {
+	h.Server.Stop()
+}
+
+{{- range .UnaryMethodInfo }}
+
+func (h *{{$serviceName}}Harness) {{.MethodName}}(ctx context.Context, req *{{.MethodReqName}}) (*{{.MethodResName}}, error) {
+	fixture, err := h.Fixtures.Match("{{.MethodName}}", req)
+	if err != nil {
+		return nil, err
+	}
+	if err := fixture.Err(); err != nil {
+		return nil, err
+	}
+	res := &{{.MethodResName}}{}
+	if err := support.UnmarshalJSONMessage(fixture.Response, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+{{- end }}
+
+{{- range .ServerStreamMethodInfo }}
+
+func (h *{{$serviceName}}Harness) {{.MethodName}}(req *{{.MethodReqName}}, stream {{$serviceName}}_{{.MethodName}}Server) error {
+	fixture, err := h.Fixtures.Match("{{.MethodName}}", req)
+	if err != nil {
+		return err
+	}
+	for _, data := range fixture.Replies() {
+		res := &{{.MethodResName}}{}
+		if err := support.UnmarshalJSONMessage(data, res); err != nil {
+			return err
+		}
+		if err := stream.Send(res); err != nil {
+			return err
+		}
+	}
+	return fixture.Err()
+}
+
+{{- end }}
+
+{{- range .ClientStreamMethodInfo }}
+
+func (h *{{$serviceName}}Harness) {{.MethodName}}(stream {{$serviceName}}_{{.MethodName}}Server) error {
+	var first *{{.MethodReqName}}
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if first == nil {
+			first = req
+		}
+	}
+	if first == nil {
+		first = &{{.MethodReqName}}{}
+	}
+
+	// the first message of the stream selects the fixture
+	fixture, err := h.Fixtures.Match("{{.MethodName}}", first)
+	if err != nil {
+		return err
+	}
+	if err := fixture.Err(); err != nil {
+		return err
+	}
+	res := &{{.MethodResName}}{}
+	if err := support.UnmarshalJSONMessage(fixture.Response, res); err != nil {
+		return err
+	}
+	return stream.SendAndClose(res)
+}
+
+{{- end }}
+
+{{- range .BiDiStreamMethodInfo }}
+
+func (h *{{$serviceName}}Harness) {{.MethodName}}(stream {{$serviceName}}_{{.MethodName}}Server) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fixture, err := h.Fixtures.Match("{{.MethodName}}", req)
+		if err != nil {
+			return err
+		}
+		for _, data := range fixture.Replies() {
+			res := &{{.MethodResName}}{}
+			if err := support.UnmarshalJSONMessage(data, res); err != nil {
+				return err
+			}
+			if err := stream.Send(res); err != nil {
+				return err
+			}
+		}
+		if err := fixture.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+{{- end }}
+
+// {{$serviceName}}HarnessClient calls the methods of {{$serviceName}} with requests and responses in JSON
+type {{$serviceName}}HarnessClient struct {
+	client {{$serviceName}}Client
+}
+
+// New{{$serviceName}}HarnessClient creates a client helper of {{$serviceName}} on the given connection
+func New{{$serviceName}}HarnessClient(conn *grpc.ClientConn) *{{$serviceName}}HarnessClient {
+	return &{{$serviceName}}HarnessClient{client: New{{$serviceName}}Client(conn)}
+}
+
+// Call invokes a method by name, unary and server streaming methods take exactly one request
+func (c *{{$serviceName}}HarnessClient) Call(ctx context.Context, methodName string, requests ...string) ([]string, error) {
+	switch methodName {
+	{{- range .UnaryMethodInfo }}
+	case "{{.MethodName}}":
+		if len(requests) != 1 {
+			return nil, fmt.Errorf("method [{{.MethodName}}] takes one request")
+		}
+		res, err := c.{{.MethodName}}(ctx, requests[0])
+		if err != nil {
+			return nil, err
+		}
+		return []string{res}, nil
+	{{- end }}
+	{{- range .ServerStreamMethodInfo }}
+	case "{{.MethodName}}":
+		if len(requests) != 1 {
+			return nil, fmt.Errorf("method [{{.MethodName}}] takes one request")
+		}
+		return c.{{.MethodName}}(ctx, requests[0])
+	{{- end }}
+	{{- range .ClientStreamMethodInfo }}
+	case "{{.MethodName}}":
+		res, err := c.{{.MethodName}}(ctx, requests)
+		if err != nil {
+			return nil, err
+		}
+		return []string{res}, nil
+	{{- end }}
+	{{- range .BiDiStreamMethodInfo }}
+	case "{{.MethodName}}":
+		return c.{{.MethodName}}(ctx, requests)
+	{{- end }}
+	}
+	return nil, fmt.Errorf("method [%s] not available in service [{{$serviceName}}]", methodName)
+}
+
+{{- range .UnaryMethodInfo }}
+
+// {{.MethodName}} calls {{.MethodName}} with a JSON request and returns the JSON response
+func (c *{{$serviceName}}HarnessClient) {{.MethodName}}(ctx context.Context, request string) (string, error) {
+	req := &{{.MethodReqName}}{}
+	if err := support.UnmarshalJSONMessage([]byte(request), req); err != nil {
+		return "", err
+	}
+	res, err := c.client.{{.MethodName}}(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	return support.MarshalJSONMessage(res)
+}
+
+{{- end }}
+
+{{- range .ServerStreamMethodInfo }}
+
+// {{.MethodName}} calls {{.MethodName}} with a JSON request and returns the streamed JSON responses
+func (c *{{$serviceName}}HarnessClient) {{.MethodName}}(ctx context.Context, request string) ([]string, error) {
+	req := &{{.MethodReqName}}{}
+	if err := support.UnmarshalJSONMessage([]byte(request), req); err != nil {
+		return nil, err
+	}
+	stream, err := c.client.{{.MethodName}}(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	var responses []string
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return responses, nil
+		}
+		if err != nil {
+			return responses, err
+		}
+		data, err := support.MarshalJSONMessage(res)
+		if err != nil {
+			return responses, err
+		}
+		responses = append(responses, data)
+	}
+}
+
+{{- end }}
+
+{{- range .ClientStreamMethodInfo }}
+
+// {{.MethodName}} streams JSON requests to {{.MethodName}} and returns the JSON response
+func (c *{{$serviceName}}HarnessClient) {{.MethodName}}(ctx context.Context, requests []string) (string, error) {
+	stream, err := c.client.{{.MethodName}}(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, request := range requests {
+		req := &{{.MethodReqName}}{}
+		if err := support.UnmarshalJSONMessage([]byte(request), req); err != nil {
+			return "", err
+		}
+		if err := stream.Send(req); err != nil {
+			return "", err
+		}
+	}
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		return "", err
+	}
+	return support.MarshalJSONMessage(res)
+}
+
+{{- end }}
+
+{{- range .BiDiStreamMethodInfo }}
+
+// {{.MethodName}} streams JSON requests to {{.MethodName}} and returns the streamed JSON responses
+func (c *{{$serviceName}}HarnessClient) {{.MethodName}}(ctx context.Context, requests []string) ([]string, error) {
+	stream, err := c.client.{{.MethodName}}(ctx)
+	if err != nil {
+		return nil, err
+	}
+	done := make(chan error, 1)
+	go func() {
+		for _, request := range requests {
+			req := &{{.MethodReqName}}{}
+			if err := support.UnmarshalJSONMessage([]byte(request), req); err != nil {
+				done <- err
+				return
+			}
+			if err := stream.Send(req); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- stream.CloseSend()
+	}()
+
+	var responses []string
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return responses, <-done
+		}
+		if err != nil {
+			return responses, err
+		}
+		data, err := support.MarshalJSONMessage(res)
+		if err != nil {
+			return responses, err
+		}
+		responses = append(responses, data)
+	}
+}
+
+{{- end }}
+`))
@@ -0,0 +1,342 @@
+package support
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+const jsonSchemaVersion = "http://json-schema.org/draft-07/schema#"
+
+// schemaBuilder creates schemas of messages following the proto3 JSON mapping with original field names
+type schemaBuilder struct {
+	descriptors *Descriptors
+	refPrefix   string
+	openAPI     bool
+	definitions map[string]interface{}
+	pending     []string
+}
+
+func newSchemaBuilder(d *Descriptors, refPrefix string, openAPI bool) *schemaBuilder {
+	return &schemaBuilder{
+		descriptors: d,
+		refPrefix:   refPrefix,
+		openAPI:     openAPI,
+		definitions: make(map[string]interface{}),
+	}
+}
+
+// MessageSchema returns a JSON Schema of the given message, referenced messages are placed in definitions
+func (d *Descriptors) MessageSchema(name string) (map[string]interface{}, error) {
+	b := newSchemaBuilder(d, "#/definitions/", false)
+	schema, err := b.messageSchema(name)
+	if err != nil {
+		return nil, err
+	}
+	if err = b.resolve(); err != nil {
+		return nil, err
+	}
+
+	schema["$schema"] = jsonSchemaVersion
+	if len(b.definitions) > 0 {
+		schema["definitions"] = b.definitions
+	}
+	return schema, nil
+}
+
+// MethodSchemas returns the JSON Schemas of the request and response messages of a method
+func (d *Descriptors) MethodSchemas(serviceName, methodName string) (map[string]interface{}, map[string]interface{}, error) {
+	method := d.Method(serviceName, methodName)
+	if method == nil {
+		return nil, nil, fmt.Errorf("method [%s] not found in service [%s]", methodName, serviceName)
+	}
+	input, err := d.MessageSchema(method.GetInputType())
+	if err != nil {
+		return nil, nil, err
+	}
+	output, err := d.MessageSchema(method.GetOutputType())
+	if err != nil {
+		return nil, nil, err
+	}
+	return input, output, nil
+}
+
+// resolve builds the definitions of all referenced messages
+func (b *schemaBuilder) resolve() error {
+	for len(b.pending) > 0 {
+		name := b.pending[0]
+		b.pending = b.pending[1:]
+		schema, err := b.messageSchema(name)
+		if err != nil {
+			return err
+		}
+		b.definitions[definitionName(name)] = schema
+	}
+	return nil
+}
+
+// ref returns a reference to the definition of a message and queues it to be built
+func (b *schemaBuilder) ref(name string) map[string]interface{} {
+	key := definitionName(name)
+	if _, ok := b.definitions[key]; !ok {
+		b.definitions[key] = nil
+		b.pending = append(b.pending, name)
+	}
+	return map[string]interface{}{"$ref": b.refPrefix + key}
+}
+
+func definitionName(name string) string {
+	return strings.TrimPrefix(name, ".")
+}
+
+func (b *schemaBuilder) messageSchema(name string) (map[string]interface{}, error) {
+	message := b.descriptors.Message(name)
+	if message == nil {
+		return nil, fmt.Errorf("message [%s] not found", definitionName(name))
+	}
+
+	properties := make(map[string]interface{})
+	oneofs := make(map[int32][]string)
+	for _, field := range message.Field {
+		schema, err := b.fieldSchema(field)
+		if err != nil {
+			return nil, err
+		}
+		properties[field.GetName()] = schema
+		if field.OneofIndex != nil {
+			oneofs[field.GetOneofIndex()] = append(oneofs[field.GetOneofIndex()], field.GetName())
+		}
+	}
+
+	schema := map[string]interface{}{
+		"title":      message.GetName(),
+		"type":       "object",
+		"properties": properties,
+	}
+
+	var constraints []interface{}
+	for index, decl := range message.OneofDecl {
+		fields := oneofs[int32(index)]
+		if len(fields) == 1 && decl.GetName() == "_"+fields[0] {
+			// synthetic oneof of a proto3 optional field
+			continue
+		}
+		constraints = append(constraints, map[string]interface{}{"oneOf": oneOfBranches(fields)})
+	}
+	if len(constraints) == 1 {
+		schema["oneOf"] = constraints[0].(map[string]interface{})["oneOf"]
+	} else if len(constraints) > 1 {
+		schema["allOf"] = constraints
+	}
+	return schema, nil
+}
+
+// oneOfBranches allows exactly one or none of the fields of a oneof to be set
+func oneOfBranches(fields []string) []interface{} {
+	var branches, required []interface{}
+	for _, field := range fields {
+		branch := map[string]interface{}{"required": []string{field}}
+		branches = append(branches, branch)
+		required = append(required, branch)
+	}
+	return append(branches, map[string]interface{}{
+		"not": map[string]interface{}{"anyOf": required},
+	})
+}
+
+func (b *schemaBuilder) fieldSchema(field *descriptor.FieldDescriptorProto) (map[string]interface{}, error) {
+	if field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		entry := b.descriptors.Message(field.GetTypeName())
+		if entry != nil && entry.GetOptions().GetMapEntry() {
+			value, err := b.fieldSchema(entry.Field[1])
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": value,
+			}, nil
+		}
+	}
+
+	schema, err := b.typeSchema(field)
+	if err != nil {
+		return nil, err
+	}
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return map[string]interface{}{
+			"type":  "array",
+			"items": schema,
+		}, nil
+	}
+	return schema, nil
+}
+
+func (b *schemaBuilder) typeSchema(field *descriptor.FieldDescriptorProto) (map[string]interface{}, error) {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE, descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return b.scalar("number", "")
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return b.scalar("integer", "int32")
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		// the values above the range of int32 fit in int64
+		schema, _ := b.scalar("integer", "int64")
+		schema["minimum"] = 0
+		return schema, nil
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64, descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return b.int64Schema(), nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return b.scalar("boolean", "")
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return b.scalar("string", "")
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return b.bytesSchema(), nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return b.enumSchema(field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		if schema := b.wellKnownSchema(field.GetTypeName()); schema != nil {
+			return schema, nil
+		}
+		if b.descriptors.Message(field.GetTypeName()) == nil {
+			return nil, fmt.Errorf("message [%s] of field [%s] not found", definitionName(field.GetTypeName()), field.GetName())
+		}
+		return b.ref(field.GetTypeName()), nil
+	}
+	return nil, fmt.Errorf("field [%s] has unsupported type [%s]", field.GetName(), field.GetType())
+}
+
+func (b *schemaBuilder) scalar(typ, format string) (map[string]interface{}, error) {
+	schema := map[string]interface{}{"type": typ}
+	if b.openAPI && format != "" {
+		schema["format"] = format
+	}
+	return schema, nil
+}
+
+// int64Schema accepts numbers as well as the strings used by the proto3 JSON mapping
+func (b *schemaBuilder) int64Schema() map[string]interface{} {
+	if b.openAPI {
+		return map[string]interface{}{"type": "string", "format": "int64"}
+	}
+	return map[string]interface{}{"type": []string{"integer", "string"}}
+}
+
+func (b *schemaBuilder) bytesSchema() map[string]interface{} {
+	if b.openAPI {
+		return map[string]interface{}{"type": "string", "format": "byte"}
+	}
+	return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+}
+
+// enumSchema accepts the value names and, outside of OpenAPI, the value numbers
+func (b *schemaBuilder) enumSchema(name string) (map[string]interface{}, error) {
+	enum := b.descriptors.Enum(name)
+	if enum == nil {
+		return nil, fmt.Errorf("enum [%s] not found", definitionName(name))
+	}
+	var names, numbers []interface{}
+	for _, value := range enum.Value {
+		names = append(names, value.GetName())
+		numbers = append(numbers, value.GetNumber())
+	}
+	if b.openAPI {
+		return map[string]interface{}{"type": "string", "enum": names}, nil
+	}
+	return map[string]interface{}{
+		"type": []string{"string", "integer"},
+		"enum": append(names, numbers...),
+	}, nil
+}
+
+// wellKnownSchema returns the schema of a google.protobuf well known type
+func (b *schemaBuilder) wellKnownSchema(name string) map[string]interface{} {
+	switch definitionName(name) {
+	case "google.protobuf.Timestamp":
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case "google.protobuf.Duration":
+		return map[string]interface{}{"type": "string", "pattern": `^-?[0-9]+(\.[0-9]+)?s$`}
+	case "google.protobuf.FieldMask":
+		return map[string]interface{}{"type": "string"}
+	case "google.protobuf.Struct", "google.protobuf.Empty":
+		return map[string]interface{}{"type": "object"}
+	case "google.protobuf.Any":
+		return map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"@type": map[string]interface{}{"type": "string"},
+			},
+		}
+	case "google.protobuf.Value":
+		return map[string]interface{}{}
+	case "google.protobuf.ListValue":
+		return map[string]interface{}{"type": "array", "items": map[string]interface{}{}}
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue":
+		schema, _ := b.scalar("number", "")
+		return schema
+	case "google.protobuf.Int32Value", "google.protobuf.UInt32Value":
+		schema, _ := b.scalar("integer", "int32")
+		return schema
+	case "google.protobuf.Int64Value", "google.protobuf.UInt64Value":
+		return b.int64Schema()
+	case "google.protobuf.BoolValue":
+		schema, _ := b.scalar("boolean", "")
+		return schema
+	case "google.protobuf.StringValue":
+		schema, _ := b.scalar("string", "")
+		return schema
+	case "google.protobuf.BytesValue":
+		return b.bytesSchema()
+	}
+	return nil
+}
+
+// generateJSONSchemaFiles writes the request and response schemas of every method of the proto file
+func generateJSONSchemaFiles(d *Descriptors) error {
+	for _, file := range d.Files {
+		if file.GetName() != protoFileName {
+			continue
+		}
+		for _, service := range file.Service {
+			serviceName := service.GetName()
+			if file.GetPackage() != "" {
+				serviceName = file.GetPackage() + "." + serviceName
+			}
+			for _, method := range service.Method {
+				input, output, err := d.MethodSchemas(serviceName, method.GetName())
+				if err != nil {
+					return err
+				}
+				prefix := strings.Split(protoFileName, ".")[0] + "." + service.GetName() + "." + method.GetName()
+				err = writeJSONFile(filepath.Join(appPath, prefix+".request.schema.json"), input)
+				if err != nil {
+					return err
+				}
+				err = writeJSONFile(filepath.Join(appPath, prefix+".response.schema.json"), output)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func writeJSONFile(path string, value interface{}) error {
+	b, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(path, b, 0644)
+	if err != nil {
+		log.Println("error: ", err)
+	}
+	return err
+}
@@ -0,0 +1,389 @@
+package support
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+const openAPIVersion = "3.0.1"
+
+// HTTPBinding is a REST exposure of a rpc method
+type HTTPBinding struct {
+	// Verb is the upper case HTTP method
+	Verb string
+	// Path is the path template with variables in the {field} form
+	Path string
+	// PathParams are the fields bound to the path variables
+	PathParams []string
+	// Template is the path template of the option, variables may match several segments with {field=pattern}
+	Template string
+	// Body is "*" when the whole request message is the body, the name of the field bound to the body or empty
+	Body string
+	// ResponseBody is the name of the response field sent as the body, the whole response message when empty
+	ResponseBody string
+	// Annotated is true when the binding comes from a google.api.http option
+	Annotated bool
+}
+
+// HTTPBindings returns the bindings of the google.api.http option of a method,
+// methods without the option are bound to POST /{service}/{method}
+func HTTPBindings(serviceName string, method *descriptor.MethodDescriptorProto) []HTTPBinding {
+	if method.GetOptions() != nil && proto.HasExtension(method.GetOptions(), annotations.E_Http) {
+		ext, err := proto.GetExtension(method.GetOptions(), annotations.E_Http)
+		if rule, ok := ext.(*annotations.HttpRule); err == nil && ok {
+			var bindings []HTTPBinding
+			if binding, ok := httpRuleBinding(rule); ok {
+				bindings = append(bindings, binding)
+			}
+			for _, additional := range rule.GetAdditionalBindings() {
+				if binding, ok := httpRuleBinding(additional); ok {
+					bindings = append(bindings, binding)
+				}
+			}
+			if len(bindings) > 0 {
+				return bindings
+			}
+		}
+	}
+
+	if index := strings.LastIndex(serviceName, "."); index >= 0 {
+		serviceName = serviceName[index+1:]
+	}
+	path := "/" + serviceName + "/" + method.GetName()
+	return []HTTPBinding{{
+		Verb:     "POST",
+		Path:     path,
+		Template: path,
+		Body:     "*",
+	}}
+}
+
+func httpRuleBinding(rule *annotations.HttpRule) (HTTPBinding, bool) {
+	binding := HTTPBinding{Body: rule.GetBody(), ResponseBody: rule.GetResponseBody(), Annotated: true}
+	var path string
+	switch pattern := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		binding.Verb, path = "GET", pattern.Get
+	case *annotations.HttpRule_Put:
+		binding.Verb, path = "PUT", pattern.Put
+	case *annotations.HttpRule_Post:
+		binding.Verb, path = "POST", pattern.Post
+	case *annotations.HttpRule_Delete:
+		binding.Verb, path = "DELETE", pattern.Delete
+	case *annotations.HttpRule_Patch:
+		binding.Verb, path = "PATCH", pattern.Patch
+	case *annotations.HttpRule_Custom:
+		binding.Verb, path = strings.ToUpper(pattern.Custom.GetKind()), pattern.Custom.GetPath()
+	default:
+		return binding, false
+	}
+	binding.Template = path
+	binding.Path, binding.PathParams = parsePathTemplate(path)
+	return binding, true
+}
+
+// parsePathTemplate turns the {field=pattern} variables of a path template into {field}
+func parsePathTemplate(template string) (string, []string) {
+	var path strings.Builder
+	var params []string
+	for {
+		start := strings.Index(template, "{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(template[start:], "}")
+		if end < 0 {
+			break
+		}
+		variable := template[start+1 : start+end]
+		if index := strings.Index(variable, "="); index >= 0 {
+			variable = variable[:index]
+		}
+		variable = strings.TrimSpace(variable)
+		params = append(params, variable)
+		path.WriteString(template[:start] + "{" + variable + "}")
+		template = template[start+end+1:]
+	}
+	path.WriteString(template)
+	return path.String(), params
+}
+
+// OpenAPI returns an OpenAPI 3 document of the REST exposures of the services declared in the given proto file,
+// streaming methods are not exposed over REST and are left out
+func (d *Descriptors) OpenAPI(fileName string) (map[string]interface{}, error) {
+	b := newSchemaBuilder(d, "#/components/schemas/", true)
+	paths := make(map[string]interface{})
+
+	var file *descriptor.FileDescriptorProto
+	for _, f := range d.Files {
+		if f.GetName() == fileName {
+			file = f
+		}
+	}
+	if file == nil {
+		return nil, fmt.Errorf("proto file [%s] not found", fileName)
+	}
+
+	for _, service := range file.Service {
+		serviceName := service.GetName()
+		if file.GetPackage() != "" {
+			serviceName = file.GetPackage() + "." + serviceName
+		}
+		for _, method := range service.Method {
+			if method.GetClientStreaming() || method.GetServerStreaming() {
+				continue
+			}
+			bindings := HTTPBindings(serviceName, method)
+			for index, binding := range bindings {
+				operation, err := b.operation(service.GetName(), method, binding)
+				if err != nil {
+					return nil, err
+				}
+				operation["operationId"] = service.GetName() + "_" + method.GetName()
+				if index > 0 {
+					operation["operationId"] = fmt.Sprintf("%s_%s%d", service.GetName(), method.GetName(), index)
+				}
+
+				item, ok := paths[binding.Path].(map[string]interface{})
+				if !ok {
+					item = make(map[string]interface{})
+					paths[binding.Path] = item
+				}
+				item[strings.ToLower(binding.Verb)] = operation
+			}
+		}
+	}
+
+	if err := b.resolve(); err != nil {
+		return nil, err
+	}
+	b.definitions["ErrorResponse"] = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"error": map[string]interface{}{"type": "string"},
+			"details": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"error": map[string]interface{}{"type": "string"},
+				},
+			},
+		},
+	}
+
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":   strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName)),
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": b.definitions,
+		},
+	}, nil
+}
+
+// operation describes a binding the way the rest-to-grpc mode applies path, query and body values to the request
+func (b *schemaBuilder) operation(serviceName string, method *descriptor.MethodDescriptorProto, binding HTTPBinding) (map[string]interface{}, error) {
+	input := b.descriptors.Message(method.GetInputType())
+	if input == nil {
+		return nil, fmt.Errorf("message [%s] not found", definitionName(method.GetInputType()))
+	}
+
+	var parameters []interface{}
+	bound := make(map[string]bool)
+	for _, param := range binding.PathParams {
+		field := b.fieldByPath(input, param)
+		if field == nil {
+			return nil, fmt.Errorf("path variable [%s] of method [%s] is not a field of [%s]", param, method.GetName(), input.GetName())
+		}
+		schema, err := b.fieldSchema(field)
+		if err != nil {
+			return nil, err
+		}
+		parameters = append(parameters, map[string]interface{}{
+			"name":     param,
+			"in":       "path",
+			"required": true,
+			"schema":   schema,
+		})
+		bound[param] = true
+	}
+
+	// query parameters are applied to the scalar fields left unbound, nested ones at their dotted path
+	if binding.Body != "*" || !binding.Annotated {
+		for _, param := range b.descriptors.queryParameters(input, binding, bound) {
+			schema, err := b.fieldSchema(param.field)
+			if err != nil {
+				return nil, err
+			}
+			parameters = append(parameters, map[string]interface{}{
+				"name":   param.path,
+				"in":     "query",
+				"schema": schema,
+			})
+		}
+	}
+
+	operation := map[string]interface{}{
+		"tags":    []string{serviceName},
+		"summary": method.GetName(),
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "A successful response",
+				"content":     jsonContent(b.messageRef(method.GetOutputType())),
+			},
+			"default": map[string]interface{}{
+				"description": "An error response",
+				"content":     jsonContent(map[string]interface{}{"$ref": b.refPrefix + "ErrorResponse"}),
+			},
+		},
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+
+	switch binding.Body {
+	case "":
+	case "*":
+		operation["requestBody"] = map[string]interface{}{
+			"content": jsonContent(b.messageRef(method.GetInputType())),
+		}
+	default:
+		field := b.fieldByPath(input, binding.Body)
+		if field == nil {
+			return nil, fmt.Errorf("body [%s] of method [%s] is not a field of [%s]", binding.Body, method.GetName(), input.GetName())
+		}
+		schema, err := b.fieldSchema(field)
+		if err != nil {
+			return nil, err
+		}
+		operation["requestBody"] = map[string]interface{}{
+			"content": jsonContent(schema),
+		}
+	}
+	return operation, nil
+}
+
+// messageRef returns the schema of a well known type or a reference to the message definition
+func (b *schemaBuilder) messageRef(name string) map[string]interface{} {
+	if schema := b.wellKnownSchema(name); schema != nil {
+		return schema
+	}
+	return b.ref(name)
+}
+
+// fieldByPath returns the field of a message for a dotted field path
+func (b *schemaBuilder) fieldByPath(message *descriptor.DescriptorProto, path string) *descriptor.FieldDescriptorProto {
+	return b.descriptors.fieldByPath(message, path)
+}
+
+// fieldByPath returns the field of a message for a dotted field path
+func (d *Descriptors) fieldByPath(message *descriptor.DescriptorProto, path string) *descriptor.FieldDescriptorProto {
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		var found *descriptor.FieldDescriptorProto
+		for _, field := range message.Field {
+			if field.GetName() == name || field.GetJsonName() == name {
+				found = field
+			}
+		}
+		if found == nil || i == len(names)-1 {
+			return found
+		}
+		message = d.Message(found.GetTypeName())
+		if message == nil {
+			return nil
+		}
+	}
+	return nil
+}
+
+// queryParameter is a field set from a query parameter, named by the dotted path of its field names or of their
+// JSON names
+type queryParameter struct {
+	path, jsonPath string
+	field          *descriptor.FieldDescriptorProto
+}
+
+// queryParameters returns the fields of a message which can be set from query parameters, its scalar and repeated
+// scalar fields and those of its singular message fields, except the fields bound to path variables and to the body
+func (d *Descriptors) queryParameters(message *descriptor.DescriptorProto, binding HTTPBinding, bound map[string]bool) []queryParameter {
+	excluded := func(path, jsonPath string) bool {
+		for _, p := range []string{path, jsonPath} {
+			if p == binding.Body || strings.HasPrefix(p, binding.Body+".") {
+				return true
+			}
+			for name := range bound {
+				if p == name || strings.HasPrefix(p, name+".") {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	var params []queryParameter
+	var add func(message *descriptor.DescriptorProto, prefix, jsonPrefix string, visited map[string]bool)
+	add = func(message *descriptor.DescriptorProto, prefix, jsonPrefix string, visited map[string]bool) {
+		for _, field := range message.Field {
+			path, jsonPath := prefix+field.GetName(), jsonPrefix+field.GetJsonName()
+			if field.GetJsonName() == "" {
+				jsonPath = jsonPrefix + field.GetName()
+			}
+			if excluded(path, jsonPath) {
+				continue
+			}
+			if isQueryField(field) {
+				params = append(params, queryParameter{path: path, jsonPath: jsonPath, field: field})
+				continue
+			}
+			// singular message fields are walked once along a path so that recursive messages end
+			if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE || field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED || visited[field.GetTypeName()] {
+				continue
+			}
+			nested := d.Message(field.GetTypeName())
+			if nested == nil || nested.GetOptions().GetMapEntry() {
+				continue
+			}
+			visited[field.GetTypeName()] = true
+			add(nested, path+".", jsonPath+".", visited)
+			delete(visited, field.GetTypeName())
+		}
+	}
+	add(message, "", "", make(map[string]bool))
+	return params
+}
+
+// isQueryField reports whether a field can be set from the string values of query parameters, scalars, repeated
+// scalars, timestamps and durations
+func isQueryField(field *descriptor.FieldDescriptorProto) bool {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_ENUM, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return false
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		name := definitionName(field.GetTypeName())
+		return name == "google.protobuf.Timestamp" || name == "google.protobuf.Duration"
+	}
+	return true
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+// generateOpenAPIFile writes the OpenAPI document of the proto file
+func generateOpenAPIFile(d *Descriptors) error {
+	document, err := d.OpenAPI(protoFileName)
+	if err != nil {
+		return err
+	}
+	return writeJSONFile(filepath.Join(appPath, strings.Split(protoFileName, ".")[0]+".openapi.json"), document)
+}
@@ -0,0 +1,450 @@
+package support
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/generator"
+)
+
+const (
+	// ModeGRPCToGRPC proxies gRPC calls to a gRPC backend
+	ModeGRPCToGRPC = "grpc-to-grpc"
+	// ModeGRPCToRest proxies gRPC calls to a REST backend
+	ModeGRPCToRest = "grpc-to-rest"
+	// ModeRestToGRPC proxies REST calls to a gRPC backend
+	ModeRestToGRPC = "rest-to-grpc"
+
+	grpcTriggerRef  = "github.com/project-flogo/grpc/trigger/grpc"
+	grpcActivityRef = "github.com/project-flogo/grpc/activity"
+	restTriggerRef  = "github.com/project-flogo/contrib/trigger/rest"
+	restActivityRef = "github.com/project-flogo/contrib/activity/rest"
+	gatewayRef      = "github.com/project-flogo/microgateway"
+)
+
+// ScaffoldConfig holds the options used to scaffold a microgateway application
+type ScaffoldConfig struct {
+	// ProtoPath is the location of the proto file
+	ProtoPath string
+	// Mode is one of grpc-to-grpc, grpc-to-rest or rest-to-grpc
+	Mode string
+	// Backends maps a service name to its backend url, the empty key is used for all other services
+	Backends map[string]string
+	// Port is the port the generated trigger listens on
+	Port int
+	// AppName is the name of the generated application
+	AppName string
+	// IncludePaths are the additional proto import paths used to load the messages of the proto file
+	IncludePaths []string
+	// Descriptors describe the messages of the proto file, they are loaded with protoc when not set
+	Descriptors *Descriptors
+}
+
+type scaffoldApp struct {
+	Name        string             `json:"name"`
+	Type        string             `json:"type"`
+	Version     string             `json:"version"`
+	Description string             `json:"description"`
+	Properties  interface{}        `json:"properties"`
+	Channels    interface{}        `json:"channels"`
+	Triggers    []scaffoldTrigger  `json:"triggers"`
+	Resources   []scaffoldResource `json:"resources"`
+	Actions     []scaffoldAction   `json:"actions"`
+}
+
+type scaffoldTrigger struct {
+	Name     string                 `json:"name"`
+	ID       string                 `json:"id"`
+	Ref      string                 `json:"ref"`
+	Settings map[string]interface{} `json:"settings"`
+	Handlers []scaffoldHandler      `json:"handlers"`
+}
+
+type scaffoldHandler struct {
+	Settings map[string]interface{} `json:"settings"`
+	Schemas  *scaffoldSchemas       `json:"schemas,omitempty"`
+	Actions  []scaffoldActionRef    `json:"actions"`
+}
+
+type scaffoldSchemas struct {
+	Output map[string]interface{} `json:"output,omitempty"`
+	Reply  map[string]interface{} `json:"reply,omitempty"`
+}
+
+type scaffoldActionRef struct {
+	ID string `json:"id"`
+}
+
+type scaffoldResource struct {
+	ID         string               `json:"id"`
+	Compressed bool                 `json:"compressed"`
+	Data       scaffoldResourceData `json:"data"`
+}
+
+type scaffoldResourceData struct {
+	Name      string             `json:"name"`
+	Steps     []scaffoldStep     `json:"steps"`
+	Responses []scaffoldResponse `json:"responses"`
+	Services  []scaffoldService  `json:"services"`
+}
+
+type scaffoldStep struct {
+	Service string                 `json:"service"`
+	Input   map[string]interface{} `json:"input"`
+}
+
+type scaffoldResponse struct {
+	If     string         `json:"if,omitempty"`
+	Error  bool           `json:"error"`
+	Output scaffoldOutput `json:"output"`
+}
+
+type scaffoldOutput struct {
+	Code int         `json:"code"`
+	Data interface{} `json:"data"`
+}
+
+type scaffoldService struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Ref         string                 `json:"ref"`
+	Settings    map[string]interface{} `json:"settings"`
+}
+
+type scaffoldAction struct {
+	Ref      string                 `json:"ref"`
+	Settings map[string]interface{} `json:"settings"`
+	ID       string                 `json:"id"`
+	Metadata interface{}            `json:"metadata"`
+}
+
+// GenerateScaffold writes a flogo.json application for the given proto to output
+func GenerateScaffold(config ScaffoldConfig, output string) error {
+	app, err := Scaffold(config)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(output)
+	if _, err := os.Stat(dir); err != nil {
+		os.MkdirAll(dir, os.ModePerm)
+	}
+
+	log.Println("writing application to:", output)
+	return ioutil.WriteFile(output, app, 0644)
+}
+
+// Scaffold creates a flogo.json application with one handler and one microgateway resource per rpc method
+func Scaffold(config ScaffoldConfig) ([]byte, error) {
+	if config.ProtoPath == "" {
+		return nil, errors.New("proto file is required")
+	}
+	path, err := filepath.Abs(config.ProtoPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = os.Stat(path); err != nil {
+		return nil, fmt.Errorf("proto file path provided is invalid: %s", err.Error())
+	}
+
+	switch config.Mode {
+	case ModeGRPCToGRPC, ModeGRPCToRest, ModeRestToGRPC:
+	default:
+		return nil, fmt.Errorf("mode [%s] is not supported", config.Mode)
+	}
+	if config.Port == 0 {
+		config.Port = 9096
+	}
+
+	protoFileName = filepath.Base(path)
+	protoName := strings.Split(protoFileName, ".")[0]
+	if config.AppName == "" {
+		config.AppName = generator.CamelCase(protoName) + "Gateway"
+	}
+
+	pdArr, err := getProtoData("main", path)
+	if err != nil {
+		return nil, err
+	}
+	pdArr = arrangeProtoData(pdArr)
+
+	descriptors := config.Descriptors
+	if descriptors == nil {
+		descriptors, err = LoadDescriptors(path, config.IncludePaths...)
+		if err != nil {
+			log.Println("handler schemas are not scaffolded:", err.Error())
+		}
+	}
+
+	triggerRef, triggerName := grpcTriggerRef, "flogo-grpc"
+	triggerSettings := map[string]interface{}{
+		"port":      config.Port,
+		"protoName": protoName,
+	}
+	if config.Mode == ModeRestToGRPC {
+		triggerRef, triggerName = restTriggerRef, "flogo-rest"
+		triggerSettings = map[string]interface{}{
+			"port": config.Port,
+		}
+	}
+
+	app := scaffoldApp{
+		Name:        config.AppName,
+		Type:        "flogo:app",
+		Version:     "1.0.0",
+		Description: fmt.Sprintf("Scaffolded %s gateway for %s", config.Mode, protoFileName),
+		Triggers: []scaffoldTrigger{
+			{
+				Name:     triggerName,
+				ID:       config.AppName,
+				Ref:      triggerRef,
+				Settings: triggerSettings,
+			},
+		},
+	}
+
+	for _, pd := range pdArr {
+		backend := config.Backends[pd.RegServiceName]
+		if backend == "" {
+			backend = config.Backends[""]
+		}
+		if backend == "" {
+			return nil, fmt.Errorf("backend url not provided for service [%s]", pd.RegServiceName)
+		}
+
+		methods := pd.UnaryMethodInfo
+		if config.Mode == ModeGRPCToGRPC {
+			methods = append(methods, pd.ServerStreamMethodInfo...)
+			methods = append(methods, pd.ClientStreamMethodInfo...)
+			methods = append(methods, pd.BiDiStreamMethodInfo...)
+		} else if len(methods) != len(pd.AllMethodInfo) {
+			log.Printf("streaming methods of service [%s] are skipped in %s mode", pd.RegServiceName, config.Mode)
+		}
+
+		for _, method := range methods {
+			id := "microgateway:" + scaffoldDispatchName(pdArr, pd.RegServiceName, method.MethodName)
+
+			handler := scaffoldHandler{
+				Settings: map[string]interface{}{
+					"serviceName": pd.RegServiceName,
+					"methodName":  method.MethodName,
+				},
+				Schemas: scaffoldHandlerSchemas(descriptors, pd.RegServiceName, method.MethodName),
+				Actions: []scaffoldActionRef{{ID: id}},
+			}
+			if config.Mode == ModeRestToGRPC {
+				handler.Settings = map[string]interface{}{
+					"method": "POST",
+					"path":   "/" + pd.RegServiceName + "/" + method.MethodName,
+				}
+			}
+			app.Triggers[0].Handlers = append(app.Triggers[0].Handlers, handler)
+
+			app.Resources = append(app.Resources, scaffoldResource{
+				ID:   id,
+				Data: scaffoldResourceFor(config.Mode, protoName, pd.RegServiceName, method.MethodName, backend),
+			})
+			app.Actions = append(app.Actions, scaffoldAction{
+				Ref:      gatewayRef,
+				Settings: map[string]interface{}{"uri": id},
+				ID:       id,
+			})
+		}
+	}
+
+	if len(app.Triggers[0].Handlers) == 0 {
+		return nil, fmt.Errorf("no methods found in proto file [%s] for %s mode", protoFileName, config.Mode)
+	}
+
+	return json.MarshalIndent(app, "", "  ")
+}
+
+// scaffoldHandlerSchemas returns the JSON Schema of the request message as the content output of a handler and the one
+// of the response message as its data reply, the handlers of streams get neither the messages of the client nor a reply
+func scaffoldHandlerSchemas(d *Descriptors, serviceName, methodName string) *scaffoldSchemas {
+	if d == nil {
+		return nil
+	}
+	method := d.Method(serviceName, methodName)
+	if method == nil || method.GetClientStreaming() {
+		return nil
+	}
+	input, output, err := d.MethodSchemas(serviceName, methodName)
+	if err != nil {
+		log.Printf("schemas of method [%s] are not scaffolded: %s", methodName, err.Error())
+		return nil
+	}
+
+	schemas := &scaffoldSchemas{
+		Output: map[string]interface{}{"content": schemaDef(input)},
+	}
+	if !method.GetServerStreaming() {
+		schemas.Reply = map[string]interface{}{"data": schemaDef(output)}
+	}
+	return schemas
+}
+
+// schemaDef creates a json schema definition as used in handler schema configs
+func schemaDef(schema map[string]interface{}) map[string]interface{} {
+	b, _ := json.Marshal(schema)
+	return map[string]interface{}{
+		"type":  "json",
+		"value": string(b),
+	}
+}
+
+// ParseBackends parses a comma separated list of backend urls, an entry can be scoped to a service with service=url
+func ParseBackends(value string) map[string]string {
+	backends := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if index := strings.Index(entry, "="); index > 0 {
+			backends[strings.TrimSpace(entry[:index])] = strings.TrimSpace(entry[index+1:])
+			continue
+		}
+		backends[""] = entry
+	}
+	return backends
+}
+
+// scaffoldResourceFor creates the service, step and responses of a dispatch resource
+func scaffoldResourceFor(mode, protoName, serviceName, methodName, backend string) scaffoldResourceData {
+	svcName := methodName + "Backend"
+	outputs := "$." + svcName + ".outputs"
+
+	data := scaffoldResourceData{
+		Name: serviceName + methodName,
+	}
+
+	switch mode {
+	case ModeGRPCToGRPC:
+		data.Services = []scaffoldService{
+			{
+				Name:        svcName,
+				Description: "Make calls to " + serviceName + " gRPC end point",
+				Ref:         grpcActivityRef,
+				Settings: map[string]interface{}{
+					"operatingMode": ModeGRPCToGRPC,
+					"hosturl":       backend,
+				},
+			},
+		}
+		data.Steps = []scaffoldStep{
+			{
+				Service: svcName,
+				Input: map[string]interface{}{
+					"grpcMthdParamtrs": "=$.payload.grpcData",
+				},
+			},
+		}
+		data.Responses = scaffoldGRPCResponses(outputs)
+	case ModeGRPCToRest:
+		data.Services = []scaffoldService{
+			{
+				Name:        svcName,
+				Description: "Make calls to " + serviceName + " REST end point",
+				Ref:         restActivityRef,
+				Settings: map[string]interface{}{
+					"uri":    strings.TrimSuffix(backend, "/") + "/" + methodName,
+					"method": "POST",
+					"headers": map[string]string{
+						"Content-Type": "application/json",
+					},
+				},
+			},
+		}
+		data.Steps = []scaffoldStep{
+			{
+				Service: svcName,
+				Input: map[string]interface{}{
+					"queryParams": "=$.payload.params",
+					"content":     "=$.payload.content",
+				},
+			},
+		}
+		data.Responses = []scaffoldResponse{
+			{
+				If:    outputs + ".status != 200",
+				Error: true,
+				Output: scaffoldOutput{
+					Code: 404,
+					Data: map[string]interface{}{
+						"error": "=" + outputs + ".data",
+					},
+				},
+			},
+			{
+				Output: scaffoldOutput{
+					Code: 200,
+					Data: "=" + outputs + ".data",
+				},
+			},
+		}
+	case ModeRestToGRPC:
+		data.Services = []scaffoldService{
+			{
+				Name:        svcName,
+				Description: "Make calls to " + serviceName + " gRPC end point",
+				Ref:         grpcActivityRef,
+				Settings: map[string]interface{}{
+					"operatingMode": ModeRestToGRPC,
+					"hosturl":       backend,
+				},
+			},
+		}
+		data.Steps = []scaffoldStep{
+			{
+				Service: svcName,
+				Input: map[string]interface{}{
+					"protoName":   protoName,
+					"serviceName": serviceName,
+					"methodName":  methodName,
+					"pathParams":  "=$.payload.pathParams",
+					"queryParams": "=$.payload.queryParams",
+					"content":     "=$.payload.content",
+				},
+			},
+		}
+		data.Responses = scaffoldGRPCResponses(outputs)
+	}
+
+	return data
+}
+
+// scaffoldGRPCResponses creates the error and success responses for the grpc activity
+func scaffoldGRPCResponses(outputs string) []scaffoldResponse {
+	return []scaffoldResponse{
+		{
+			If:    outputs + ".body.error == 'true'",
+			Error: true,
+			Output: scaffoldOutput{
+				Code: 404,
+				Data: "=" + outputs + ".body.details",
+			},
+		},
+		{
+			Output: scaffoldOutput{
+				Code: 200,
+				Data: "=" + outputs + ".body",
+			},
+		},
+	}
+}
+
+// scaffoldDispatchName names a dispatch resource, the service is added when the proto has more than one service
+func scaffoldDispatchName(pdArr []ProtoData, serviceName, methodName string) string {
+	name := strings.ToLower(methodName[:1]) + methodName[1:] + "Dispatch"
+	if len(pdArr) > 1 {
+		name = strings.ToLower(serviceName[:1]) + serviceName[1:] + methodName + "Dispatch"
+	}
+	return name
+}
@@ -0,0 +1,221 @@
+package support
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// HTTPRoute matches the paths of HTTP requests against the path template of a binding
+type HTTPRoute struct {
+	HTTPBinding
+	pattern   *regexp.Regexp
+	variables []string
+}
+
+// NewHTTPRoute compiles the path template of the binding, * matches one segment, ** any number of segments
+// and a trailing :verb is matched literally
+func NewHTTPRoute(binding HTTPBinding) (*HTTPRoute, error) {
+	route := &HTTPRoute{HTTPBinding: binding}
+	template := binding.Template
+	verb := ""
+	if index := strings.LastIndex(template, ":"); index > strings.LastIndex(template, "/") && index > strings.LastIndex(template, "}") {
+		template, verb = template[:index], template[index:]
+	}
+
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for {
+		start := strings.Index(template, "{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(template[start:], "}")
+		if end < 0 {
+			return nil, fmt.Errorf("invalid path template [%s]", binding.Template)
+		}
+		pattern.WriteString(segmentsPattern(template[:start]))
+		variable, segments := template[start+1:start+end], "*"
+		if index := strings.Index(variable, "="); index >= 0 {
+			variable, segments = variable[:index], variable[index+1:]
+		}
+		route.variables = append(route.variables, strings.TrimSpace(variable))
+		pattern.WriteString("(" + segmentsPattern(segments) + ")")
+		template = template[start+end+1:]
+	}
+	pattern.WriteString(segmentsPattern(template) + regexp.QuoteMeta(verb) + "$")
+
+	var err error
+	route.pattern, err = regexp.Compile(pattern.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid path template [%s]: %s", binding.Template, err.Error())
+	}
+	return route, nil
+}
+
+func segmentsPattern(segments string) string {
+	parts := strings.Split(segments, "/")
+	for i, part := range parts {
+		switch part {
+		case "*":
+			parts[i] = "[^/]+"
+		case "**":
+			parts[i] = ".+"
+		default:
+			parts[i] = regexp.QuoteMeta(part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// Match returns the values of the path variables when the escaped path of a request matches the template
+func (r *HTTPRoute) Match(path string) (map[string]string, bool) {
+	matches := r.pattern.FindStringSubmatch(path)
+	if matches == nil {
+		return nil, false
+	}
+	variables := make(map[string]string, len(r.variables))
+	for i, name := range r.variables {
+		value, err := url.PathUnescape(matches[i+1])
+		if err != nil {
+			return nil, false
+		}
+		variables[name] = value
+	}
+	return variables, true
+}
+
+// TranscodeRequest builds the request message of a binding from the path variables, the query parameters and the
+// JSON body of an HTTP request, query parameters are applied to the scalar fields left unbound like in the OpenAPI
+// document of the binding, nested fields at their dotted path such as ?owner.name=x and repeated fields with every
+// value of their parameter such as ?tags=a&tags=b
+func (d *Descriptors) TranscodeRequest(inputType string, binding HTTPBinding, variables map[string]string, query url.Values, body []byte) (*DynamicMessage, error) {
+	input := d.Message(inputType)
+	if input == nil {
+		return nil, fmt.Errorf("message [%s] not found", definitionName(inputType))
+	}
+
+	value := make(map[string]interface{})
+	if len(strings.TrimSpace(string(body))) > 0 && binding.Body != "" {
+		var content interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&content); err != nil {
+			return nil, err
+		}
+		if binding.Body == "*" {
+			fields, ok := content.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("body of message [%s] is not an object", input.GetName())
+			}
+			value = fields
+		} else {
+			setFieldPath(value, binding.Body, content)
+		}
+	}
+
+	bound := make(map[string]bool)
+	for name, v := range variables {
+		if d.fieldByPath(input, name) == nil {
+			return nil, fmt.Errorf("path variable [%s] is not a field of [%s]", name, input.GetName())
+		}
+		setFieldPath(value, name, v)
+		bound[name] = true
+	}
+
+	if binding.Body != "*" || !binding.Annotated {
+		for _, param := range d.queryParameters(input, binding, bound) {
+			values, ok := query[param.path]
+			if !ok {
+				if values, ok = query[param.jsonPath]; !ok {
+					continue
+				}
+			}
+			// a repeated field takes every value of its parameter, a singular one the last value
+			if param.field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+				list := make([]interface{}, len(values))
+				for i, v := range values {
+					list[i] = v
+				}
+				setFieldPath(value, param.path, list)
+			} else if len(values) > 0 {
+				setFieldPath(value, param.path, values[len(values)-1])
+			}
+		}
+	}
+
+	msg, err := d.NewDynamicMessage(inputType)
+	if err != nil {
+		return nil, err
+	}
+	msg.Value = value
+	// the values are checked against the descriptor
+	b, err := msg.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	if err = msg.Unmarshal(b); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// TranscodeResponse converts handler data to the response message of a binding and returns its JSON value,
+// or the JSON value of its response body field when the binding has one
+func (d *Descriptors) TranscodeResponse(outputType string, binding HTTPBinding, data interface{}) ([]byte, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := d.NewDynamicMessage(outputType)
+	if err != nil {
+		return nil, err
+	}
+	if err = msg.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+	if b, err = msg.Marshal(); err != nil {
+		return nil, err
+	}
+	if err = msg.Unmarshal(b); err != nil {
+		return nil, err
+	}
+	if binding.ResponseBody == "" {
+		return msg.MarshalJSON()
+	}
+
+	output := d.Message(outputType)
+	field := d.fieldByPath(output, binding.ResponseBody)
+	if field == nil {
+		return nil, fmt.Errorf("response body [%s] is not a field of [%s]", binding.ResponseBody, output.GetName())
+	}
+	fields, _ := msg.Value.(map[string]interface{})
+	value, ok := fields[field.GetName()]
+	switch {
+	case ok:
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		value = []interface{}{}
+	default:
+		value = msg.codec().defaultValue(field)
+	}
+	return json.Marshal(value)
+}
+
+// setFieldPath sets a value at a dotted field path, creating the enclosing objects
+func setFieldPath(value map[string]interface{}, path string, v interface{}) {
+	names := strings.Split(path, ".")
+	for _, name := range names[:len(names)-1] {
+		next, ok := value[name].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			value[name] = next
+		}
+		value = next
+	}
+	value[names[len(names)-1]] = v
+}
@@ -0,0 +1,741 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/proto/test_proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/project-flogo/core/engine"
+	"github.com/project-flogo/grpc/proto/grpc2grpc"
+	"github.com/project-flogo/grpc/support"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeClientStream records the messages sent and returns the queued messages
+type fakeClientStream struct {
+	grpc.ClientStream
+	sent     []proto.Message
+	received []proto.Message
+}
+
+func (s *fakeClientStream) SendMsg(m interface{}) error {
+	s.sent = append(s.sent, m.(proto.Message))
+	return nil
+}
+
+func (s *fakeClientStream) CloseSend() error {
+	return nil
+}
+
+func (s *fakeClientStream) RecvMsg(m interface{}) error {
+	if len(s.received) == 0 {
+		return io.EOF
+	}
+	proto.Merge(m.(proto.Message), s.received[0])
+	s.received = s.received[1:]
+	return nil
+}
+
+func TestClientStreamMessages(t *testing.T) {
+	newField := func() proto.Message { return &descriptor.FieldDescriptorProto{} }
+	stream := &fakeClientStream{}
+
+	// the content follows the proto JSON mapping: enum names, either field name and unknown fields ignored
+	err := support.SendMessages(stream, map[string]interface{}{
+		"Content": []interface{}{
+			map[string]interface{}{"name": "id", "type": "TYPE_INT64", "json_name": "id", "other": true},
+			map[string]interface{}{"name": "kind", "typeName": ".Kind", "label": 3},
+			&descriptor.FieldDescriptorProto{Name: proto.String("pet")},
+		},
+	}, newField)
+	assert.Nil(t, err)
+	if assert.Len(t, stream.sent, 3) {
+		assert.Equal(t, &descriptor.FieldDescriptorProto{
+			Name:     proto.String("id"),
+			Type:     descriptor.FieldDescriptorProto_TYPE_INT64.Enum(),
+			JsonName: proto.String("id"),
+		}, stream.sent[0])
+		assert.Equal(t, &descriptor.FieldDescriptorProto{
+			Name:     proto.String("kind"),
+			TypeName: proto.String(".Kind"),
+			Label:    descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+		}, stream.sent[1])
+		assert.Equal(t, "pet", stream.sent[2].(*descriptor.FieldDescriptorProto).GetName())
+	}
+
+	stream.received = []proto.Message{
+		&descriptor.FieldDescriptorProto{Name: proto.String("id"), Type: descriptor.FieldDescriptorProto_TYPE_INT64.Enum(), TypeName: proto.String(".Pet")},
+		&descriptor.FieldDescriptorProto{Name: proto.String("kind")},
+	}
+	b, err := support.RecvMessages(context.Background(), stream, newField, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `[{"name": "id", "type": "TYPE_INT64", "type_name": ".Pet"}, {"name": "kind"}]`, string(b))
+}
+
+func TestScaffold(t *testing.T) {
+	gz, _ := (&grpc2grpc.PetResponse{}).Descriptor()
+	descriptors, err := support.RegisteredDescriptors(gz)
+	assert.Nil(t, err)
+	data, err := support.Scaffold(support.ScaffoldConfig{
+		ProtoPath:   filepath.FromSlash("./proto/grpc2grpc/petstore.proto"),
+		Mode:        support.ModeGRPCToGRPC,
+		Backends:    support.ParseBackends("localhost:9000"),
+		Descriptors: descriptors,
+	})
+	assert.Nil(t, err)
+
+	cfg, err := engine.LoadAppConfig(string(data), false)
+	assert.Nil(t, err)
+	assert.Len(t, cfg.Triggers, 1)
+	assert.Equal(t, "github.com/project-flogo/grpc/trigger/grpc", cfg.Triggers[0].Ref)
+	assert.Len(t, cfg.Triggers[0].Handlers, 5)
+	assert.Len(t, cfg.Resources, 5)
+
+	handler := cfg.Triggers[0].Handlers[0]
+	assert.Equal(t, "PetStoreService", handler.Settings["serviceName"])
+	assert.Equal(t, "PetById", handler.Settings["methodName"])
+	assert.Equal(t, "microgateway:petByIdDispatch", cfg.Resources[0].ID)
+	if assert.NotNil(t, handler.Schemas) {
+		content := handler.Schemas.Output["content"].(map[string]interface{})
+		assert.Equal(t, "json", content["type"])
+		assert.Contains(t, content["value"], "PetByIdRequest")
+		data := handler.Schemas.Reply["data"].(map[string]interface{})
+		assert.Contains(t, data["value"], "grpc2grpc.Pet")
+	}
+	for _, handler := range cfg.Triggers[0].Handlers {
+		switch handler.Settings["methodName"] {
+		case "ListUsers":
+			// the responses of a server stream are not the reply of the handler
+			assert.NotNil(t, handler.Schemas.Output["content"])
+			assert.Nil(t, handler.Schemas.Reply)
+		case "StoreUsers", "BulkUsers":
+			assert.Nil(t, handler.Schemas)
+		}
+	}
+
+	data, err = support.Scaffold(support.ScaffoldConfig{
+		ProtoPath: filepath.FromSlash("./proto/grpc2grpc/petstore.proto"),
+		Mode:      support.ModeRestToGRPC,
+		Backends:  support.ParseBackends("PetStoreService=localhost:9000"),
+	})
+	assert.Nil(t, err)
+	cfg, err = engine.LoadAppConfig(string(data), false)
+	assert.Nil(t, err)
+	assert.Equal(t, "github.com/project-flogo/contrib/trigger/rest", cfg.Triggers[0].Ref)
+	assert.Len(t, cfg.Triggers[0].Handlers, 2)
+	assert.Equal(t, "/PetStoreService/UserByName", cfg.Triggers[0].Handlers[1].Settings["path"])
+
+	_, err = support.Scaffold(support.ScaffoldConfig{
+		ProtoPath: filepath.FromSlash("./proto/grpc2grpc/petstore.proto"),
+		Mode:      support.ModeGRPCToRest,
+	})
+	assert.NotNil(t, err)
+}
+
+func TestJSONSchema(t *testing.T) {
+	gz, _ := (&grpc2grpc.PetResponse{}).Descriptor()
+	d, err := support.RegisteredDescriptors(gz)
+	assert.Nil(t, err)
+
+	input, output, err := d.MethodSchemas("PetStoreService", "PetById")
+	assert.Nil(t, err)
+	assert.Equal(t, "PetByIdRequest", input["title"])
+	assert.Equal(t, map[string]interface{}{"type": "integer"}, input["properties"].(map[string]interface{})["id"])
+
+	pet := output["properties"].(map[string]interface{})["pet"]
+	assert.Equal(t, map[string]interface{}{"$ref": "#/definitions/grpc2grpc.Pet"}, pet)
+	definitions := output["definitions"].(map[string]interface{})
+	assert.Contains(t, definitions["grpc2grpc.Pet"].(map[string]interface{})["properties"], "name")
+
+	_, _, err = d.MethodSchemas("PetStoreService", "Unknown")
+	assert.NotNil(t, err)
+
+	schema, err := codecDescriptors().MessageSchema(".codec.Message")
+	assert.Nil(t, err)
+	properties := schema["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "integer", "minimum": 0}, properties["uint32_value"])
+	assert.Equal(t, map[string]interface{}{
+		"type": []string{"string", "integer"},
+		"enum": []interface{}{"UNKNOWN", "SMALL", "LARGE", int32(0), int32(1), int32(2)},
+	}, properties["kind"])
+	assert.Equal(t, map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}}, properties["numbers"])
+	assert.Equal(t, map[string]interface{}{
+		"type":  "array",
+		"items": map[string]interface{}{"$ref": "#/definitions/codec.Message.Nested"},
+	}, properties["children"])
+	assert.Equal(t, map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": map[string]interface{}{"type": "integer"},
+	}, properties["counts"])
+	assert.Equal(t, map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": map[string]interface{}{"$ref": "#/definitions/codec.Message.Nested"},
+	}, properties["children_by_id"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"required": []string{"name"}},
+		map[string]interface{}{"required": []string{"child"}},
+		map[string]interface{}{"not": map[string]interface{}{"anyOf": []interface{}{
+			map[string]interface{}{"required": []string{"name"}},
+			map[string]interface{}{"required": []string{"child"}},
+		}}},
+	}, schema["oneOf"])
+	assert.Contains(t, schema["definitions"], "codec.Message.Nested")
+}
+
+// codecDescriptors describes a message with a field of every scalar type, packed repeated fields, maps, a oneof,
+// an enum and nested messages
+func codecDescriptors() *support.Descriptors {
+	field := func(name string, number int32, typ descriptor.FieldDescriptorProto_Type, typeName string, repeated bool) *descriptor.FieldDescriptorProto {
+		f := &descriptor.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Type:     typ.Enum(),
+			Label:    descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		if repeated {
+			f.Label = descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		}
+		return f
+	}
+	entry := func(name string, key, value *descriptor.FieldDescriptorProto) *descriptor.DescriptorProto {
+		return &descriptor.DescriptorProto{
+			Name:    proto.String(name),
+			Field:   []*descriptor.FieldDescriptorProto{key, value},
+			Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+		}
+	}
+	choice := func(f *descriptor.FieldDescriptorProto) *descriptor.FieldDescriptorProto {
+		f.OneofIndex = proto.Int32(0)
+		return f
+	}
+
+	return support.NewDescriptors(&descriptor.FileDescriptorProto{
+		Name:    proto.String("codec.proto"),
+		Package: proto.String("codec"),
+		Syntax:  proto.String("proto3"),
+		EnumType: []*descriptor.EnumDescriptorProto{{
+			Name: proto.String("Kind"),
+			Value: []*descriptor.EnumValueDescriptorProto{
+				{Name: proto.String("UNKNOWN"), Number: proto.Int32(0)},
+				{Name: proto.String("SMALL"), Number: proto.Int32(1)},
+				{Name: proto.String("LARGE"), Number: proto.Int32(2)},
+			},
+		}},
+		MessageType: []*descriptor.DescriptorProto{{
+			Name: proto.String("Message"),
+			Field: []*descriptor.FieldDescriptorProto{
+				field("double_value", 1, descriptor.FieldDescriptorProto_TYPE_DOUBLE, "", false),
+				field("float_value", 2, descriptor.FieldDescriptorProto_TYPE_FLOAT, "", false),
+				field("int64_value", 3, descriptor.FieldDescriptorProto_TYPE_INT64, "", false),
+				field("uint64_value", 4, descriptor.FieldDescriptorProto_TYPE_UINT64, "", false),
+				field("int32_value", 5, descriptor.FieldDescriptorProto_TYPE_INT32, "", false),
+				field("fixed64_value", 6, descriptor.FieldDescriptorProto_TYPE_FIXED64, "", false),
+				field("fixed32_value", 7, descriptor.FieldDescriptorProto_TYPE_FIXED32, "", false),
+				field("bool_value", 8, descriptor.FieldDescriptorProto_TYPE_BOOL, "", false),
+				field("string_value", 9, descriptor.FieldDescriptorProto_TYPE_STRING, "", false),
+				field("bytes_value", 12, descriptor.FieldDescriptorProto_TYPE_BYTES, "", false),
+				field("uint32_value", 13, descriptor.FieldDescriptorProto_TYPE_UINT32, "", false),
+				field("sfixed32_value", 15, descriptor.FieldDescriptorProto_TYPE_SFIXED32, "", false),
+				field("sfixed64_value", 16, descriptor.FieldDescriptorProto_TYPE_SFIXED64, "", false),
+				field("sint32_value", 17, descriptor.FieldDescriptorProto_TYPE_SINT32, "", false),
+				field("sint64_value", 18, descriptor.FieldDescriptorProto_TYPE_SINT64, "", false),
+				field("kind", 20, descriptor.FieldDescriptorProto_TYPE_ENUM, ".codec.Kind", false),
+				field("numbers", 21, descriptor.FieldDescriptorProto_TYPE_INT32, "", true),
+				field("offsets", 22, descriptor.FieldDescriptorProto_TYPE_SINT64, "", true),
+				field("weights", 23, descriptor.FieldDescriptorProto_TYPE_DOUBLE, "", true),
+				field("flags", 24, descriptor.FieldDescriptorProto_TYPE_BOOL, "", true),
+				field("kinds", 25, descriptor.FieldDescriptorProto_TYPE_ENUM, ".codec.Kind", true),
+				field("tags", 26, descriptor.FieldDescriptorProto_TYPE_STRING, "", true),
+				field("counts", 27, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".codec.Message.CountsEntry", true),
+				field("children_by_id", 28, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".codec.Message.ChildrenByIdEntry", true),
+				choice(field("name", 29, descriptor.FieldDescriptorProto_TYPE_STRING, "", false)),
+				choice(field("child", 30, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".codec.Message.Nested", false)),
+				field("nested", 31, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".codec.Message.Nested", false),
+				field("children", 32, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".codec.Message.Nested", true),
+			},
+			NestedType: []*descriptor.DescriptorProto{
+				{
+					Name: proto.String("Nested"),
+					Field: []*descriptor.FieldDescriptorProto{
+						field("id", 1, descriptor.FieldDescriptorProto_TYPE_STRING, "", false),
+						field("kind", 2, descriptor.FieldDescriptorProto_TYPE_ENUM, ".codec.Kind", false),
+						field("nested", 3, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".codec.Message.Nested", false),
+					},
+				},
+				entry("CountsEntry",
+					field("key", 1, descriptor.FieldDescriptorProto_TYPE_STRING, "", false),
+					field("value", 2, descriptor.FieldDescriptorProto_TYPE_INT32, "", false)),
+				entry("ChildrenByIdEntry",
+					field("key", 1, descriptor.FieldDescriptorProto_TYPE_INT64, "", false),
+					field("value", 2, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".codec.Message.Nested", false)),
+			},
+			OneofDecl: []*descriptor.OneofDescriptorProto{{Name: proto.String("choice")}},
+		}},
+	})
+}
+
+func TestDynamicMessage(t *testing.T) {
+	d := codecDescriptors()
+	roundTrip := func(value map[string]interface{}) (map[string]interface{}, []byte) {
+		message, err := d.NewDynamicMessage("codec.Message")
+		assert.Nil(t, err)
+		message.Value = value
+		b, err := message.Marshal()
+		assert.Nil(t, err)
+		decoded, err := d.NewDynamicMessage(".codec.Message")
+		assert.Nil(t, err)
+		assert.Nil(t, decoded.Unmarshal(b))
+		result, _ := decoded.Value.(map[string]interface{})
+		return result, b
+	}
+
+	// the values are given as they are decoded: 64 bit integers as strings, floats as float64, bytes as base64 and
+	// enums by name
+	scalars := map[string]interface{}{
+		"double_value":   -2.25,
+		"float_value":    1.5,
+		"int64_value":    "-9007199254740993",
+		"uint64_value":   "18446744073709551615",
+		"int32_value":    int32(-42),
+		"fixed64_value":  "12345678901234567",
+		"fixed32_value":  uint32(4294967295),
+		"bool_value":     true,
+		"string_value":   "héllo",
+		"bytes_value":    "AAEC/w==",
+		"uint32_value":   uint32(7),
+		"sfixed32_value": int32(-2147483648),
+		"sfixed64_value": "-5",
+		"sint32_value":   int32(-3),
+		"sint64_value":   "-9223372036854775808",
+		"kind":           "LARGE",
+	}
+	decoded, _ := roundTrip(scalars)
+	assert.Equal(t, scalars, decoded)
+
+	special, _ := roundTrip(map[string]interface{}{"double_value": "NaN", "float_value": "-Infinity"})
+	assert.Equal(t, map[string]interface{}{"double_value": "NaN", "float_value": "-Infinity"}, special)
+
+	repeated := map[string]interface{}{
+		"numbers": []interface{}{int32(1), int32(-2), int32(300)},
+		"offsets": []interface{}{"-1", "0", "9223372036854775807"},
+		"weights": []interface{}{0.5, -1.25},
+		"flags":   []interface{}{true, false, true},
+		"kinds":   []interface{}{"SMALL", "UNKNOWN", "LARGE"},
+		"tags":    []interface{}{"a", "", "c"},
+	}
+	decoded, _ = roundTrip(repeated)
+	assert.Equal(t, repeated, decoded)
+
+	// repeated scalars are packed in a single length delimited field, the tag of field 21 is a varint of 2 bytes
+	_, b := roundTrip(map[string]interface{}{"numbers": []interface{}{1, 2, 300}})
+	assert.Equal(t, []byte{0xaa, 0x01, 4, 0x01, 0x02, 0xac, 0x02}, b)
+	// and unpacked repeated scalars are accepted
+	message, err := d.NewDynamicMessage("codec.Message")
+	assert.Nil(t, err)
+	assert.Nil(t, message.Unmarshal([]byte{0xa8, 0x01, 0x01, 0xa8, 0x01, 0xac, 0x02}))
+	assert.Equal(t, map[string]interface{}{"numbers": []interface{}{int32(1), int32(300)}}, message.Value)
+
+	nested := map[string]interface{}{
+		"counts": map[string]interface{}{"a": int32(1), "b": int32(0)},
+		"children_by_id": map[string]interface{}{
+			"-7": map[string]interface{}{"id": "c7", "kind": "SMALL"},
+			"8":  map[string]interface{}{"nested": map[string]interface{}{"id": "deep"}},
+		},
+		"child":    map[string]interface{}{"id": "only"},
+		"nested":   map[string]interface{}{"id": "n1", "nested": map[string]interface{}{"id": "n2", "kind": "LARGE"}},
+		"children": []interface{}{map[string]interface{}{"id": "x"}, map[string]interface{}{}},
+	}
+	decoded, _ = roundTrip(nested)
+	assert.Equal(t, nested, decoded)
+
+	decoded, _ = roundTrip(map[string]interface{}{"name": "chosen"})
+	assert.Equal(t, map[string]interface{}{"name": "chosen"}, decoded)
+
+	// the JSON values of requests are accepted as numbers or strings
+	decoded, _ = roundTrip(map[string]interface{}{"int64_value": json.Number("12"), "uint32_value": 3.0, "kind": 1})
+	assert.Equal(t, map[string]interface{}{"int64_value": "12", "uint32_value": uint32(3), "kind": "SMALL"}, decoded)
+
+	for _, invalid := range []map[string]interface{}{
+		{"unknown": 1},
+		{"name": "a", "child": map[string]interface{}{}},
+		{"kind": "HUGE"},
+		{"int32_value": "x"},
+		{"numbers": 1},
+		{"counts": []interface{}{}},
+		{"bytes_value": "not base64"},
+		{"nested": map[string]interface{}{"unknown": true}},
+	} {
+		message, err := d.NewDynamicMessage("codec.Message")
+		assert.Nil(t, err)
+		message.Value = invalid
+		_, err = message.Marshal()
+		assert.NotNil(t, err, "%v", invalid)
+	}
+
+	_, err = d.NewDynamicMessage("codec.Unknown")
+	assert.NotNil(t, err)
+}
+
+func TestDynamicMessageWireFormat(t *testing.T) {
+	// the proto2 test messages of golang/protobuf cover groups, packed and unpacked repeated fields and maps
+	gz, _ := (&test_proto.GoTest{}).Descriptor()
+	d, err := support.RegisteredDescriptors(gz)
+	assert.Nil(t, err)
+	// roundTrip decodes the encoding of a generated message, encodes it again and decodes it with the generated message
+	roundTrip := func(name string, message, decoded proto.Message) (map[string]interface{}, []byte) {
+		b, err := proto.Marshal(message)
+		assert.Nil(t, err)
+		dynamic, err := d.NewDynamicMessage(name)
+		assert.Nil(t, err)
+		assert.Nil(t, dynamic.Unmarshal(b))
+		encoded, err := dynamic.Marshal()
+		assert.Nil(t, err)
+		assert.Nil(t, proto.Unmarshal(encoded, decoded))
+		assert.True(t, proto.Equal(message, decoded), "%v\n%v", message, decoded)
+		value, _ := dynamic.Value.(map[string]interface{})
+		return value, encoded
+	}
+
+	message := &test_proto.GoTest{
+		Kind:                    test_proto.GoTest_TIME.Enum(),
+		Table:                   proto.String("table"),
+		RequiredField:           &test_proto.GoTestField{Label: proto.String("label"), Type: proto.String("type")},
+		RepeatedField:           []*test_proto.GoTestField{{Label: proto.String("a"), Type: proto.String("b")}},
+		F_BoolRequired:          proto.Bool(true),
+		F_Int32Required:         proto.Int32(-3),
+		F_Int64Required:         proto.Int64(-1 << 40),
+		F_Fixed32Required:       proto.Uint32(1 << 31),
+		F_Fixed64Required:       proto.Uint64(1 << 63),
+		F_Uint32Required:        proto.Uint32(1<<32 - 1),
+		F_Uint64Required:        proto.Uint64(1<<64 - 1),
+		F_FloatRequired:         proto.Float32(3.5),
+		F_DoubleRequired:        proto.Float64(-0.125),
+		F_StringRequired:        proto.String("string"),
+		F_BytesRequired:         []byte{0, 1, 0xff},
+		F_Sint32Required:        proto.Int32(-1 << 31),
+		F_Sint64Required:        proto.Int64(-1 << 63),
+		F_Sfixed32Required:      proto.Int32(-2),
+		F_Sfixed64Required:      proto.Int64(-3),
+		F_Int32Repeated:         []int32{1, -2, 300},
+		F_Fixed64Repeated:       []uint64{4, 5},
+		F_FloatRepeated:         []float32{0.5},
+		F_StringRepeated:        []string{"x", ""},
+		F_BytesRepeated:         [][]byte{{1}, {}},
+		F_Sint64Repeated:        []int64{-6},
+		F_Int32Optional:         proto.Int32(0),
+		F_BoolRepeatedPacked:    []bool{true, false},
+		F_Int64RepeatedPacked:   []int64{-1, 1 << 50},
+		F_Fixed32RepeatedPacked: []uint32{7, 8},
+		F_DoubleRepeatedPacked:  []float64{1.25, -2},
+		F_Sint32RepeatedPacked:  []int32{-9, 9},
+		Requiredgroup:           &test_proto.GoTest_RequiredGroup{RequiredField: proto.String("required")},
+		Repeatedgroup: []*test_proto.GoTest_RepeatedGroup{
+			{RequiredField: proto.String("first")},
+			{RequiredField: proto.String("second")},
+		},
+	}
+	value, encoded := roundTrip("test_proto.GoTest", message, &test_proto.GoTest{})
+	assert.Equal(t, map[string]interface{}{"RequiredField": "required"}, value["requiredgroup"])
+	assert.Len(t, value["repeatedgroup"], 2)
+	// the fields are written like the generated message writes them, the repeated fields of proto2 messages are only
+	// packed with the packed option
+	b, err := proto.Marshal(message)
+	assert.Nil(t, err)
+	assert.Equal(t, b, encoded)
+	more := &test_proto.MoreRepeated{
+		Bools:        []bool{true, false},
+		BoolsPacked:  []bool{false, true},
+		Ints:         []int32{1, 2},
+		IntsPacked:   []int32{3, 4},
+		Int64SPacked: []int64{5},
+		Strings:      []string{"a", "b"},
+		Fixeds:       []uint32{6, 7},
+	}
+	_, encoded = roundTrip("test_proto.MoreRepeated", more, &test_proto.MoreRepeated{})
+	b, err = proto.Marshal(more)
+	assert.Nil(t, err)
+	assert.Equal(t, b, encoded)
+
+	maps := &test_proto.MessageWithMap{
+		NameMapping: map[int32]string{1: "one", -2: "minus two"},
+		MsgMapping: map[int64]*test_proto.FloatingPoint{
+			-1 << 40: {F: proto.Float64(1.5), Exact: proto.Bool(true)},
+			3:        {F: proto.Float64(0)},
+		},
+		ByteMapping: map[bool][]byte{true: {1, 2}, false: {}},
+		StrToStr:    map[string]string{"a": "b", "": "empty"},
+	}
+	value, _ = roundTrip("test_proto.MessageWithMap", maps, &test_proto.MessageWithMap{})
+	assert.Equal(t, map[string]interface{}{"1": "one", "-2": "minus two"}, value["name_mapping"])
+
+	// unknown fields of every wire type, groups included, are skipped
+	skip, err := proto.Marshal(&test_proto.GoSkipTest{
+		SkipInt32:   proto.Int32(1),
+		SkipFixed32: proto.Uint32(2),
+		SkipFixed64: proto.Uint64(3),
+		SkipString:  proto.String("skipped"),
+		Skipgroup:   &test_proto.GoSkipTest_SkipGroup{GroupInt32: proto.Int32(4), GroupString: proto.String("group")},
+	})
+	assert.Nil(t, err)
+	field, err := proto.Marshal(&test_proto.GoTestField{Label: proto.String("label"), Type: proto.String("type")})
+	assert.Nil(t, err)
+	dynamic, err := d.NewDynamicMessage("test_proto.GoTestField")
+	assert.Nil(t, err)
+	assert.Nil(t, dynamic.Unmarshal(append(skip, field...)))
+	assert.Equal(t, map[string]interface{}{"Label": "label", "Type": "type"}, dynamic.Value)
+	// as are the known fields with the wire type of another type
+	assert.Nil(t, dynamic.Unmarshal([]byte{0x08, 0x01}))
+	assert.Equal(t, map[string]interface{}{}, dynamic.Value)
+
+	for _, invalid := range [][]byte{
+		// a group without its end
+		{0x7b, 0x80, 0x01, 0x04},
+		// a group ended by the end tag of another field
+		{0x7b, 0x84, 0x01},
+		// an end group tag without a group
+		{0x7c},
+		// a length beyond the message
+		{0x0a, 0x05, 'a'},
+		// a wire type which does not exist
+		{0x0e},
+	} {
+		assert.NotNil(t, dynamic.Unmarshal(invalid), "%v", invalid)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	gz, _ := (&grpc2grpc.PetResponse{}).Descriptor()
+	d, err := support.RegisteredDescriptors(gz)
+	assert.Nil(t, err)
+
+	document, err := d.OpenAPI("petstore.proto")
+	assert.Nil(t, err)
+	assert.Equal(t, "3.0.1", document["openapi"])
+	paths := document["paths"].(map[string]interface{})
+	assert.Len(t, paths, 2)
+	operation := paths["/PetStoreService/PetById"].(map[string]interface{})["post"].(map[string]interface{})
+	assert.Equal(t, "PetStoreService_PetById", operation["operationId"])
+	assert.Len(t, operation["parameters"], 1)
+	schemas := document["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	assert.Contains(t, schemas, "grpc2grpc.PetByIdRequest")
+	assert.Contains(t, schemas, "grpc2grpc.Pet")
+
+	options := &descriptor.MethodOptions{}
+	err = proto.SetExtension(options, annotations.E_Http, &annotations.HttpRule{
+		Pattern: &annotations.HttpRule_Get{Get: "/v1/pets/{id=*}"},
+		AdditionalBindings: []*annotations.HttpRule{
+			{Pattern: &annotations.HttpRule_Put{Put: "/v1/pets/{id}"}, Body: "pet"},
+		},
+	})
+	assert.Nil(t, err)
+	d = support.NewDescriptors(&descriptor.FileDescriptorProto{
+		Name:    proto.String("pets.proto"),
+		Package: proto.String("pets"),
+		MessageType: []*descriptor.DescriptorProto{
+			{Name: proto.String("Pet"), Field: []*descriptor.FieldDescriptorProto{
+				{Name: proto.String("name"), Type: descriptor.FieldDescriptorProto_TYPE_STRING.Enum()},
+			}},
+			{Name: proto.String("PetRequest"), Field: []*descriptor.FieldDescriptorProto{
+				{Name: proto.String("id"), Type: descriptor.FieldDescriptorProto_TYPE_INT32.Enum()},
+				{Name: proto.String("verbose"), Type: descriptor.FieldDescriptorProto_TYPE_BOOL.Enum()},
+				{Name: proto.String("pet"), Type: descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(".pets.Pet")},
+			}},
+		},
+		Service: []*descriptor.ServiceDescriptorProto{
+			{Name: proto.String("PetService"), Method: []*descriptor.MethodDescriptorProto{
+				{Name: proto.String("Pet"), InputType: proto.String(".pets.PetRequest"), OutputType: proto.String(".pets.Pet"), Options: options},
+			}},
+		},
+	})
+
+	document, err = d.OpenAPI("pets.proto")
+	assert.Nil(t, err)
+	item := document["paths"].(map[string]interface{})["/v1/pets/{id}"].(map[string]interface{})
+	get := item["get"].(map[string]interface{})
+	assert.Len(t, get["parameters"], 3)
+	assert.Equal(t, "pet.name", get["parameters"].([]interface{})[2].(map[string]interface{})["name"])
+	assert.Nil(t, get["requestBody"])
+	put := item["put"].(map[string]interface{})
+	assert.Equal(t, "PetService_Pet1", put["operationId"])
+	assert.NotNil(t, put["requestBody"])
+}
+
+func TestHarness(t *testing.T) {
+	harness, err := grpc2grpc.NewPetStoreServiceHarness(filepath.FromSlash("./proto/grpc2grpc/petstore.PetStoreService.fixtures.json"))
+	assert.Nil(t, err)
+	addr, err := harness.Start("localhost:0")
+	assert.Nil(t, err)
+	defer harness.Stop()
+
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	assert.Nil(t, err)
+	defer conn.Close()
+	client := grpc2grpc.NewPetStoreServiceHarnessClient(conn)
+	ctx := context.Background()
+
+	res, err := client.PetById(ctx, `{"id": 3}`)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"pet":{"id":3,"name":"cat3"}}`, res)
+	_, err = client.PetById(ctx, `{"id": 9}`)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	users, err := client.ListUsers(ctx, `{}`)
+	assert.Nil(t, err)
+	assert.Len(t, users, 3)
+
+	res, err = client.StoreUsers(ctx, []string{`{"id": 22}`, `{"id": 23}`})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"msg":"users stored"}`, res)
+
+	users, err = client.Call(ctx, "BulkUsers", `{"username": "user22c"}`, `{"username": "user23c"}`)
+	assert.Nil(t, err)
+	assert.Len(t, users, 3)
+
+	_, err = client.Call(ctx, "Unknown")
+	assert.NotNil(t, err)
+}
+
+func TestInvoke(t *testing.T) {
+	harness, err := grpc2grpc.NewPetStoreServiceHarness(filepath.FromSlash("./proto/grpc2grpc/petstore.PetStoreService.fixtures.json"))
+	assert.Nil(t, err)
+	addr, err := harness.Start("localhost:0")
+	assert.Nil(t, err)
+	defer harness.Stop()
+
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	assert.Nil(t, err)
+	defer conn.Close()
+	ctx := context.Background()
+
+	// the petstore.proto files of the proto packages collide in the registry used by the reflection service
+	gz, _ := (&grpc2grpc.PetResponse{}).Descriptor()
+	d, err := support.RegisteredDescriptors(gz)
+	assert.Nil(t, err)
+
+	requests, err := support.ReadRequests(strings.NewReader(`{"id": 2}`))
+	assert.Nil(t, err)
+	result, err := support.Invoke(ctx, conn, d, "PetStoreService/PetById", requests)
+	assert.Nil(t, err)
+	assert.Equal(t, "OK", result.Status.Code)
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"pet": map[string]interface{}{"id": int32(2), "name": "cat2"},
+	}}, result.Responses)
+
+	result, err = support.Invoke(ctx, conn, d, "grpc2grpc.PetStoreService/PetById", []interface{}{map[string]interface{}{"id": 9}})
+	assert.Nil(t, err)
+	assert.Equal(t, "NotFound", result.Status.Code)
+	assert.Equal(t, "Pet not found", result.Status.Message)
+	assert.Len(t, result.Responses, 0)
+
+	result, err = support.Invoke(ctx, conn, d, "PetStoreService/ListUsers", []interface{}{map[string]interface{}{}})
+	assert.Nil(t, err)
+	assert.Len(t, result.Responses, 3)
+
+	requests, err = support.ReadRequests(strings.NewReader("{\"username\": \"user22c\"}\n{\"username\": \"user23c\"}\n"))
+	assert.Nil(t, err)
+	result, err = support.Invoke(ctx, conn, d, "PetStoreService/StoreUsers", requests)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{map[string]interface{}{"msg": "users stored"}}, result.Responses)
+	result, err = support.Invoke(ctx, conn, d, "PetStoreService/BulkUsers", requests)
+	assert.Nil(t, err)
+	assert.Len(t, result.Responses, 3)
+
+	_, err = support.Invoke(ctx, conn, d, "PetStoreService/Unknown", requests)
+	assert.NotNil(t, err)
+}
+
+func TestHTTPTranscoding(t *testing.T) {
+	options := &descriptor.MethodOptions{}
+	err := proto.SetExtension(options, annotations.E_Http, &annotations.HttpRule{
+		Pattern: &annotations.HttpRule_Get{Get: "/v1/{name=shelves/*/pets/*}"},
+		AdditionalBindings: []*annotations.HttpRule{
+			{Pattern: &annotations.HttpRule_Put{Put: "/v1/pets/{id}"}, Body: "pet"},
+			{Pattern: &annotations.HttpRule_Post{Post: "/v1/pets/{id}:tags"}, Body: "*", ResponseBody: "tags"},
+		},
+	})
+	assert.Nil(t, err)
+	d := support.NewDescriptors(&descriptor.FileDescriptorProto{
+		Name:    proto.String("pets.proto"),
+		Package: proto.String("pets"),
+		MessageType: []*descriptor.DescriptorProto{
+			{Name: proto.String("Pet"), Field: []*descriptor.FieldDescriptorProto{
+				{Name: proto.String("name"), Number: proto.Int32(1), Type: descriptor.FieldDescriptorProto_TYPE_STRING.Enum()},
+				{Name: proto.String("tags"), Number: proto.Int32(2), Type: descriptor.FieldDescriptorProto_TYPE_STRING.Enum(), Label: descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum()},
+			}},
+			{Name: proto.String("PetRequest"), Field: []*descriptor.FieldDescriptorProto{
+				{Name: proto.String("id"), Number: proto.Int32(1), Type: descriptor.FieldDescriptorProto_TYPE_INT32.Enum()},
+				{Name: proto.String("verbose"), Number: proto.Int32(2), Type: descriptor.FieldDescriptorProto_TYPE_BOOL.Enum()},
+				{Name: proto.String("pet"), Number: proto.Int32(3), Type: descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(".pets.Pet")},
+				{Name: proto.String("name"), Number: proto.Int32(4), Type: descriptor.FieldDescriptorProto_TYPE_STRING.Enum()},
+			}},
+		},
+		Service: []*descriptor.ServiceDescriptorProto{
+			{Name: proto.String("PetService"), Method: []*descriptor.MethodDescriptorProto{
+				{Name: proto.String("Pet"), InputType: proto.String(".pets.PetRequest"), OutputType: proto.String(".pets.Pet"), Options: options},
+			}},
+		},
+	})
+	bindings := support.HTTPBindings("pets.PetService", d.Method("pets.PetService", "Pet"))
+	assert.Len(t, bindings, 3)
+
+	get, err := support.NewHTTPRoute(bindings[0])
+	assert.Nil(t, err)
+	variables, ok := get.Match("/v1/shelves/1/pets/rex%20jr")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"name": "shelves/1/pets/rex jr"}, variables)
+	_, ok = get.Match("/v1/shelves/1")
+	assert.False(t, ok)
+	msg, err := d.TranscodeRequest(".pets.PetRequest", bindings[0], variables, url.Values{"verbose": {"true"}, "id": {"3"}}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "shelves/1/pets/rex jr", "verbose": true, "id": int32(3)}, msg.Value)
+	// nested fields are set at their dotted path and repeated fields take every value
+	msg, err = d.TranscodeRequest(".pets.PetRequest", bindings[0], variables, url.Values{"pet.name": {"rex"}, "pet.tags": {"good", "old"}, "name": {"ignored"}}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"name": "shelves/1/pets/rex jr",
+		"pet":  map[string]interface{}{"name": "rex", "tags": []interface{}{"good", "old"}},
+	}, msg.Value)
+
+	put, err := support.NewHTTPRoute(bindings[1])
+	assert.Nil(t, err)
+	variables, ok = put.Match("/v1/pets/7")
+	assert.True(t, ok)
+	msg, err = d.TranscodeRequest(".pets.PetRequest", bindings[1], variables, url.Values{"name": {"rex"}}, []byte(`{"name": "rex", "tags": ["good"]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"id":   int32(7),
+		"name": "rex",
+		"pet":  map[string]interface{}{"name": "rex", "tags": []interface{}{"good"}},
+	}, msg.Value)
+	_, err = d.TranscodeRequest(".pets.PetRequest", bindings[1], variables, nil, []byte(`{"owner": "me"}`))
+	assert.NotNil(t, err)
+
+	tags, err := support.NewHTTPRoute(bindings[2])
+	assert.Nil(t, err)
+	_, ok = tags.Match("/v1/pets/7")
+	assert.False(t, ok)
+	variables, ok = tags.Match("/v1/pets/7:tags")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"id": "7"}, variables)
+	b, err := d.TranscodeResponse(".pets.Pet", bindings[2], map[string]interface{}{"name": "rex", "tags": []string{"good", "old"}})
+	assert.Nil(t, err)
+	assert.Equal(t, `["good","old"]`, string(b))
+	b, err = d.TranscodeResponse(".pets.Pet", bindings[0], map[string]interface{}{"name": "rex"})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"name":"rex"}`, string(b))
+}
@@ -0,0 +1,271 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/project-flogo/core/data/coerce"
+	"github.com/project-flogo/core/support/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultAPIKeyHeader  = "x-api-key"
+	defaultAPIKeysReload = 10
+	defaultQuotaInterval = 24 * time.Hour
+)
+
+// ReloadUnit is the duration of one second of the reload intervals of the trigger settings, tests shorten it so that
+// the changed files are read again sooner
+var ReloadUnit = time.Second
+
+type identityKey struct{}
+
+// apiKey is an entry of the key store
+type apiKey struct {
+	identity      string
+	methods       []string
+	quota         int
+	quotaInterval time.Duration
+}
+
+// quotaWindow counts the calls made with a key since the start of its quota interval
+type quotaWindow struct {
+	start time.Time
+	calls int
+}
+
+// apiKeyStore authenticates the callers with the API key of their metadata, the keys of a file are reloaded when the
+// file changes and the calls counted against the quotas survive the reloads
+type apiKeyStore struct {
+	header string
+	file   string
+	reload time.Duration
+	logger log.Logger
+
+	mutex   sync.Mutex
+	keys    map[string]*apiKey
+	modTime time.Time
+	checked time.Time
+	usage   map[string]*quotaWindow
+}
+
+// newAPIKeyStore returns the key store of the apiKeys setting, an object mapping the keys to their entry or the
+// path of a JSON file holding that object, or nil when the setting is not set
+func (t *Trigger) newAPIKeyStore() (*apiKeyStore, error) {
+	if t.settings.APIKeys == nil || t.settings.APIKeys == "" {
+		return nil, nil
+	}
+	s := &apiKeyStore{
+		header: strings.ToLower(t.settings.APIKeyHeader),
+		logger: t.Logger,
+		usage:  make(map[string]*quotaWindow),
+	}
+	if s.header == "" {
+		s.header = defaultAPIKeyHeader
+	}
+
+	if file, ok := t.settings.APIKeys.(string); ok && !strings.HasPrefix(strings.TrimSpace(file), "{") {
+		reload := t.settings.APIKeysReload
+		if reload == 0 {
+			reload = defaultAPIKeysReload
+		}
+		if reload < 0 {
+			return nil, fmt.Errorf("Invalid apiKeysReload [%d]", reload)
+		}
+		s.file, s.reload = strings.TrimPrefix(file, "file://"), time.Duration(reload)*ReloadUnit
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	entries, err := coerce.ToObject(t.settings.APIKeys)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid apiKeys: %s", err.Error())
+	}
+	if s.keys, err = parseAPIKeys(entries); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// parseAPIKeys returns the keys of the key store, each entry has an identity, the methods allowed to the key and a
+// quota of calls per quotaInterval
+func parseAPIKeys(entries map[string]interface{}) (map[string]*apiKey, error) {
+	keys := make(map[string]*apiKey)
+	for name, value := range entries {
+		entry, err := coerce.ToObject(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid entry of API key [%s]", name)
+		}
+		key := &apiKey{quotaInterval: defaultQuotaInterval}
+		key.identity, _ = coerce.ToString(entry["identity"])
+		if key.identity == "" {
+			key.identity = name
+		}
+		if methods, ok := entry["methods"]; ok {
+			list, err := coerce.ToArray(methods)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid methods of API key [%s]", key.identity)
+			}
+			for _, method := range list {
+				m, _ := coerce.ToString(method)
+				key.methods = append(key.methods, m)
+			}
+		}
+		if quota, ok := entry["quota"]; ok {
+			if key.quota, err = coerce.ToInt(quota); err != nil || key.quota < 0 {
+				return nil, fmt.Errorf("Invalid quota of API key [%s]", key.identity)
+			}
+		}
+		if interval, ok := entry["quotaInterval"]; ok {
+			s, _ := coerce.ToString(interval)
+			if key.quotaInterval, err = time.ParseDuration(s); err != nil || key.quotaInterval <= 0 {
+				return nil, fmt.Errorf("Invalid quotaInterval of API key [%s]", key.identity)
+			}
+		}
+		keys[name] = key
+	}
+	return keys, nil
+}
+
+// load reads the keys of the file
+func (s *apiKeyStore) load() error {
+	info, err := os.Stat(s.file)
+	if err != nil {
+		return fmt.Errorf("Error reading API keys [%s]: %s", s.file, err.Error())
+	}
+	data, err := ioutil.ReadFile(s.file)
+	if err != nil {
+		return fmt.Errorf("Error reading API keys [%s]: %s", s.file, err.Error())
+	}
+	var entries map[string]interface{}
+	if err = json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("Invalid API keys [%s]: %s", s.file, err.Error())
+	}
+	keys, err := parseAPIKeys(entries)
+	if err != nil {
+		return err
+	}
+	s.keys, s.modTime = keys, info.ModTime()
+	return nil
+}
+
+// lookup returns the entry of a key, the file is checked for changes at most once per reload interval
+func (s *apiKeyStore) lookup(name string) *apiKey {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.file != "" && time.Since(s.checked) > s.reload {
+		s.checked = time.Now()
+		if info, err := os.Stat(s.file); err == nil && !info.ModTime().Equal(s.modTime) {
+			if err := s.load(); err != nil {
+				// keep the keys until the file is valid again
+				s.logger.Warnf("Reloading API keys failed: %s", err.Error())
+			} else {
+				s.logger.Infof("API keys reloaded from [%s]", s.file)
+			}
+		}
+	}
+	return s.keys[name]
+}
+
+// allows tells whether a method is allowed to a key, entries are patterns of the full method name or of the
+// service and method names, such as grpc2grpc.PetStoreService/PetById, PetStoreService/* or *
+func (k *apiKey) allows(fullMethod string) bool {
+	if len(k.methods) == 0 {
+		return true
+	}
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	shortMethod := fullMethod
+	if index := strings.LastIndex(fullMethod[:strings.Index(fullMethod+"/", "/")], "."); index >= 0 {
+		shortMethod = fullMethod[index+1:]
+	}
+	for _, pattern := range k.methods {
+		pattern = strings.TrimPrefix(pattern, "/")
+		if pattern == "*" {
+			return true
+		}
+		if ok, _ := path.Match(pattern, fullMethod); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, shortMethod); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// count counts a call against the quota of a key and tells whether the quota allows it
+func (s *apiKeyStore) count(name string, key *apiKey) bool {
+	if key.quota == 0 {
+		return true
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	window, ok := s.usage[name]
+	if !ok || now.Sub(window.start) >= key.quotaInterval {
+		window = &quotaWindow{start: now}
+		s.usage[name] = window
+	}
+	if window.calls >= key.quota {
+		return false
+	}
+	window.calls++
+	return true
+}
+
+// authorize checks the API key of the incoming metadata for the method and returns its identity
+func (s *apiKeyStore) authorize(ctx context.Context, fullMethod string) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(s.header)
+	if len(values) == 0 || values[0] == "" {
+		return "", status.Error(codes.Unauthenticated, "missing API key")
+	}
+	key := s.lookup(values[0])
+	if key == nil {
+		return "", status.Error(codes.Unauthenticated, "unknown API key")
+	}
+	if !key.allows(fullMethod) {
+		return "", status.Errorf(codes.PermissionDenied, "method %s not allowed to [%s]", fullMethod, key.identity)
+	}
+	if !s.count(values[0], key) {
+		return "", status.Errorf(codes.ResourceExhausted, "quota of [%s] exceeded", key.identity)
+	}
+	return key.identity, nil
+}
+
+// unaryInterceptor rejects the unary calls without a valid API key before their handler runs
+func (s *apiKeyStore) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	identity, err := s.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(context.WithValue(ctx, identityKey{}, identity), req)
+}
+
+// streamInterceptor rejects the streaming calls without a valid API key before their handler runs
+func (s *apiKeyStore) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	identity, err := s.authorize(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &contextStream{ServerStream: stream, ctx: context.WithValue(stream.Context(), identityKey{}, identity)})
+}
+
+// callIdentity returns the identity of the API key of the call of the grpc data
+func callIdentity(grpcData map[string]interface{}) string {
+	identity, _ := callContext(grpcData).Value(identityKey{}).(string)
+	return identity
+}
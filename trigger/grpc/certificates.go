@@ -0,0 +1,204 @@
+package grpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/project-flogo/core/support/log"
+)
+
+const defaultCertReload = 60
+
+// certificateSource is a certificate setting, with the file it refers to when the certificate is read from a file
+type certificateSource struct {
+	setting string
+	file    string
+	modTime time.Time
+}
+
+func newCertificateSource(setting string) *certificateSource {
+	s := &certificateSource{setting: setting}
+	// the files are the ones decodeCertificate reads
+	switch {
+	case strings.HasPrefix(setting, "file://"):
+		s.file = strings.TrimPrefix(setting, "file://")
+	case strings.HasPrefix(setting, "{"), strings.Contains(setting, ","), isPEM(setting):
+	case strings.Contains(setting, "/") || strings.Contains(setting, "\\"):
+		s.file = setting
+	}
+	return s
+}
+
+// changed tells whether the file of the certificate was modified since it was read
+func (s *certificateSource) changed() bool {
+	if s == nil || s.file == "" {
+		return false
+	}
+	info, err := os.Stat(s.file)
+	return err == nil && !info.ModTime().Equal(s.modTime)
+}
+
+func isPEM(setting string) bool {
+	return strings.HasPrefix(strings.TrimSpace(setting), "-----BEGIN")
+}
+
+// certificateStore holds the certificate of a TLS endpoint and the CA certificate of its clients, the files they are
+// read from are checked for changes at most once per certReload interval and the certificates are reloaded for the
+// next handshakes, the connections already established keep the certificate of their handshake
+type certificateStore struct {
+	cert, key, ca *certificateSource
+	decode        func(string) ([]byte, error)
+	reload        time.Duration
+	logger        log.Logger
+
+	mutex       sync.Mutex
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+	checked     time.Time
+}
+
+// newCertificateStore returns the store of a certificate, its key and the optional CA certificate of the clients
+func (t *Trigger) newCertificateStore(cert, key, ca string) (*certificateStore, error) {
+	reload := t.settings.CertReload
+	if reload == 0 {
+		reload = defaultCertReload
+	}
+	if reload < 0 {
+		return nil, fmt.Errorf("Invalid certReload [%d]", reload)
+	}
+	s := &certificateStore{
+		cert:   newCertificateSource(cert),
+		key:    newCertificateSource(key),
+		decode: t.decodeCertificate,
+		reload: time.Duration(reload) * ReloadUnit,
+		logger: t.Logger,
+	}
+	if ca != "" {
+		s.ca = newCertificateSource(ca)
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	s.checked = time.Now()
+	return s, nil
+}
+
+// read returns the content of a certificate setting and records the modification time of its file
+func (s *certificateStore) read(source *certificateSource) ([]byte, time.Time, error) {
+	var modTime time.Time
+	if source.file != "" {
+		info, err := os.Stat(source.file)
+		if err != nil {
+			return nil, modTime, err
+		}
+		modTime = info.ModTime()
+	}
+	if isPEM(source.setting) {
+		return []byte(source.setting), modTime, nil
+	}
+	content, err := s.decode(source.setting)
+	return content, modTime, err
+}
+
+// load reads the certificates, the previous ones are kept unless all of them are valid
+func (s *certificateStore) load() error {
+	certPEM, certTime, err := s.read(s.cert)
+	if err != nil {
+		return fmt.Errorf("Error decoding server certificate: %s", err.Error())
+	}
+	keyPEM, keyTime, err := s.read(s.key)
+	if err != nil {
+		return fmt.Errorf("Error decoding server key: %s", err.Error())
+	}
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("Invalid server certificate: %s", err.Error())
+	}
+
+	var clientCAs *x509.CertPool
+	var caTime time.Time
+	if s.ca != nil {
+		var caPEM []byte
+		if caPEM, caTime, err = s.read(s.ca); err != nil {
+			return fmt.Errorf("Error decoding client CA certificate: %s", err.Error())
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPEM) {
+			return errors.New("Invalid client CA certificate")
+		}
+		s.ca.modTime = caTime
+	}
+
+	s.certificate, s.clientCAs = &certificate, clientCAs
+	s.cert.modTime, s.key.modTime = certTime, keyTime
+	return nil
+}
+
+// current returns the certificates, reloaded when one of their files changed
+func (s *certificateStore) current() (*tls.Certificate, *x509.CertPool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if time.Since(s.checked) > s.reload {
+		s.checked = time.Now()
+		if s.cert.changed() || s.key.changed() || s.ca.changed() {
+			if err := s.load(); err != nil {
+				// keep the certificates until the files are valid again
+				s.logger.Warnf("Reloading certificates failed: %s", err.Error())
+			} else {
+				s.logger.Info("Certificates reloaded")
+			}
+		}
+	}
+	return s.certificate, s.clientCAs
+}
+
+// tlsConfig returns the TLS configuration of the endpoint, the certificate is looked up at each handshake and the
+// certificate of a client is verified with the current CA certificate, sessions are not resumed then since
+// VerifyPeerCertificate is skipped for the resumed ones
+func (s *certificateStore) tlsConfig() *tls.Config {
+	config := &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			certificate, _ := s.current()
+			return certificate, nil
+		},
+	}
+	if s.ca != nil {
+		config.ClientAuth = tls.RequireAnyClientCert
+		config.VerifyPeerCertificate = s.verifyClient
+		config.SessionTicketsDisabled = true
+	}
+	return config
+}
+
+// verifyClient verifies the certificate chain of a client as tls.RequireAndVerifyClientCert does, with the client CA
+// certificate of the store
+func (s *certificateStore) verifyClient(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	_, clientCAs := s.current()
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("Invalid client certificate: %s", err.Error())
+		}
+		certs[i] = cert
+	}
+	if len(certs) == 0 {
+		return errors.New("No client certificate")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         clientCAs,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(opts)
+	return err
+}
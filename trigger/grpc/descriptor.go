@@ -0,0 +1,49 @@
+package grpc
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/project-flogo/grpc/support"
+)
+
+var descriptorCache = struct {
+	sync.Mutex
+	descriptors map[string]*support.Descriptors
+}{
+	descriptors: make(map[string]*support.Descriptors),
+}
+
+// serviceDescriptors returns the descriptors of the proto file the registered service was generated from
+func serviceDescriptors(service ServerService) (*support.Descriptors, error) {
+	info := service.ServiceInfo()
+	key := info.ProtoName + info.ServiceName
+
+	descriptorCache.Lock()
+	defer descriptorCache.Unlock()
+	if d, ok := descriptorCache.descriptors[key]; ok {
+		return d, nil
+	}
+
+	var gz []byte
+	if ds, ok := service.(DescriptorService); ok {
+		gz = ds.FileDescriptor()
+	}
+	if gz == nil {
+		gz = proto.FileDescriptor(info.ProtoName + ".proto")
+	}
+	if gz == nil {
+		return nil, fmt.Errorf("descriptor not found for proto [%s]", info.ProtoName)
+	}
+
+	d, err := support.RegisteredDescriptors(gz)
+	if err != nil {
+		return nil, err
+	}
+	if _, s := d.Service(info.ServiceName); s == nil {
+		return nil, fmt.Errorf("service [%s] not found in descriptor of proto [%s]", info.ServiceName, info.ProtoName)
+	}
+	descriptorCache.descriptors[key] = d
+	return d, nil
+}
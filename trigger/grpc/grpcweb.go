@@ -0,0 +1,176 @@
+package grpc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/http2"
+)
+
+const (
+	contentTypeGRPC        = "application/grpc"
+	contentTypeGRPCWeb     = "application/grpc-web"
+	contentTypeGRPCWebText = "application/grpc-web-text"
+
+	// grpcWebHeaders are the request headers of gRPC-Web clients allowed by CORS preflights
+	grpcWebHeaders = "content-type,x-grpc-web,x-user-agent,grpc-timeout"
+	// grpcWebExposedHeaders are the response headers read by gRPC-Web clients
+	grpcWebExposedHeaders = "grpc-status,grpc-message,grpc-status-details-bin"
+)
+
+// serveCORS adds the CORS headers for the allowed origins and answers preflight requests, it reports whether the request was answered
+func (t *Trigger) serveCORS(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || !t.allowedOrigin(origin) {
+		return false
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Add("Vary", "Origin")
+	w.Header().Set("Access-Control-Expose-Headers", grpcWebExposedHeaders)
+	if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+		return false
+	}
+
+	headers := grpcWebHeaders
+	if t.settings.AllowedHeaders != "" {
+		headers += "," + t.settings.AllowedHeaders
+	}
+	w.Header().Set("Access-Control-Allow-Methods", http.MethodPost)
+	w.Header().Set("Access-Control-Allow-Headers", headers)
+	w.Header().Set("Access-Control-Max-Age", "600")
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// allowedOrigin reports whether the origin is one of the comma separated allowedOrigins, * allows any origin
+func (t *Trigger) allowedOrigin(origin string) bool {
+	for _, allowed := range strings.Split(t.settings.AllowedOrigins, ",") {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func isGRPCWeb(r *http.Request) bool {
+	return r.Method == http.MethodPost && strings.HasPrefix(r.Header.Get("Content-Type"), contentTypeGRPCWeb)
+}
+
+// serveGRPCWebRequest calls the gRPC server with a gRPC-Web request, with the binary framing or its base64 text encoding
+func (t *Trigger) serveGRPCWebRequest(w http.ResponseWriter, r *http.Request) {
+	contentType := r.Header.Get("Content-Type")
+	text := strings.HasPrefix(contentType, contentTypeGRPCWebText)
+
+	req := r.WithContext(r.Context())
+	req.ProtoMajor, req.ProtoMinor, req.Proto = 2, 0, "HTTP/2.0"
+	req.Header = make(http.Header, len(r.Header))
+	for k, v := range r.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", contentTypeGRPC+strings.TrimPrefix(strings.TrimPrefix(contentType, contentTypeGRPCWebText), contentTypeGRPCWeb))
+	req.Header.Del("Content-Length")
+	if text {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{base64.NewDecoder(base64.StdEncoding, r.Body), r.Body}
+	}
+
+	responseType := contentTypeGRPCWeb + "+proto"
+	if text {
+		responseType = contentTypeGRPCWebText + "+proto"
+	}
+	writer := &grpcWebResponseWriter{writer: w, header: make(http.Header), contentType: responseType, text: text}
+	t.server.ServeHTTP(writer, req)
+	writer.finish()
+}
+
+// grpcWebResponseWriter turns the response of the gRPC server into a gRPC-Web response, the headers of the server
+// are kept apart until the response starts and the trailers are sent as the last frame of the body
+type grpcWebResponseWriter struct {
+	writer      http.ResponseWriter
+	header      http.Header
+	contentType string
+	text        bool
+	started     bool
+	buffer      bytes.Buffer
+}
+
+func (w *grpcWebResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *grpcWebResponseWriter) WriteHeader(code int) {
+	if w.started {
+		return
+	}
+	w.started = true
+	h := w.writer.Header()
+	for k, v := range w.header {
+		if k == "Trailer" || k == "Content-Type" || strings.HasPrefix(k, http2.TrailerPrefix) {
+			continue
+		}
+		h[k] = v
+	}
+	h.Set("Content-Type", w.contentType)
+	w.writer.WriteHeader(code)
+}
+
+func (w *grpcWebResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if w.text {
+		// text responses are encoded when flushed so that each flush is a complete base64 chunk
+		return w.buffer.Write(b)
+	}
+	return w.writer.Write(b)
+}
+
+func (w *grpcWebResponseWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+	if w.buffer.Len() > 0 {
+		w.writer.Write([]byte(base64.StdEncoding.EncodeToString(w.buffer.Bytes())))
+		w.buffer.Reset()
+	}
+	if flusher, ok := w.writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *grpcWebResponseWriter) CloseNotify() <-chan bool {
+	if notifier, ok := w.writer.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+// finish sends the trailers of the gRPC response as a frame flagged with 0x80
+func (w *grpcWebResponseWriter) finish() {
+	var trailers bytes.Buffer
+	keys := make([]string, 0)
+	for k := range w.header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		name := strings.TrimPrefix(k, http2.TrailerPrefix)
+		if name == k && k != "Grpc-Status" && k != "Grpc-Message" && k != "Grpc-Status-Details-Bin" {
+			continue
+		}
+		for _, v := range w.header[k] {
+			trailers.WriteString(strings.ToLower(name) + ": " + v + "\r\n")
+		}
+	}
+
+	frame := make([]byte, 5, 5+trailers.Len())
+	frame[0] = 0x80
+	binary.BigEndian.PutUint32(frame[1:], uint32(trailers.Len()))
+	frame = append(frame, trailers.Bytes()...)
+	w.Write(frame)
+	w.Flush()
+}
@@ -0,0 +1,104 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// healthService is the name of the gRPC health checking service
+const healthService = "grpc.health.v1.Health"
+
+// healthServer serves the grpc.health.v1 protocol with the health of the trigger, for the server as a whole, the
+// empty service name, and for each of its services
+type healthServer struct {
+	lifecycle *lifecycle
+	services  map[string]bool
+}
+
+// registerHealth registers the health checking service for the services of the trigger
+func (t *Trigger) registerHealth(services []ServerService) {
+	h := &healthServer{lifecycle: &t.lifecycle, services: make(map[string]bool)}
+	for _, service := range services {
+		h.services[service.ServiceInfo().ServiceName] = true
+		if d, err := serviceDescriptors(service); err == nil {
+			if name, sd := d.Service(service.ServiceInfo().ServiceName); sd != nil {
+				h.services[name] = true
+			}
+		}
+	}
+	healthpb.RegisterHealthServer(t.server, h)
+}
+
+func (h *healthServer) servingStatus(service string) healthpb.HealthCheckResponse_ServingStatus {
+	if service != "" && !h.services[service] {
+		return healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+	}
+	if health, _ := h.lifecycle.status(); health == healthServing {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
+
+// Check implements healthpb.HealthServer
+func (h *healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	s := h.servingStatus(req.GetService())
+	if s == healthpb.HealthCheckResponse_SERVICE_UNKNOWN {
+		return nil, status.Errorf(codes.NotFound, "unknown service %s", req.GetService())
+	}
+	return &healthpb.HealthCheckResponse{Status: s}, nil
+}
+
+// Watch implements healthpb.HealthServer, the stream ends once the trigger drains so that it does not hold up the
+// stop of the server
+func (h *healthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	var last healthpb.HealthCheckResponse_ServingStatus = -1
+	for {
+		changed, draining := h.lifecycle.watch()
+		if s := h.servingStatus(req.GetService()); s != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: s}); err != nil {
+				return err
+			}
+			last = s
+		}
+		if draining {
+			return nil
+		}
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-changed:
+		}
+	}
+}
+
+// unauthenticatedHealth lets the health checks through without the interceptors of the trigger, like the health
+// handler over HTTP, so that the probes of an orchestrator need no credentials
+func unauthenticatedHealth(unary grpc.UnaryServerInterceptor, stream grpc.StreamServerInterceptor) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	isHealth := func(fullMethod string) bool {
+		return strings.HasPrefix(fullMethod, "/"+healthService+"/")
+	}
+	var u grpc.UnaryServerInterceptor
+	if unary != nil {
+		u = func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if isHealth(info.FullMethod) {
+				return handler(ctx, req)
+			}
+			return unary(ctx, req, info, handler)
+		}
+	}
+	var s grpc.StreamServerInterceptor
+	if stream != nil {
+		s = func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if isHealth(info.FullMethod) {
+				return handler(srv, ss)
+			}
+			return stream(srv, ss, info, handler)
+		}
+	}
+	return u, s
+}
@@ -0,0 +1,445 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/project-flogo/grpc/support"
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	contentTypeEventStream = "text/event-stream"
+	contentTypeNDJSON      = "application/x-ndjson"
+)
+
+// HTTPStreamWriter sends handler data as the messages of a streamed HTTP response, either as Server-Sent Events
+// when the client accepts text/event-stream or as newline delimited JSON, it is passed to the handler in grpcData["streamWriter"]
+type HTTPStreamWriter struct {
+	mutex   sync.Mutex
+	writer  http.ResponseWriter
+	request *http.Request
+	events  bool
+	started bool
+	closed  bool
+}
+
+// NewHTTPStreamWriter creates a writer streaming the response to the request
+func NewHTTPStreamWriter(w http.ResponseWriter, r *http.Request) *HTTPStreamWriter {
+	return &HTTPStreamWriter{
+		writer:  w,
+		request: r,
+		events:  strings.Contains(r.Header.Get("Accept"), contentTypeEventStream),
+	}
+}
+
+// Send writes the data as a message of the response and flushes it to the client
+func (w *HTTPStreamWriter) Send(data interface{}) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return errors.New("stream is closed")
+	}
+	if err := w.request.Context().Err(); err != nil {
+		return status.Error(codes.Canceled, "client went away")
+	}
+	b, err := messageJSON(data)
+	if err != nil {
+		return err
+	}
+	w.start()
+	if w.events {
+		_, err = fmt.Fprintf(w.writer, "data: %s\n\n", b)
+	} else {
+		_, err = fmt.Fprintf(w.writer, "%s\n", b)
+	}
+	if err != nil {
+		return err
+	}
+	w.flush()
+	return nil
+}
+
+// SendReply sends each element of a reply array as a message, any other reply is sent as a single message
+func (w *HTTPStreamWriter) SendReply(data interface{}) error {
+	return sendReply(w, data)
+}
+
+// Fail ends the response with an error, before the first message it is sent with the HTTP status of its code,
+// afterwards as an error event or as a final error object
+func (w *HTTPStreamWriter) Fail(err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return
+	}
+	s, _ := status.FromError(err)
+	if !w.started {
+		writeHTTPError(w.writer, s, 0)
+		return
+	}
+	b, _ := errorJSON(s)
+	if w.events {
+		fmt.Fprintf(w.writer, "event: error\ndata: %s\n\n", b)
+	} else {
+		fmt.Fprintf(w.writer, "%s\n", b)
+	}
+	w.flush()
+}
+
+// Close ends the writer, the response is ended by returning from the HTTP handler
+func (w *HTTPStreamWriter) Close() {
+	w.mutex.Lock()
+	w.closed = true
+	w.mutex.Unlock()
+}
+
+// Context returns the context of the request, which is canceled when the client disconnects
+func (w *HTTPStreamWriter) Context() context.Context {
+	return w.request.Context()
+}
+
+func (w *HTTPStreamWriter) start() {
+	if w.started {
+		return
+	}
+	w.started = true
+	if w.events {
+		w.writer.Header().Set("Content-Type", contentTypeEventStream)
+	} else {
+		w.writer.Header().Set("Content-Type", contentTypeNDJSON)
+	}
+	w.writer.Header().Set("Cache-Control", "no-cache")
+	w.writer.WriteHeader(http.StatusOK)
+}
+
+func (w *HTTPStreamWriter) flush() {
+	if flusher, ok := w.writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// messageJSON marshals messages following the proto3 JSON mapping and any other data as plain JSON
+func messageJSON(data interface{}) ([]byte, error) {
+	if msg, ok := data.(proto.Message); ok {
+		if _, ok := msg.(*support.DynamicMessage); !ok {
+			s, err := (&jsonpb.Marshaler{OrigName: true}).MarshalToString(msg)
+			return []byte(s), err
+		}
+	}
+	return json.Marshal(data)
+}
+
+// writeHTTPError sends the JSON error object of a status, with the HTTP status of its code unless one is given
+func writeHTTPError(w http.ResponseWriter, s *status.Status, httpStatus int) {
+	if httpStatus == 0 {
+		httpStatus = HTTPStatus(s.Code())
+	}
+	b, _ := errorJSON(s)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	w.Write(b)
+}
+
+func errorJSON(s *status.Status) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{"code": s.Code().String(), "message": s.Message()},
+	})
+}
+
+// HTTPStatus maps a gRPC status code to the HTTP status of a response
+func HTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// startHTTP serves the HTTP exposure of the methods on the HTTP port, with the TLS certificate of the trigger when
+// enabled, after the health and metrics handlers selected by the httpHandlers setting
+func (t *Trigger) startHTTP() error {
+	enabled, err := t.enabledHTTPHandlers()
+	if err != nil {
+		return err
+	}
+	rest, err := t.httpHandler()
+	if err != nil {
+		return err
+	}
+	mux := t.statusMux(enabled)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handler, pattern := mux.Handler(r); pattern != "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		rest.ServeHTTP(w, r)
+	})
+	lis, err := net.Listen("tcp", ":"+strconv.Itoa(t.settings.HTTPPort))
+	if err != nil {
+		return err
+	}
+	lis = t.lifecycle.listener(lis)
+	if t.certificates != nil {
+		lis = tls.NewListener(lis, t.certificates.tlsConfig())
+	}
+
+	server := &http.Server{Handler: handler}
+	t.httpServer = server
+	t.serve(fmt.Sprintf("HTTP server on port [%d]", t.settings.HTTPPort), func() error {
+		return server.Serve(lis)
+	})
+	t.Logger.Infof("HTTP server started on port: [%d]", t.settings.HTTPPort)
+	return nil
+}
+
+// httpHandler exposes the streaming methods of the registered services at /{service}/{method}, server streaming
+// methods are called with a POST of the JSON request message and the response messages are streamed as they are sent,
+// client streaming and bidirectional methods are bridged to WebSockets, unary methods are transcoded from their HTTP bindings
+func (t *Trigger) httpHandler() (http.Handler, error) {
+	mux := http.NewServeMux()
+	var routes []*httpRoute
+	protoName := strings.Split(t.settings.ProtoName, ".")[0]
+	for k, service := range ServiceRegistery.ServerServices {
+		info := service.ServiceInfo()
+		if k != protoName+info.ServiceName {
+			continue
+		}
+		d, err := serviceDescriptors(service)
+		if err != nil {
+			return nil, err
+		}
+		fullName, sd := d.Service(info.ServiceName)
+		for _, method := range sd.Method {
+			path := "/" + info.ServiceName + "/" + method.GetName()
+			switch {
+			case !method.GetClientStreaming() && !method.GetServerStreaming():
+				for _, binding := range support.HTTPBindings(fullName, method) {
+					route, err := support.NewHTTPRoute(binding)
+					if err != nil {
+						return nil, err
+					}
+					t.Logger.Infof("Method [%s] exposed over HTTP at [%s %s]", method.GetName(), binding.Verb, binding.Template)
+					routes = append(routes, &httpRoute{
+						HTTPRoute:   route,
+						descriptors: d,
+						fullMethod:  "/" + fullName + "/" + method.GetName(),
+						serviceName: info.ServiceName,
+						methodName:  method.GetName(),
+						inputType:   method.GetInputType(),
+						outputType:  method.GetOutputType(),
+					})
+				}
+			case method.GetClientStreaming():
+				desc, srv, ok := streamHandler(service, t, method.GetName())
+				if !ok {
+					t.Logger.Warnf("Streaming method [%s] not bridged to WebSockets, the support files of service [%s] must be regenerated", method.GetName(), info.ServiceName)
+					continue
+				}
+				t.Logger.Infof("Streaming method [%s] bridged to WebSockets at [%s]", method.GetName(), path)
+				mux.Handle(path, websocket.Server{
+					Handler: t.serveWebSocket(d, fullName, method.GetName(), method.GetInputType(), method.GetOutputType(), method.GetServerStreaming(), desc, srv),
+				})
+			case method.GetServerStreaming():
+				t.Logger.Infof("Streaming method [%s] exposed over HTTP at [%s]", method.GetName(), path)
+				mux.HandleFunc(path, t.serveServerStream(d, fullName, info.ServiceName, method.GetName(), method.GetInputType()))
+			}
+		}
+	}
+	mux.Handle("/", t.serveTranscoded(routes))
+	return mux, nil
+}
+
+// serveServerStream invokes the handler of a server streaming method for an HTTP request
+func (t *Trigger) serveServerStream(d *support.Descriptors, fullServiceName, serviceName, methodName, inputType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writer := NewHTTPStreamWriter(w, r)
+		defer writer.Close()
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, ok := t.readBody(w, r)
+		if !ok {
+			return
+		}
+		req, err := httpRequestMessage(d, inputType, body)
+		if err != nil {
+			writer.Fail(status.Error(codes.InvalidArgument, err.Error()))
+			return
+		}
+
+		grpcData := make(map[string]interface{})
+		grpcData["methodName"] = methodName
+		grpcData["serviceName"] = serviceName
+		grpcData["reqdata"] = req
+		grpcData["streamWriter"] = writer
+
+		stream := &httpServerStream{
+			ctx:    httpCallContext(r),
+			writer: writer,
+			req:    req,
+		}
+		err = t.invokeStream("/"+fullServiceName+"/"+methodName, stream, false, true, func(srv interface{}, stream grpc.ServerStream) error {
+			grpcData["contextdata"] = stream.Context()
+			_, data, err := t.CallHandler(grpcData)
+			if err == nil {
+				err = ReplyError(data)
+			}
+			if err == nil {
+				err = writer.SendReply(data)
+			}
+			return err
+		})
+		if err != nil {
+			t.Logger.Errorf("Streaming method [%s] over HTTP failed: %s", methodName, err.Error())
+			writer.Fail(err)
+		}
+	}
+}
+
+// httpServerStream presents a server streaming call over HTTP to stream interceptors, its request message is
+// the one of the HTTP request and the messages sent are written to the response
+type httpServerStream struct {
+	ctx      context.Context
+	writer   *HTTPStreamWriter
+	req      *support.DynamicMessage
+	received bool
+}
+
+func (s *httpServerStream) SetHeader(md metadata.MD) error {
+	s.writer.mutex.Lock()
+	defer s.writer.mutex.Unlock()
+	if s.writer.started {
+		return errors.New("response already started")
+	}
+	for key, values := range md {
+		for _, value := range values {
+			s.writer.writer.Header().Add(key, value)
+		}
+	}
+	return nil
+}
+
+func (s *httpServerStream) SendHeader(md metadata.MD) error {
+	return s.SetHeader(md)
+}
+
+func (s *httpServerStream) SetTrailer(metadata.MD) {
+}
+
+func (s *httpServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *httpServerStream) SendMsg(m interface{}) error {
+	return s.writer.Send(m)
+}
+
+func (s *httpServerStream) RecvMsg(m interface{}) error {
+	if s.received {
+		return io.EOF
+	}
+	s.received = true
+	if msg, ok := m.(*support.DynamicMessage); ok {
+		msg.Value = s.req.Value
+		return nil
+	}
+	b, err := s.req.Marshal()
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, m.(proto.Message))
+}
+
+// readBody reads the body of a request up to the size of the largest message the server receives, maxRecvMsgSize or
+// 4MB, a larger body is refused with 413 and false is returned once the error is sent
+func (t *Trigger) readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	limit := t.maxRecvMsgSize()
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, int64(limit)))
+	switch {
+	case err != nil && len(body) >= limit:
+		writeHTTPError(w, status.Newf(codes.ResourceExhausted, "request body larger than max (%d)", limit), http.StatusRequestEntityTooLarge)
+		return nil, false
+	case err != nil:
+		writeHTTPError(w, status.New(codes.InvalidArgument, err.Error()), 0)
+		return nil, false
+	}
+	return body, true
+}
+
+// httpRequestMessage reads the request message from the JSON body, its fields are checked against the descriptor
+func httpRequestMessage(d *support.Descriptors, inputType string, body []byte) (*support.DynamicMessage, error) {
+	if len(strings.TrimSpace(string(body))) == 0 {
+		body = []byte("{}")
+	}
+	req, err := d.NewDynamicMessage(inputType)
+	if err != nil {
+		return nil, err
+	}
+	if err = req.UnmarshalJSON(body); err != nil {
+		return nil, err
+	}
+	b, err := req.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	if err = req.Unmarshal(b); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// httpCallContext returns the context of a call made over HTTP, with the request headers as incoming metadata and
+// the HTTP client as peer
+func httpCallContext(r *http.Request) context.Context {
+	ctx := metadata.NewIncomingContext(r.Context(), headerMetadata(r.Header))
+	if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		p := &peer.Peer{Addr: addr}
+		if r.TLS != nil {
+			p.AuthInfo = credentials.TLSInfo{State: *r.TLS}
+		}
+		ctx = peer.NewContext(ctx, p)
+	}
+	return ctx
+}
@@ -0,0 +1,137 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+var (
+	interceptorsMutex  sync.RWMutex
+	unaryInterceptors  = make(map[string]grpc.UnaryServerInterceptor)
+	streamInterceptors = make(map[string]grpc.StreamServerInterceptor)
+)
+
+// RegisterUnaryInterceptor registers a unary server interceptor under a name, triggers listing the name in their
+// interceptors setting apply it to unary calls, registering a name again replaces its interceptor
+func RegisterUnaryInterceptor(name string, interceptor grpc.UnaryServerInterceptor) {
+	interceptorsMutex.Lock()
+	defer interceptorsMutex.Unlock()
+	unaryInterceptors[name] = interceptor
+}
+
+// RegisterStreamInterceptor registers a stream server interceptor under a name, triggers listing the name in their
+// interceptors setting apply it to streaming calls, registering a name again replaces its interceptor
+func RegisterStreamInterceptor(name string, interceptor grpc.StreamServerInterceptor) {
+	interceptorsMutex.Lock()
+	defer interceptorsMutex.Unlock()
+	streamInterceptors[name] = interceptor
+}
+
+// registeredInterceptors returns the interceptors named by the comma separated names in their order,
+// a name may have a unary interceptor, a stream interceptor or both
+func registeredInterceptors(names string) ([]grpc.UnaryServerInterceptor, []grpc.StreamServerInterceptor, error) {
+	interceptorsMutex.RLock()
+	defer interceptorsMutex.RUnlock()
+	var unary []grpc.UnaryServerInterceptor
+	var stream []grpc.StreamServerInterceptor
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		u, hasUnary := unaryInterceptors[name]
+		s, hasStream := streamInterceptors[name]
+		if !hasUnary && !hasStream {
+			return nil, nil, fmt.Errorf("Interceptor [%s] not registered", name)
+		}
+		if hasUnary {
+			unary = append(unary, u)
+		}
+		if hasStream {
+			stream = append(stream, s)
+		}
+	}
+	return unary, stream, nil
+}
+
+// chainUnary returns an interceptor applying the interceptors in order, the first one being the outermost
+func chainUnary(interceptors []grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	switch len(interceptors) {
+	case 0:
+		return nil
+	case 1:
+		return interceptors[0]
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		next := handler
+		for i := len(interceptors) - 1; i > 0; i-- {
+			interceptor, inner := interceptors[i], next
+			next = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, inner)
+			}
+		}
+		return interceptors[0](ctx, req, info, next)
+	}
+}
+
+// chainStream returns an interceptor applying the interceptors in order, the first one being the outermost
+func chainStream(interceptors []grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	switch len(interceptors) {
+	case 0:
+		return nil
+	case 1:
+		return interceptors[0]
+	}
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		next := handler
+		for i := len(interceptors) - 1; i > 0; i-- {
+			interceptor, inner := interceptors[i], next
+			next = func(srv interface{}, stream grpc.ServerStream) error {
+				return interceptor(srv, stream, info, inner)
+			}
+		}
+		return interceptors[0](srv, stream, info, next)
+	}
+}
+
+// invokeUnary calls the handler of a unary method served over HTTP through the interceptors of the trigger
+func (t *Trigger) invokeUnary(ctx context.Context, fullMethod string, req interface{}, handler grpc.UnaryHandler) (interface{}, error) {
+	if t.unaryInterceptor == nil {
+		return handler(ctx, req)
+	}
+	return t.unaryInterceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: fullMethod}, handler)
+}
+
+// invokeStream calls the handler of a streaming method served over HTTP through the interceptors of the trigger
+func (t *Trigger) invokeStream(fullMethod string, stream grpc.ServerStream, clientStreams, serverStreams bool, handler grpc.StreamHandler) error {
+	if t.streamInterceptor == nil {
+		return handler(nil, stream)
+	}
+	info := &grpc.StreamServerInfo{FullMethod: fullMethod, IsClientStream: clientStreams, IsServerStream: serverStreams}
+	return t.streamInterceptor(nil, stream, info, handler)
+}
+
+// contextStream replaces the context of a stream with one carrying the values set by an interceptor
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// callContext returns the context of the call of the grpc data, where the interceptors set their values
+func callContext(grpcData map[string]interface{}) context.Context {
+	if ctx, ok := grpcData["contextdata"].(context.Context); ok {
+		return ctx
+	}
+	if stream, ok := grpcData["strmReq"].(grpc.ServerStream); ok {
+		return stream.Context()
+	}
+	return context.Background()
+}
@@ -0,0 +1,512 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/project-flogo/core/data/coerce"
+	"github.com/project-flogo/core/support/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	// registers the hashes of the supported algorithms
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+const (
+	defaultJWTAlgorithms = "RS256,ES256"
+	defaultJWKSRefresh   = 300
+	// jwksMinRefresh bounds the fetches of the JWKS triggered by tokens signed with an unknown key
+	jwksMinRefresh = 10 * time.Second
+	// jwtLeeway is the clock skew tolerated on the exp and nbf claims
+	jwtLeeway = time.Minute
+)
+
+type claimsKey struct{}
+
+// jwtAlgorithm is a JWS signature algorithm of RSA or ECDSA keys
+type jwtAlgorithm struct {
+	hash crypto.Hash
+	pss  bool
+	ec   bool
+}
+
+var jwtAlgorithms = map[string]jwtAlgorithm{
+	"RS256": {hash: crypto.SHA256},
+	"RS384": {hash: crypto.SHA384},
+	"RS512": {hash: crypto.SHA512},
+	"PS256": {hash: crypto.SHA256, pss: true},
+	"PS384": {hash: crypto.SHA384, pss: true},
+	"PS512": {hash: crypto.SHA512, pss: true},
+	"ES256": {hash: crypto.SHA256, ec: true},
+	"ES384": {hash: crypto.SHA384, ec: true},
+	"ES512": {hash: crypto.SHA512, ec: true},
+}
+
+// jwtVerifier authenticates the callers with the bearer token of the authorization metadata
+type jwtVerifier struct {
+	issuer     string
+	audience   string
+	requireExp bool
+	algorithms map[string]bool
+	keys       []crypto.PublicKey
+	jwks       *jwksCache
+}
+
+// newJWTVerifier returns the verifier configured by the JWT settings of the trigger, or nil when no key is set
+func (t *Trigger) newJWTVerifier() (*jwtVerifier, error) {
+	if t.settings.JWTKeys == "" && t.settings.JWKS == "" {
+		return nil, nil
+	}
+	v := &jwtVerifier{
+		issuer:     t.settings.JWTIssuer,
+		audience:   t.settings.JWTAudience,
+		requireExp: t.settings.JWTRequireExp,
+		algorithms: make(map[string]bool),
+	}
+
+	algorithms := t.settings.JWTAlgorithms
+	if strings.TrimSpace(algorithms) == "" {
+		algorithms = defaultJWTAlgorithms
+	}
+	for _, name := range strings.Split(algorithms, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := jwtAlgorithms[name]; !ok {
+			return nil, fmt.Errorf("Unsupported JWT algorithm [%s]", name)
+		}
+		v.algorithms[name] = true
+	}
+
+	if t.settings.JWTKeys != "" {
+		keys, err := t.decodeCertificate(t.settings.JWTKeys)
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding JWT keys: %s", err.Error())
+		}
+		if v.keys, err = parsePublicKeys(keys); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.settings.JWKS != "" {
+		refresh := t.settings.JWKSRefresh
+		if refresh == 0 {
+			refresh = defaultJWKSRefresh
+		}
+		if refresh < 0 {
+			return nil, fmt.Errorf("Invalid jwksRefresh [%d]", refresh)
+		}
+		v.jwks = &jwksCache{location: t.settings.JWKS, refresh: time.Duration(refresh) * time.Second, logger: t.Logger}
+		if _, err := v.jwks.lookup(""); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// parsePublicKeys returns the public keys and the keys of the certificates of PEM blocks
+func parsePublicKeys(data []byte) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid JWT key: %s", err.Error())
+			}
+			keys = append(keys, key)
+		case "RSA PUBLIC KEY":
+			key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid JWT key: %s", err.Error())
+			}
+			keys = append(keys, key)
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid JWT key certificate: %s", err.Error())
+			}
+			keys = append(keys, cert.PublicKey)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("No public key found in the JWT keys")
+	}
+	return keys, nil
+}
+
+// jwksCache holds the keys of a JWK Set read from a URL or a file, refreshed once they are older than the refresh
+// interval or when a token names an unknown key, at most once per jwksMinRefresh for unknown keys
+type jwksCache struct {
+	location string
+	refresh  time.Duration
+	logger   log.Logger
+
+	mutex   sync.Mutex
+	keys    map[string]crypto.PublicKey
+	unnamed []crypto.PublicKey
+	err     error
+	fetched time.Time
+	// fetching is closed once the fetch in progress ends
+	fetching chan struct{}
+}
+
+// lookup returns the key with the key id, or all the keys when the token has no key id. The JWKS is fetched outside
+// the lock by one call at a time, the other calls are served the cached keys meanwhile unless they wait for a key
+// that is not cached yet
+func (c *jwksCache) lookup(kid string) ([]crypto.PublicKey, error) {
+	c.mutex.Lock()
+	age := time.Since(c.fetched)
+	_, known := c.keys[kid]
+	missing := c.keys == nil || (kid != "" && !known)
+	if c.fetching == nil && (c.keys == nil || age > c.refresh || (missing && age > jwksMinRefresh)) {
+		c.fetching = make(chan struct{})
+		c.fetched = time.Now()
+		go c.update(c.fetching)
+	}
+	if fetching := c.fetching; missing && fetching != nil {
+		c.mutex.Unlock()
+		<-fetching
+		c.mutex.Lock()
+	}
+	defer c.mutex.Unlock()
+
+	if c.keys == nil {
+		return nil, c.err
+	}
+	if kid != "" {
+		if key, ok := c.keys[kid]; ok {
+			return []crypto.PublicKey{key}, nil
+		}
+		return nil, nil
+	}
+	keys := append([]crypto.PublicKey{}, c.unnamed...)
+	for _, key := range c.keys {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// update fetches the JWKS and replaces the cached keys, the keys are kept until the JWKS is available again
+func (c *jwksCache) update(done chan struct{}) {
+	keys, unnamed, err := c.fetch()
+	c.mutex.Lock()
+	if err != nil {
+		c.err = err
+		if c.keys != nil {
+			c.logger.Warnf("Refreshing JWKS [%s] failed: %s", c.location, err.Error())
+		}
+	} else {
+		c.keys, c.unnamed, c.err = keys, unnamed, nil
+	}
+	c.fetching = nil
+	c.mutex.Unlock()
+	close(done)
+}
+
+// fetch reads the keys of the JWKS
+func (c *jwksCache) fetch() (map[string]crypto.PublicKey, []crypto.PublicKey, error) {
+	var data []byte
+	var err error
+	switch {
+	case strings.HasPrefix(c.location, "http://"), strings.HasPrefix(c.location, "https://"):
+		client := &http.Client{Timeout: 10 * time.Second}
+		var resp *http.Response
+		if resp, err = client.Get(c.location); err != nil {
+			return nil, nil, fmt.Errorf("Error fetching JWKS [%s]: %s", c.location, err.Error())
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, nil, fmt.Errorf("Error fetching JWKS [%s]: %s", c.location, resp.Status)
+		}
+		data, err = ioutil.ReadAll(resp.Body)
+	default:
+		data, err = ioutil.ReadFile(strings.TrimPrefix(c.location, "file://"))
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error reading JWKS [%s]: %s", c.location, err.Error())
+	}
+
+	var set struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}
+	if err = json.Unmarshal(data, &set); err != nil {
+		return nil, nil, fmt.Errorf("Invalid JWKS [%s]: %s", c.location, err.Error())
+	}
+	keys := make(map[string]crypto.PublicKey)
+	var unnamed []crypto.PublicKey
+	for _, jwk := range set.Keys {
+		if use, _ := jwk["use"].(string); use != "" && use != "sig" {
+			continue
+		}
+		key, err := parseJWK(jwk)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Invalid JWKS [%s]: %s", c.location, err.Error())
+		}
+		if key == nil {
+			continue
+		}
+		if kid, _ := jwk["kid"].(string); kid != "" {
+			keys[kid] = key
+		} else {
+			unnamed = append(unnamed, key)
+		}
+	}
+	return keys, unnamed, nil
+}
+
+// parseJWK returns the public key of an RSA or EC JSON Web Key, keys of other types are ignored
+func parseJWK(jwk map[string]interface{}) (crypto.PublicKey, error) {
+	param := func(name string) (*big.Int, error) {
+		value, _ := jwk[name].(string)
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("invalid %s parameter of key [%v]", name, jwk["kid"])
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch jwk["kty"] {
+	case "RSA":
+		n, err := param("n")
+		if err != nil {
+			return nil, err
+		}
+		e, err := param("e")
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk["crv"] {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve [%v] of key [%v]", jwk["crv"], jwk["kid"])
+		}
+		x, err := param("x")
+		if err != nil {
+			return nil, err
+		}
+		y, err := param("y")
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("invalid point of key [%v]", jwk["kid"])
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, nil
+}
+
+// authenticate verifies the bearer token of the incoming metadata and returns its claims
+func (v *jwtVerifier) authenticate(ctx context.Context) (map[string]interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var token string
+	for _, value := range md.Get("authorization") {
+		if len(value) > 7 && strings.EqualFold(value[:7], "bearer ") {
+			token = strings.TrimSpace(value[7:])
+			break
+		}
+	}
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	claims, err := v.verify(token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid token: "+err.Error())
+	}
+	return claims, nil
+}
+
+// verify checks the signature and the registered claims of a compact JWS token
+func (v *jwtVerifier) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, errors.New("malformed header")
+	}
+	if !v.algorithms[header.Alg] {
+		return nil, fmt.Errorf("algorithm [%s] not allowed", header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed signature")
+	}
+
+	keys := v.keys
+	if v.jwks != nil {
+		jwksKeys, err := v.jwks.lookup(header.Kid)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(jwksKeys, keys...)
+	}
+	verified := false
+	for _, key := range keys {
+		if verifySignature(jwtAlgorithms[header.Alg], key, parts[0]+"."+parts[1], signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.New("signature not verified")
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, errors.New("malformed claims")
+	}
+	now := time.Now()
+	if _, ok := claims["exp"]; !ok && v.requireExp {
+		return nil, errors.New("missing exp claim")
+	}
+	if exp, ok := claims["exp"]; ok {
+		if seconds, err := coerce.ToFloat64(exp); err != nil || now.After(time.Unix(int64(seconds), 0).Add(jwtLeeway)) {
+			return nil, errors.New("token expired")
+		}
+	}
+	if nbf, ok := claims["nbf"]; ok {
+		if seconds, err := coerce.ToFloat64(nbf); err != nil || now.Add(jwtLeeway).Before(time.Unix(int64(seconds), 0)) {
+			return nil, errors.New("token not valid yet")
+		}
+	}
+	if v.issuer != "" && claims["iss"] != v.issuer {
+		return nil, errors.New("unexpected issuer")
+	}
+	if v.audience != "" {
+		audiences, ok := claims["aud"].([]interface{})
+		if !ok {
+			audiences = []interface{}{claims["aud"]}
+		}
+		found := false
+		for _, audience := range audiences {
+			if audience == v.audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New("unexpected audience")
+		}
+	}
+	return claims, nil
+}
+
+func decodeSegment(segment string, value interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	return decoder.Decode(value)
+}
+
+// verifySignature verifies the signature of the signing input with a key of the type of the algorithm
+func verifySignature(algorithm jwtAlgorithm, key crypto.PublicKey, input string, signature []byte) bool {
+	h := algorithm.hash.New()
+	h.Write([]byte(input))
+	digest := h.Sum(nil)
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if algorithm.ec {
+			return false
+		}
+		if algorithm.pss {
+			return rsa.VerifyPSS(key, algorithm.hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+		}
+		return rsa.VerifyPKCS1v15(key, algorithm.hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if !algorithm.ec || len(signature) != 2*size {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
+
+// unaryInterceptor rejects the unary calls without a valid token before their handler runs
+func (v *jwtVerifier) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	claims, err := v.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(context.WithValue(ctx, claimsKey{}, claims), req)
+}
+
+// streamInterceptor rejects the streaming calls without a valid token before their handler runs
+func (v *jwtVerifier) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	claims, err := v.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &contextStream{ServerStream: stream, ctx: context.WithValue(stream.Context(), claimsKey{}, claims)})
+}
+
+// callClaims returns the verified claims of the call of the grpc data
+func callClaims(grpcData map[string]interface{}) map[string]interface{} {
+	claims, _ := callContext(grpcData).Value(claimsKey{}).(map[string]interface{})
+	return claims
+}
+
+// checkScopes returns PERMISSION_DENIED unless the scope or scp claim grants all the required scopes
+func checkScopes(required string, claims map[string]interface{}) error {
+	granted := make(map[string]bool)
+	for _, name := range []string{"scope", "scp"} {
+		switch value := claims[name].(type) {
+		case string:
+			for _, scope := range strings.Fields(value) {
+				granted[scope] = true
+			}
+		case []interface{}:
+			for _, scope := range value {
+				if s, ok := scope.(string); ok {
+					granted[s] = true
+				}
+			}
+		}
+	}
+	for _, scope := range strings.FieldsFunc(required, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if !granted[scope] {
+			return status.Errorf(codes.PermissionDenied, "missing scope [%s]", scope)
+		}
+	}
+	return nil
+}
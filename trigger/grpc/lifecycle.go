@@ -0,0 +1,244 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultDrainTimeout = 30
+
+	healthServing    = "SERVING"
+	healthNotServing = "NOT_SERVING"
+
+	// drainPollInterval is the interval at which the requests of the multiplexed listeners are checked while draining
+	drainPollInterval = 10 * time.Millisecond
+)
+
+// lifecycle is the state of the servers of the trigger between Start and Stop
+type lifecycle struct {
+	// requests counts the requests in flight on the multiplexed listeners, their connections are hijacked from the
+	// HTTP server which does not wait for them when it shuts down
+	requests int64
+
+	mutex    sync.Mutex
+	health   string
+	draining bool
+	err      error
+	conns    map[net.Conn]struct{}
+	// changed is closed when the health changes
+	changed chan struct{}
+}
+
+func (l *lifecycle) start() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.draining, l.err = false, nil
+	l.setHealth(healthServing)
+	l.conns = make(map[net.Conn]struct{})
+}
+
+func (l *lifecycle) drain() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.draining = true
+	l.setHealth(healthNotServing)
+}
+
+// setHealth changes the health and wakes up its watchers, the mutex is held by the caller
+func (l *lifecycle) setHealth(health string) {
+	l.health = health
+	if l.changed != nil {
+		close(l.changed)
+	}
+	l.changed = make(chan struct{})
+}
+
+// watch returns a channel closed at the next change of the health, and whether the trigger is draining
+func (l *lifecycle) watch() (<-chan struct{}, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.changed == nil {
+		l.changed = make(chan struct{})
+	}
+	return l.changed, l.draining
+}
+
+// fail records the error of a server that stopped serving on its own and tells whether the trigger was not stopping
+func (l *lifecycle) fail(err error) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.draining {
+		return false
+	}
+	if l.err == nil {
+		l.err = err
+	}
+	l.setHealth(healthNotServing)
+	return true
+}
+
+func (l *lifecycle) status() (string, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.health == "" {
+		return healthNotServing, l.err
+	}
+	return l.health, l.err
+}
+
+// track counts a request of the multiplexed listeners until the returned function is called
+func (l *lifecycle) track() func() {
+	atomic.AddInt64(&l.requests, 1)
+	return func() {
+		atomic.AddInt64(&l.requests, -1)
+	}
+}
+
+// drained waits for the requests of the multiplexed listeners to end, or for the context to be done
+func (l *lifecycle) drained(ctx context.Context) {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for atomic.LoadInt64(&l.requests) > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// listener tracks the connections of a listener served by an HTTP server, the HTTP server forgets the connections
+// hijacked by h2c and by the WebSockets and only closeConns closes them
+func (l *lifecycle) listener(lis net.Listener) net.Listener {
+	return &trackedListener{Listener: lis, lifecycle: l}
+}
+
+// closeConns closes the connections left once the drain timeout expires
+func (l *lifecycle) closeConns() {
+	l.mutex.Lock()
+	conns := l.conns
+	l.conns = make(map[net.Conn]struct{})
+	l.mutex.Unlock()
+	for conn := range conns {
+		conn.Close()
+	}
+}
+
+type trackedListener struct {
+	net.Listener
+	lifecycle *lifecycle
+}
+
+func (l *trackedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	tracked := &trackedConn{Conn: conn, lifecycle: l.lifecycle}
+	l.lifecycle.mutex.Lock()
+	l.lifecycle.conns[tracked] = struct{}{}
+	l.lifecycle.mutex.Unlock()
+	return tracked, nil
+}
+
+type trackedConn struct {
+	net.Conn
+	lifecycle *lifecycle
+}
+
+func (c *trackedConn) Close() error {
+	c.lifecycle.mutex.Lock()
+	delete(c.lifecycle.conns, c)
+	c.lifecycle.mutex.Unlock()
+	return c.Conn.Close()
+}
+
+// serve runs a server of the trigger, a server that stops without the trigger being stopped turns the health of the
+// trigger NOT_SERVING and its error is returned by Stop, the trigger interface of the engine has no other way to
+// report it so it only reaches the engine when the engine stops the trigger
+func (t *Trigger) serve(name string, serve func() error) {
+	go func() {
+		err := serve()
+		if err == nil || err == http.ErrServerClosed {
+			return
+		}
+		if t.lifecycle.fail(err) {
+			t.Logger.Errorf("%s stopped serving: %s", name, err.Error())
+		}
+	}()
+}
+
+// drainTimeout returns the time given to the calls in flight to end when the trigger stops
+func (t *Trigger) drainTimeout() time.Duration {
+	if t.settings.DrainTimeout == 0 {
+		return seconds(defaultDrainTimeout)
+	}
+	return seconds(t.settings.DrainTimeout)
+}
+
+// Stop implements trigger.Trigger.Stop, the health of the trigger turns NOT_SERVING, the servers stop accepting
+// connections and send a GOAWAY to their clients, then the calls in flight are given drainTimeout seconds to end
+// before the servers are stopped, the error of a server that failed while the trigger was serving is returned
+func (t *Trigger) Stop() error {
+	t.lifecycle.drain()
+	timeout := t.drainTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	drain := func(f func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f()
+		}()
+	}
+	if t.portServer != nil {
+		// the calls of the multiplexed listeners are served by the gRPC server over HTTP, draining the gRPC server
+		// itself is not supported for them
+		drain(func() {
+			t.portServer.Shutdown(ctx)
+			t.lifecycle.drained(ctx)
+		})
+	} else if t.server != nil {
+		drain(t.server.GracefulStop)
+	}
+	if t.httpServer != nil {
+		drain(func() {
+			t.httpServer.Shutdown(ctx)
+		})
+	}
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		t.Logger.Warnf("Calls still in flight after %s, stopping the server", timeout)
+		t.lifecycle.closeConns()
+	}
+
+	if t.server != nil {
+		t.server.Stop()
+	}
+	if t.portServer != nil {
+		t.portServer.Close()
+	}
+	if t.httpServer != nil {
+		t.httpServer.Close()
+	}
+	if t.policy != nil {
+		t.policy.audit.close()
+	}
+	t.server, t.portServer, t.httpServer = nil, nil, nil
+
+	_, err := t.lifecycle.status()
+	return err
+}
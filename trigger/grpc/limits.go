@@ -0,0 +1,357 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// concurrencyRetryDelay is the delay suggested to the calls refused by a concurrency limit
+	concurrencyRetryDelay = time.Second
+	// callersSweepInterval is the interval at which the limiters of idle callers are removed
+	callersSweepInterval = time.Minute
+	// maxCallers is the number of callers with their own limiter, the callers beyond it share one limiter
+	maxCallers = 10000
+)
+
+// tokenBucket allows rate calls per second with bursts of up to burst calls
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst <= 0 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// take takes a token, or returns the delay until the next one is available
+func (b *tokenBucket) take(now time.Time) (bool, time.Duration) {
+	b.refill(now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// limiter applies a rate limit and a limit of calls in flight, either of them may be unset
+type limiter struct {
+	name          string
+	mutex         sync.Mutex
+	bucket        *tokenBucket
+	maxConcurrent int
+	inFlight      int
+}
+
+func newLimiter(name string, rate float64, burst, maxConcurrent int) (*limiter, error) {
+	if rate < 0 || burst < 0 || maxConcurrent < 0 {
+		return nil, fmt.Errorf("Invalid limits for [%s]", name)
+	}
+	if rate == 0 && maxConcurrent == 0 {
+		return nil, nil
+	}
+	l := &limiter{name: name, maxConcurrent: maxConcurrent}
+	if rate > 0 {
+		l.bucket = newTokenBucket(rate, burst)
+	}
+	return l, nil
+}
+
+// acquire admits a call, the call holds its slot of the concurrency limit until release is called
+func (l *limiter) acquire() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.maxConcurrent > 0 && l.inFlight >= l.maxConcurrent {
+		return retryError(fmt.Sprintf("too many calls in flight for %s", l.name), concurrencyRetryDelay)
+	}
+	if l.bucket != nil {
+		if ok, wait := l.bucket.take(time.Now()); !ok {
+			return retryError(fmt.Sprintf("rate limit of %s exceeded", l.name), wait)
+		}
+	}
+	l.inFlight++
+	return nil
+}
+
+func (l *limiter) release() {
+	l.mutex.Lock()
+	l.inFlight--
+	l.mutex.Unlock()
+}
+
+// refund gives the token of a call refused by another limiter back
+func (l *limiter) refund() {
+	l.mutex.Lock()
+	if l.bucket != nil {
+		l.bucket.tokens = math.Min(l.bucket.burst, l.bucket.tokens+1)
+	}
+	l.mutex.Unlock()
+}
+
+// idle tells whether the limiter has no call in flight and a full bucket
+func (l *limiter) idle(now time.Time) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.inFlight > 0 {
+		return false
+	}
+	if l.bucket != nil {
+		l.bucket.refill(now)
+		return l.bucket.tokens >= l.bucket.burst
+	}
+	return true
+}
+
+// retryError returns a RESOURCE_EXHAUSTED status with the delay after which the call may be retried
+func retryError(message string, delay time.Duration) error {
+	s := status.New(codes.ResourceExhausted, message)
+	if detailed, err := s.WithDetails(&errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(delay)}); err == nil {
+		s = detailed
+	}
+	return s.Err()
+}
+
+// callLimits holds the limiters of the trigger, the global one, the ones of the handlers with limits keyed by
+// serviceName_methodName and the ones of the callers created on their first call
+type callLimits struct {
+	global      *limiter
+	methods     map[string]*limiter
+	callerKey   string
+	callerClaim string
+
+	callerRate          float64
+	callerBurst         int
+	callerMaxConcurrent int
+
+	mutex    sync.Mutex
+	callers  map[string]*limiter
+	overflow *limiter
+	swept    time.Time
+}
+
+// newCallLimits returns the limits of the trigger and of its handlers, or nil when none is set
+func (t *Trigger) newCallLimits() (*callLimits, error) {
+	l := &callLimits{
+		methods:             make(map[string]*limiter),
+		callerKey:           strings.ToLower(t.settings.CallerKey),
+		callerClaim:         t.settings.CallerClaim,
+		callerRate:          t.settings.CallerRateLimit,
+		callerBurst:         t.settings.CallerRateBurst,
+		callerMaxConcurrent: t.settings.CallerMaxConcurrent,
+		callers:             make(map[string]*limiter),
+		swept:               time.Now(),
+	}
+	var err error
+	if l.global, err = newLimiter("the server", t.settings.RateLimit, t.settings.RateBurst, t.settings.MaxConcurrent); err != nil {
+		return nil, err
+	}
+	if l.overflow, err = newLimiter("the other callers", l.callerRate, l.callerBurst, l.callerMaxConcurrent); err != nil {
+		return nil, err
+	}
+	for key, handler := range t.handlers {
+		s := handler.settings
+		method, err := newLimiter(s.ServiceName+"/"+s.MethodName, s.RateLimit, s.RateBurst, s.MaxConcurrent)
+		if err != nil {
+			return nil, err
+		}
+		if method != nil {
+			l.methods[key] = method
+		}
+	}
+	if l.global == nil && len(l.methods) == 0 && l.callerRate == 0 && l.callerMaxConcurrent == 0 {
+		return nil, nil
+	}
+	return l, nil
+}
+
+// admissionKey is the context key of the admission of a call by the global and method limits
+type admissionKey struct{}
+
+// admission holds the limiters that admitted a call
+type admission []*limiter
+
+// admit admits a call against the limiters, the call holds its slots until release is called
+func admit(limiters ...*limiter) (admission, error) {
+	var a admission
+	for _, lim := range limiters {
+		if lim == nil {
+			continue
+		}
+		if err := lim.acquire(); err != nil {
+			a.release()
+			a.refund()
+			return nil, err
+		}
+		a = append(a, lim)
+	}
+	return a, nil
+}
+
+func (a admission) release() {
+	for _, lim := range a {
+		lim.release()
+	}
+}
+
+// refund gives the tokens of a call refused by a later limiter back, its slots are held until release
+func (a admission) refund() {
+	for _, lim := range a {
+		lim.refund()
+	}
+}
+
+// callerLimited tells whether the callers have limits
+func (l *callLimits) callerLimited() bool {
+	return l.callerRate != 0 || l.callerMaxConcurrent != 0
+}
+
+// callerName returns the identity of the caller of a call: the identity of its API key, else the callerClaim claim of
+// its verified token when set, else the value of its callerKey metadata when set, else the address of its peer
+func (l *callLimits) callerName(ctx context.Context) string {
+	if identity, ok := ctx.Value(identityKey{}).(string); ok && identity != "" {
+		return identity
+	}
+	if l.callerClaim != "" {
+		if claims, ok := ctx.Value(claimsKey{}).(map[string]interface{}); ok {
+			if value, ok := claims[l.callerClaim]; ok && value != nil {
+				if caller := fmt.Sprint(value); caller != "" {
+					return caller
+				}
+			}
+		}
+	}
+	if l.callerKey != "" {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if values := md.Get(l.callerKey); len(values) != 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+	var caller string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		caller = p.Addr.String()
+		if host, _, err := net.SplitHostPort(caller); err == nil {
+			caller = host
+		}
+	}
+	return caller
+}
+
+// caller returns the limiter of the caller of a call, the callers beyond maxCallers share the overflow limiter until
+// the limiters of idle callers are removed
+func (l *callLimits) caller(ctx context.Context) *limiter {
+	caller := l.callerName(ctx)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := time.Now()
+	// a full map is swept again at most once per second for new callers
+	if now.Sub(l.swept) > callersSweepInterval || len(l.callers) >= maxCallers && now.Sub(l.swept) > time.Second {
+		l.swept = now
+		for name, lim := range l.callers {
+			if lim.idle(now) {
+				delete(l.callers, name)
+			}
+		}
+	}
+	lim, ok := l.callers[caller]
+	if !ok {
+		if len(l.callers) >= maxCallers {
+			return l.overflow
+		}
+		lim, _ = newLimiter("caller ["+caller+"]", l.callerRate, l.callerBurst, l.callerMaxConcurrent)
+		l.callers[caller] = lim
+	}
+	return lim
+}
+
+// handlerKey returns the serviceName_methodName key of the handlers for a full method name
+func handlerKey(fullMethod string) string {
+	service, method := fullMethod, ""
+	if index := strings.LastIndex(fullMethod, "/"); index >= 0 {
+		service, method = fullMethod[:index], fullMethod[index+1:]
+	}
+	service = strings.TrimPrefix(service, "/")
+	if index := strings.LastIndex(service, "."); index >= 0 {
+		service = service[index+1:]
+	}
+	return service + "_" + method
+}
+
+// unaryInterceptor refuses the unary calls over the global and method limits
+func (l *callLimits) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	a, err := admit(l.global, l.methods[handlerKey(info.FullMethod)])
+	if err != nil {
+		return nil, err
+	}
+	defer a.release()
+	return handler(context.WithValue(ctx, admissionKey{}, a), req)
+}
+
+// streamInterceptor refuses the streaming calls over the global and method limits, a stream holds its slots as long
+// as it is open
+func (l *callLimits) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	a, err := admit(l.global, l.methods[handlerKey(info.FullMethod)])
+	if err != nil {
+		return err
+	}
+	defer a.release()
+	return handler(srv, &contextStream{ServerStream: stream, ctx: context.WithValue(stream.Context(), admissionKey{}, a)})
+}
+
+// admitCaller admits a call against the limits of its caller, a call refused by them does not count against the
+// rate limits of the server and of its method
+func (l *callLimits) admitCaller(ctx context.Context) (admission, error) {
+	a, err := admit(l.caller(ctx))
+	if err != nil {
+		if admitted, ok := ctx.Value(admissionKey{}).(admission); ok {
+			admitted.refund()
+		}
+		return nil, err
+	}
+	return a, nil
+}
+
+// callerUnaryInterceptor refuses the unary calls over the limits of their caller, it runs once the caller is
+// authenticated
+func (l *callLimits) callerUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	a, err := l.admitCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer a.release()
+	return handler(ctx, req)
+}
+
+// callerStreamInterceptor refuses the streaming calls over the limits of their caller, it runs once the caller is
+// authenticated
+func (l *callLimits) callerStreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	a, err := l.admitCaller(stream.Context())
+	if err != nil {
+		return err
+	}
+	defer a.release()
+	return handler(srv, stream)
+}
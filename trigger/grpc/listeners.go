@@ -0,0 +1,216 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/project-flogo/core/data/coerce"
+	"google.golang.org/grpc/credentials"
+)
+
+// listenerConfig is an endpoint served by the trigger, with its own TLS configuration
+type listenerConfig struct {
+	network   string
+	address   string
+	tlsConfig *tls.Config
+}
+
+func (c *listenerConfig) String() string {
+	if c.network == "unix" {
+		return "unix://" + c.address
+	}
+	return "tcp://" + c.address
+}
+
+// parseListeners returns the endpoints of the listeners setting, each entry is an address such as tcp://127.0.0.1:9000
+// or unix:///var/run/gw.sock, or an object with the address and the TLS settings of the endpoint, without the setting
+// the trigger listens on the port with the TLS settings of the trigger
+func (t *Trigger) parseListeners() ([]*listenerConfig, error) {
+	if t.settings.Listeners == nil || t.settings.Listeners == "" {
+		if t.settings.Port == 0 {
+			return nil, errors.New("Either port or listeners must be set")
+		}
+		config := &listenerConfig{network: "tcp", address: ":" + strconv.Itoa(t.settings.Port)}
+		if t.certificates != nil {
+			config.tlsConfig = t.certificates.tlsConfig()
+		}
+		return []*listenerConfig{config}, nil
+	}
+
+	entries, err := coerce.ToArray(t.settings.Listeners)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid listeners: %s", err.Error())
+	}
+	var configs []*listenerConfig
+	for _, entry := range entries {
+		settings, ok := entry.(map[string]interface{})
+		if !ok {
+			settings = map[string]interface{}{"address": entry}
+		}
+		config, err := t.parseListener(settings)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, config)
+	}
+	if len(configs) == 0 {
+		return nil, errors.New("Invalid listeners: no listener")
+	}
+	return configs, nil
+}
+
+// parseListener returns the endpoint of an entry of the listeners setting, a TLS entry without certificate uses the
+// certificate of the trigger and requires client certificates signed by its clientCACert when set
+func (t *Trigger) parseListener(settings map[string]interface{}) (*listenerConfig, error) {
+	address, _ := coerce.ToString(settings["address"])
+	config := &listenerConfig{}
+	switch {
+	case strings.HasPrefix(address, "tcp://"):
+		config.network, config.address = "tcp", strings.TrimPrefix(address, "tcp://")
+	case strings.HasPrefix(address, "unix://"):
+		config.network, config.address = "unix", strings.TrimPrefix(address, "unix://")
+	default:
+		return nil, fmt.Errorf("Invalid listener address [%s], expected tcp://host:port or unix://path", address)
+	}
+
+	enableTLS, _ := coerce.ToBool(settings["enableTLS"])
+	if !enableTLS {
+		return config, nil
+	}
+	serverCert, serverKey := t.settings.ServerCert, t.settings.ServerKey
+	if cert, _ := coerce.ToString(settings["serverCert"]); cert != "" {
+		serverCert = cert
+	}
+	if key, _ := coerce.ToString(settings["serverKey"]); key != "" {
+		serverKey = key
+	}
+	clientCA, _ := coerce.ToString(settings["clientCACert"])
+	certificates, err := t.newCertificateStore(serverCert, serverKey, clientCA)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid TLS settings of listener [%s]: %s", address, err.Error())
+	}
+	config.tlsConfig = certificates.tlsConfig()
+	return config, nil
+}
+
+// listen opens the listeners of the endpoints, a stale Unix socket is removed first while a socket another process
+// still accepts connections on is left in place
+func listen(configs []*listenerConfig) ([]net.Listener, error) {
+	var listeners []net.Listener
+	for _, config := range configs {
+		var err error
+		if config.network == "unix" {
+			err = removeStaleSocket(config.address)
+		}
+		var lis net.Listener
+		if err == nil {
+			lis, err = net.Listen(config.network, config.address)
+		}
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, lis)
+	}
+	return listeners, nil
+}
+
+// removeStaleSocket removes a Unix socket left by a process which exited, a socket accepting connections is in use
+func removeStaleSocket(address string) error {
+	info, err := os.Stat(address)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return nil
+	}
+	conn, err := net.DialTimeout("unix", address, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("Unix socket [%s] is in use", address)
+	}
+	return os.Remove(address)
+}
+
+// handshakeListener marks the connections of a TLS endpoint, the handshake is made by the listenerCredentials
+// of the gRPC server so that calls carry the TLS state of their connection
+type handshakeListener struct {
+	net.Listener
+	config *tls.Config
+}
+
+func (l *handshakeListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &handshakeConn{Conn: conn, config: l.config}, nil
+}
+
+type handshakeConn struct {
+	net.Conn
+	config *tls.Config
+}
+
+// listenerCredentials are the transport credentials of a gRPC server serving plaintext and TLS endpoints,
+// connections accepted by a handshakeListener are secured with the TLS configuration of their endpoint
+type listenerCredentials struct {
+	// allTLS tells whether every endpoint uses TLS, the server has no single security protocol otherwise
+	allTLS bool
+}
+
+// newListenerCredentials returns the credentials of the endpoints
+func newListenerCredentials(configs []*listenerConfig) listenerCredentials {
+	c := listenerCredentials{allTLS: true}
+	for _, config := range configs {
+		if config.tlsConfig == nil {
+			c.allTLS = false
+		}
+	}
+	return c
+}
+
+// ClientHandshake implements credentials.TransportCredentials.ClientHandshake
+func (listenerCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.New("listener credentials are server side only")
+}
+
+// ServerHandshake implements credentials.TransportCredentials.ServerHandshake
+func (listenerCredentials) ServerHandshake(rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	c, ok := rawConn.(*handshakeConn)
+	if !ok {
+		return rawConn, nil, nil
+	}
+	config := c.config.Clone()
+	config.NextProtos = []string{"h2"}
+	conn := tls.Server(c.Conn, config)
+	if err := conn.Handshake(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, credentials.TLSInfo{State: conn.ConnectionState()}, nil
+}
+
+// Info implements credentials.TransportCredentials.Info
+func (c listenerCredentials) Info() credentials.ProtocolInfo {
+	if !c.allTLS {
+		return credentials.ProtocolInfo{}
+	}
+	return credentials.ProtocolInfo{SecurityProtocol: "tls", SecurityVersion: "1.2"}
+}
+
+// Clone implements credentials.TransportCredentials.Clone
+func (c listenerCredentials) Clone() credentials.TransportCredentials {
+	return c
+}
+
+// OverrideServerName implements credentials.TransportCredentials.OverrideServerName
+func (listenerCredentials) OverrideServerName(string) error {
+	return nil
+}
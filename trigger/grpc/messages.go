@@ -0,0 +1,121 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// messageResult is the reply of the handler to a message of a bidirectional stream
+type messageResult struct {
+	data interface{}
+	err  error
+}
+
+// handleMessages invokes the handler once per message of the request stream and sends the replies on the response stream,
+// the stream ends with the first error returned by the handler
+func (t *Trigger) handleMessages(handler *Handler, grpcData map[string]interface{}, reader *ClientStreamReader, writer *ServerStreamWriter) error {
+	parallel := 1
+	if handler.settings.Ordering == OrderingParallel {
+		parallel = handler.settings.MaxParallel
+	}
+
+	streamMetadata := make(map[string]interface{})
+	if md, ok := metadata.FromIncomingContext(reader.stream.Context()); ok {
+		for key, values := range md {
+			streamMetadata[key] = strings.Join(values, ",")
+		}
+	}
+
+	// slots bounds the messages being handled or waiting for their reply to be sent,
+	// pending holds their results in the order of the messages
+	slots := make(chan struct{}, parallel)
+	pending := make(chan chan messageResult, parallel)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		defer close(pending)
+		for sequence := 1; ; sequence++ {
+			select {
+			case slots <- struct{}{}:
+			case <-stop:
+				return
+			}
+			done := make(chan messageResult, 1)
+			msg, err := reader.Read()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				done <- messageResult{err: err}
+			} else {
+				data := make(map[string]interface{}, len(grpcData)+2)
+				for k, v := range grpcData {
+					data[k] = v
+				}
+				data["sequence"] = sequence
+				data["metadata"] = streamMetadata
+				go func() {
+					done <- t.handleMessage(handler, data, msg)
+				}()
+			}
+			select {
+			case pending <- done:
+			case <-stop:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for done := range pending {
+		result := <-done
+		if result.err == nil {
+			result.err = writer.SendReply(result.data)
+		}
+		<-slots
+		if result.err != nil {
+			t.Logger.Errorf("Stream of method [%s] ended: %s", handler.settings.MethodName, result.err.Error())
+			return result.err
+		}
+	}
+	return nil
+}
+
+// handleMessage invokes the handler with a message of a bidirectional stream
+func (t *Trigger) handleMessage(handler *Handler, grpcData map[string]interface{}, msg interface{}) messageResult {
+	params, content, err := t.requestData(msg)
+	if err != nil {
+		return messageResult{err: err}
+	}
+	if t.policy != nil {
+		if err = t.policy.authorize(grpcData, content); err != nil {
+			t.Logger.Errorf("Message [%d] of method [%s] denied: %s", grpcData["sequence"], grpcData["methodName"], err.Error())
+			return messageResult{err: err}
+		}
+	}
+	out := &Output{
+		Params:   params,
+		GrpcData: grpcData,
+		Content:  content,
+	}
+
+	t.Logger.Debugf("Calling handler with message [%d] of stream", grpcData["sequence"])
+	results, err := handler.handler.Handle(context.Background(), out)
+	if err != nil {
+		return messageResult{err: err}
+	}
+	reply := &Reply{}
+	if err = reply.FromMap(results); err != nil {
+		return messageResult{err: err}
+	}
+	if err = ReplyError(reply.Data); err != nil {
+		return messageResult{err: err}
+	}
+	return messageResult{data: reply.Data}
+}
@@ -5,17 +5,87 @@ import (
 )
 
 type Settings struct {
-	Port       int    `md:"port,required"`
+	Port       int    `md:"port"`
 	ProtoName  string `md:"protoName,required"`
 	ProtoFile  string `md:"protoFile"`
 	EnableTLS  bool   `md:"enableTLS"`
 	ServerCert string `md:"serverCert"`
 	ServerKey  string `md:"serverKey"`
+	HTTPPort   int    `md:"httpPort"`
+
+	GRPCWeb        bool   `md:"grpcWeb"`
+	AllowedOrigins string `md:"allowedOrigins"`
+	AllowedHeaders string `md:"allowedHeaders"`
+
+	Multiplex    bool   `md:"multiplex"`
+	HTTPHandlers string `md:"httpHandlers"`
+
+	Listeners    interface{} `md:"listeners"`
+	Interceptors string      `md:"interceptors"`
+	Reflection   bool        `md:"reflection"`
+
+	JWTIssuer     string `md:"jwtIssuer"`
+	JWTAudience   string `md:"jwtAudience"`
+	JWTAlgorithms string `md:"jwtAlgorithms"`
+	JWTKeys       string `md:"jwtKeys"`
+	JWKS          string `md:"jwks"`
+	JWKSRefresh   int    `md:"jwksRefresh"`
+	JWTRequireExp bool   `md:"jwtRequireExp"`
+
+	APIKeys       interface{} `md:"apiKeys"`
+	APIKeyHeader  string      `md:"apiKeyHeader"`
+	APIKeysReload int         `md:"apiKeysReload"`
+
+	PolicyFile string `md:"policyFile"`
+	AuditLog   string `md:"auditLog"`
+
+	RateLimit           float64 `md:"rateLimit"`
+	RateBurst           int     `md:"rateBurst"`
+	MaxConcurrent       int     `md:"maxConcurrent"`
+	CallerKey           string  `md:"callerKey"`
+	CallerClaim         string  `md:"callerClaim"`
+	CallerRateLimit     float64 `md:"callerRateLimit"`
+	CallerRateBurst     int     `md:"callerRateBurst"`
+	CallerMaxConcurrent int     `md:"callerMaxConcurrent"`
+
+	AdaptiveShedding bool `md:"adaptiveShedding"`
+	SheddingLatency  int  `md:"sheddingLatency"`
+	SheddingMinLimit int  `md:"sheddingMinLimit"`
+	SheddingMaxLimit int  `md:"sheddingMaxLimit"`
+
+	MaxRecvMsgSize               int  `md:"maxRecvMsgSize"`
+	MaxSendMsgSize               int  `md:"maxSendMsgSize"`
+	MaxConcurrentStreams         int  `md:"maxConcurrentStreams"`
+	KeepaliveTime                int  `md:"keepaliveTime"`
+	KeepaliveTimeout             int  `md:"keepaliveTimeout"`
+	KeepaliveMinTime             int  `md:"keepaliveMinTime"`
+	KeepalivePermitWithoutStream bool `md:"keepalivePermitWithoutStream"`
+	MaxConnectionIdle            int  `md:"maxConnectionIdle"`
+	MaxConnectionAge             int  `md:"maxConnectionAge"`
+	MaxConnectionAgeGrace        int  `md:"maxConnectionAgeGrace"`
+	ConnectionTimeout            int  `md:"connectionTimeout"`
+	InitialWindowSize            int  `md:"initialWindowSize"`
+	InitialConnWindowSize        int  `md:"initialConnWindowSize"`
+
+	DrainTimeout int `md:"drainTimeout"`
+	CertReload   int `md:"certReload"`
 }
 
 type HandlerSettings struct {
 	ServiceName string `md:"serviceName"`
 	MethodName  string `md:"methodName"`
+	StreamMode  string `md:"streamMode"`
+	MaxMessages int    `md:"maxMessages"`
+	MaxBytes    int    `md:"maxBytes"`
+	Ordering    string `md:"ordering"`
+	MaxParallel int    `md:"maxParallel"`
+
+	RequiredScopes string `md:"requiredScopes"`
+
+	RateLimit     float64 `md:"rateLimit"`
+	RateBurst     int     `md:"rateBurst"`
+	MaxConcurrent int     `md:"maxConcurrent"`
+	Priority      string  `md:"priority"`
 }
 
 type Output struct {
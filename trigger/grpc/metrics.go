@@ -0,0 +1,136 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+type methodKey struct{}
+
+// serverMetrics counts the calls of the gRPC server per method and status code, it is installed as the stats handler
+// of the server and serves the counters in the Prometheus text format
+type serverMetrics struct {
+	mutex    sync.Mutex
+	started  map[string]int64
+	handled  map[[2]string]int64
+	seconds  map[string]float64
+	inFlight map[string]int64
+	shedder  *adaptiveLimiter
+}
+
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{
+		started:  make(map[string]int64),
+		handled:  make(map[[2]string]int64),
+		seconds:  make(map[string]float64),
+		inFlight: make(map[string]int64),
+	}
+}
+
+// TagRPC implements stats.Handler.TagRPC
+func (m *serverMetrics) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, methodKey{}, strings.TrimPrefix(info.FullMethodName, "/"))
+}
+
+// HandleRPC implements stats.Handler.HandleRPC
+func (m *serverMetrics) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	method, _ := ctx.Value(methodKey{}).(string)
+	switch s := s.(type) {
+	case *stats.Begin:
+		m.mutex.Lock()
+		m.started[method]++
+		m.inFlight[method]++
+		m.mutex.Unlock()
+	case *stats.End:
+		code := status.Code(s.Error).String()
+		m.mutex.Lock()
+		m.handled[[2]string{method, code}]++
+		m.seconds[method] += s.EndTime.Sub(s.BeginTime).Seconds()
+		m.inFlight[method]--
+		m.mutex.Unlock()
+	}
+}
+
+// TagConn implements stats.Handler.TagConn
+func (m *serverMetrics) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+// HandleConn implements stats.Handler.HandleConn
+func (m *serverMetrics) HandleConn(ctx context.Context, s stats.ConnStats) {
+}
+
+// ServeHTTP writes the counters in the Prometheus text format
+func (m *serverMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintln(w, "# HELP grpc_server_started_total Total number of calls started on the server.")
+	fmt.Fprintln(w, "# TYPE grpc_server_started_total counter")
+	for _, method := range sortedKeys(m.started) {
+		fmt.Fprintf(w, "grpc_server_started_total{%s} %d\n", methodLabels(method), m.started[method])
+	}
+
+	fmt.Fprintln(w, "# HELP grpc_server_handled_total Total number of calls completed on the server, by status code.")
+	fmt.Fprintln(w, "# TYPE grpc_server_handled_total counter")
+	keys := make([][2]string, 0, len(m.handled))
+	for key := range m.handled {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i][0] < keys[j][0] || (keys[i][0] == keys[j][0] && keys[i][1] < keys[j][1])
+	})
+	for _, key := range keys {
+		fmt.Fprintf(w, "grpc_server_handled_total{%s,grpc_code=%q} %d\n", methodLabels(key[0]), key[1], m.handled[key])
+	}
+
+	fmt.Fprintln(w, "# HELP grpc_server_handling_seconds_total Total time spent handling calls on the server.")
+	fmt.Fprintln(w, "# TYPE grpc_server_handling_seconds_total counter")
+	for _, method := range sortedKeys(m.started) {
+		fmt.Fprintf(w, "grpc_server_handling_seconds_total{%s} %g\n", methodLabels(method), m.seconds[method])
+	}
+
+	fmt.Fprintln(w, "# HELP grpc_server_in_flight Number of calls being handled by the server.")
+	fmt.Fprintln(w, "# TYPE grpc_server_in_flight gauge")
+	for _, method := range sortedKeys(m.started) {
+		fmt.Fprintf(w, "grpc_server_in_flight{%s} %d\n", methodLabels(method), m.inFlight[method])
+	}
+
+	if m.shedder != nil {
+		limit, shed := m.shedder.currentLimit()
+		fmt.Fprintln(w, "# HELP grpc_server_concurrency_limit Current limit of calls in flight of the adaptive load shedding.")
+		fmt.Fprintln(w, "# TYPE grpc_server_concurrency_limit gauge")
+		fmt.Fprintf(w, "grpc_server_concurrency_limit %g\n", limit)
+		fmt.Fprintln(w, "# HELP grpc_server_shed_total Total number of calls shed by the adaptive load shedding.")
+		fmt.Fprintln(w, "# TYPE grpc_server_shed_total counter")
+		for _, method := range sortedKeys(shed) {
+			fmt.Fprintf(w, "grpc_server_shed_total{%s} %d\n", methodLabels(method), shed[method])
+		}
+	}
+}
+
+func sortedKeys(values map[string]int64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// methodLabels splits a full method name into its service and method labels
+func methodLabels(fullMethod string) string {
+	service, method := fullMethod, ""
+	if index := strings.LastIndex(fullMethod, "/"); index >= 0 {
+		service, method = fullMethod[:index], fullMethod[index+1:]
+	}
+	return fmt.Sprintf("grpc_service=%q,grpc_method=%q", service, method)
+}
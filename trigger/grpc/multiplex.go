@@ -0,0 +1,190 @@
+package grpc
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+const (
+	// HTTPHandlerHealth answers GET /health with the serving status of the trigger
+	HTTPHandlerHealth = "health"
+	// HTTPHandlerMetrics serves the call counters of the gRPC server at /metrics in the Prometheus text format
+	HTTPHandlerMetrics = "metrics"
+	// HTTPHandlerREST serves the transcoded routes and the HTTP exposure of the streaming methods
+	HTTPHandlerREST = "rest"
+)
+
+var (
+	registeredHandlersMutex sync.RWMutex
+	registeredHandlers      = make(map[string]http.Handler)
+	registeredMux           = http.NewServeMux()
+)
+
+// RegisterHTTPHandler serves the handler for the pattern on the ports multiplexing HTTP and gRPC, so that a trigger
+// co-hosted in the same engine shares the port, registering a pattern again replaces its handler
+func RegisterHTTPHandler(pattern string, handler http.Handler) {
+	registeredHandlersMutex.Lock()
+	defer registeredHandlersMutex.Unlock()
+	registeredHandlers[pattern] = handler
+	// a ServeMux does not replace patterns
+	registeredMux = http.NewServeMux()
+	for p, h := range registeredHandlers {
+		registeredMux.Handle(p, h)
+	}
+}
+
+// registeredHandler returns the registered handler for the request
+func registeredHandler(r *http.Request) (http.Handler, bool) {
+	registeredHandlersMutex.RLock()
+	defer registeredHandlersMutex.RUnlock()
+	handler, pattern := registeredMux.Handler(r)
+	return handler, pattern != ""
+}
+
+// serveMultiplexed serves gRPC and HTTP on the listener of the trigger, requests with the application/grpc content type
+// over HTTP/2 are calls of the gRPC server, gRPC-Web requests are translated to calls when enabled and any other request
+// is served by the HTTP handlers
+func (t *Trigger) serveMultiplexed(listeners []net.Listener) error {
+	var httpHandler http.Handler
+	if t.settings.Multiplex {
+		var err error
+		httpHandler, err = t.multiplexedHandler()
+		if err != nil {
+			return err
+		}
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer t.lifecycle.track()()
+		if t.settings.GRPCWeb {
+			if t.serveCORS(w, r) {
+				return
+			}
+			if isGRPCWeb(r) {
+				t.serveGRPCWebRequest(w, r)
+				return
+			}
+		}
+		if httpHandler != nil && (r.ProtoMajor != 2 || !strings.HasPrefix(r.Header.Get("Content-Type"), contentTypeGRPC)) {
+			httpHandler.ServeHTTP(w, r)
+			return
+		}
+		t.server.ServeHTTP(w, r)
+	}))
+
+	// native gRPC clients speak HTTP/2 without TLS on plaintext endpoints, the HTTP/2 server is the one of the port
+	// server so that its connections are sent a GOAWAY when the port server shuts down
+	h2s := &http2.Server{}
+	server := &http.Server{Handler: h2c.NewHandler(handler, h2s)}
+	t.tuneMultiplexed(server, h2s)
+	if err := http2.ConfigureServer(server, h2s); err != nil {
+		return err
+	}
+	t.portServer = server
+	for i, lis := range listeners {
+		lis = t.lifecycle.listener(lis)
+		if config := t.listenerConfigs[i].tlsConfig; config != nil {
+			config = config.Clone()
+			config.NextProtos = []string{"h2", "http/1.1"}
+			lis = tls.NewListener(lis, config)
+		}
+		lis := lis
+		t.serve(fmt.Sprintf("Server on [%s]", t.listenerConfigs[i]), func() error {
+			return server.Serve(lis)
+		})
+	}
+	if t.settings.Multiplex {
+		t.Logger.Info("HTTP multiplexed with gRPC on the listeners of the trigger")
+	}
+	if t.settings.GRPCWeb {
+		t.Logger.Info("gRPC-Web enabled on the listeners of the trigger")
+	}
+	return nil
+}
+
+// enabledHTTPHandlers returns the HTTP handlers selected by the httpHandlers setting, all of them when it is empty
+func (t *Trigger) enabledHTTPHandlers() (map[string]bool, error) {
+	enabled := map[string]bool{HTTPHandlerHealth: true, HTTPHandlerMetrics: true, HTTPHandlerREST: true}
+	if strings.TrimSpace(t.settings.HTTPHandlers) != "" {
+		enabled = make(map[string]bool)
+		for _, name := range strings.Split(t.settings.HTTPHandlers, ",") {
+			name = strings.TrimSpace(name)
+			switch name {
+			case HTTPHandlerHealth, HTTPHandlerMetrics, HTTPHandlerREST:
+				enabled[name] = true
+			default:
+				return nil, fmt.Errorf("Unknown HTTP handler [%s]", name)
+			}
+		}
+	}
+	return enabled, nil
+}
+
+// statusMux returns the mux of the health and metrics handlers that are enabled
+func (t *Trigger) statusMux(enabled map[string]bool) *http.ServeMux {
+	mux := http.NewServeMux()
+	if enabled[HTTPHandlerHealth] {
+		mux.HandleFunc("/health", t.serveHealth)
+	}
+	if enabled[HTTPHandlerMetrics] {
+		mux.Handle("/metrics", t.metrics)
+	}
+	return mux
+}
+
+// multiplexedHandler returns the HTTP handlers selected by the httpHandlers setting followed by the handlers
+// registered with RegisterHTTPHandler
+func (t *Trigger) multiplexedHandler() (http.Handler, error) {
+	enabled, err := t.enabledHTTPHandlers()
+	if err != nil {
+		return nil, err
+	}
+	mux := t.statusMux(enabled)
+	var rest http.Handler
+	if enabled[HTTPHandlerREST] {
+		rest, err = t.httpHandler()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handler, pattern := mux.Handler(r); pattern != "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if handler, ok := registeredHandler(r); ok {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if rest != nil {
+			rest.ServeHTTP(w, r)
+			return
+		}
+		http.NotFound(w, r)
+	}), nil
+}
+
+// serveHealth reports whether the trigger is serving, a trigger that is draining or whose server failed answers
+// NOT_SERVING with a 503 status
+func (t *Trigger) serveHealth(w http.ResponseWriter, r *http.Request) {
+	health, err := t.lifecycle.status()
+	body := map[string]interface{}{"status": health}
+	if err != nil {
+		body["error"] = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if health != healthServing {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	b, _ := json.Marshal(body)
+	w.Write(b)
+}
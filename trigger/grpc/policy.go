@@ -0,0 +1,289 @@
+package grpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/project-flogo/core/data"
+	"github.com/project-flogo/core/data/coerce"
+	"github.com/project-flogo/core/data/expression"
+	"github.com/project-flogo/core/data/resolve"
+	"github.com/project-flogo/core/support/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	// registers the factory of script expressions
+	_ "github.com/project-flogo/core/data/expression/script"
+)
+
+const (
+	// PolicyAllow is the decision of the calls granted by the policy
+	PolicyAllow = "allow"
+	// PolicyDeny is the decision of the calls refused by the policy
+	PolicyDeny = "deny"
+)
+
+// policyRule is a condition required for the calls of the methods it matches
+type policyRule struct {
+	name        string
+	serviceName string
+	methodName  string
+	condition   string
+	expr        expression.Expr
+}
+
+// policy authorizes the calls with the rules of the policy file, a call is allowed when all the rules matching its
+// method hold, calls of methods without rules get the default decision
+type policy struct {
+	rules        []*policyRule
+	defaultAllow bool
+	audit        *auditLog
+}
+
+// loadPolicy returns the policy of the policyFile setting, or nil when it is not set
+func (t *Trigger) loadPolicy() (*policy, error) {
+	if t.settings.PolicyFile == "" {
+		return nil, nil
+	}
+	file := strings.TrimPrefix(t.settings.PolicyFile, "file://")
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading policy [%s]: %s", file, err.Error())
+	}
+	var config struct {
+		Default string `json:"default"`
+		Rules   []struct {
+			Name        string `json:"name"`
+			ServiceName string `json:"serviceName"`
+			MethodName  string `json:"methodName"`
+			Condition   string `json:"condition"`
+		} `json:"rules"`
+	}
+	if err = json.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("Invalid policy [%s]: %s", file, err.Error())
+	}
+
+	p := &policy{}
+	switch config.Default {
+	case "", PolicyAllow:
+		p.defaultAllow = true
+	case PolicyDeny:
+	default:
+		return nil, fmt.Errorf("Invalid default decision [%s] of policy [%s]", config.Default, file)
+	}
+	factory := expression.NewFactory(resolve.GetBasicResolver())
+	for i, r := range config.Rules {
+		rule := &policyRule{name: r.Name, serviceName: r.ServiceName, methodName: r.MethodName, condition: r.Condition}
+		if rule.name == "" {
+			rule.name = fmt.Sprintf("rule%d", i+1)
+		}
+		if rule.condition == "" {
+			return nil, fmt.Errorf("No condition for policy rule [%s]", rule.name)
+		}
+		if rule.expr, err = factory.NewExpr(rule.condition); err != nil {
+			return nil, fmt.Errorf("Invalid condition of policy rule [%s]: %s", rule.name, err.Error())
+		}
+		p.rules = append(p.rules, rule)
+	}
+
+	if p.audit, err = openAuditLog(t.settings.AuditLog, t.Logger); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// matches tells whether the rule applies to a method, the names are patterns where empty matches any name
+func (r *policyRule) matches(serviceName, methodName string) bool {
+	if r.serviceName != "" {
+		if ok, _ := path.Match(r.serviceName, serviceName); !ok {
+			return false
+		}
+	}
+	if r.methodName != "" {
+		if ok, _ := path.Match(r.methodName, methodName); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// authorize evaluates the rules matching the method of the call and audits the decision, the conditions see the
+// serviceName, methodName, metadata, peer, claims, identity and request of the call, a nil request is a request
+// stream received by the handler which is left out of the values so that the rules referring to it deny the call
+func (p *policy) authorize(grpcData map[string]interface{}, request interface{}) error {
+	serviceName, _ := grpcData["serviceName"].(string)
+	methodName, _ := grpcData["methodName"].(string)
+	values := policyValues(grpcData, request)
+	scope := data.NewSimpleScope(values, nil)
+
+	entry := &auditEntry{
+		Time:        time.Now().UTC().Format(time.RFC3339Nano),
+		ServiceName: serviceName,
+		MethodName:  methodName,
+		Identity:    values["identity"].(string),
+		Peer:        values["peer"].(map[string]interface{})["address"].(string),
+	}
+	if subject, ok := values["claims"].(map[string]interface{})["sub"].(string); ok {
+		entry.Subject = subject
+	}
+
+	matched := false
+	for _, rule := range p.rules {
+		if !rule.matches(serviceName, methodName) {
+			continue
+		}
+		matched = true
+		entry.Rules = append(entry.Rules, rule.name)
+		result, err := rule.expr.Eval(scope)
+		if err == nil {
+			var allowed bool
+			if allowed, err = coerce.ToBool(result); err == nil && !allowed {
+				err = errors.New("condition not met")
+			}
+		}
+		if err != nil {
+			entry.Decision, entry.Rule, entry.Reason = PolicyDeny, rule.name, err.Error()
+			p.audit.write(entry)
+			return status.Errorf(codes.PermissionDenied, "denied by policy rule [%s]", rule.name)
+		}
+	}
+	if !matched && !p.defaultAllow {
+		entry.Decision, entry.Reason = PolicyDeny, "no rule for the method"
+		p.audit.write(entry)
+		return status.Error(codes.PermissionDenied, "denied by policy")
+	}
+	entry.Decision = PolicyAllow
+	p.audit.write(entry)
+	return nil
+}
+
+// policyValues returns the values the conditions are evaluated with, every value but a nil request is set so that
+// conditions referring to an absent one evaluate instead of failing to resolve
+func policyValues(grpcData map[string]interface{}, request interface{}) map[string]interface{} {
+	ctx := callContext(grpcData)
+
+	md := make(map[string]interface{})
+	if incoming, ok := metadata.FromIncomingContext(ctx); ok {
+		for key, values := range incoming {
+			if len(values) > 0 {
+				md[key] = values[0]
+			}
+		}
+	}
+
+	peerValues := map[string]interface{}{"address": "", "commonName": "", "dnsNames": []interface{}{}}
+	if p, ok := peer.FromContext(ctx); ok {
+		if p.Addr != nil {
+			peerValues["address"] = p.Addr.String()
+		}
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.PeerCertificates) > 0 {
+			cert := info.State.PeerCertificates[0]
+			peerValues["commonName"] = cert.Subject.CommonName
+			names := make([]interface{}, len(cert.DNSNames))
+			for i, name := range cert.DNSNames {
+				names[i] = name
+			}
+			peerValues["dnsNames"] = names
+		}
+	}
+
+	claims, _ := grpcData["claims"].(map[string]interface{})
+	if claims == nil {
+		claims = make(map[string]interface{})
+	}
+	identity, _ := grpcData["identity"].(string)
+	values := map[string]interface{}{
+		"serviceName": grpcData["serviceName"],
+		"methodName":  grpcData["methodName"],
+		"metadata":    md,
+		"peer":        peerValues,
+		"claims":      claims,
+		"identity":    identity,
+	}
+	if request != nil {
+		values["request"] = request
+	}
+	return values
+}
+
+// auditEntry is a line of the audit log
+type auditEntry struct {
+	Time        string   `json:"time"`
+	ServiceName string   `json:"serviceName"`
+	MethodName  string   `json:"methodName"`
+	Decision    string   `json:"decision"`
+	Rule        string   `json:"rule,omitempty"`
+	Reason      string   `json:"reason,omitempty"`
+	Rules       []string `json:"rules,omitempty"`
+	Identity    string   `json:"identity,omitempty"`
+	Subject     string   `json:"subject,omitempty"`
+	Peer        string   `json:"peer,omitempty"`
+}
+
+// auditLog writes the policy decisions as JSON lines to a file, or to the logger of the trigger
+type auditLog struct {
+	file   string
+	mutex  sync.Mutex
+	writer io.WriteCloser
+	logger log.Logger
+}
+
+func openAuditLog(file string, logger log.Logger) (*auditLog, error) {
+	a := &auditLog{file: strings.TrimPrefix(file, "file://"), logger: logger}
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// open opens the file of the audit log unless it is open, the file is closed when the trigger stops
+func (a *auditLog) open() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.file == "" || a.writer != nil {
+		return nil
+	}
+	f, err := os.OpenFile(a.file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("Error opening audit log [%s]: %s", a.file, err.Error())
+	}
+	a.writer = f
+	return nil
+}
+
+func (a *auditLog) write(entry *auditEntry) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		a.logger.Errorf("Audit entry not written: %s", err.Error())
+		return
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.writer == nil {
+		a.logger.Infof("Audit: %s", b)
+		return
+	}
+	if _, err = a.writer.Write(append(b, '\n')); err != nil {
+		a.logger.Errorf("Audit entry not written: %s", err.Error())
+	}
+}
+
+func (a *auditLog) close() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.writer != nil {
+		a.writer.Close()
+		a.writer = nil
+	}
+}
@@ -0,0 +1,168 @@
+package grpc
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/grpc/codes"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+// reflectionServer serves the descriptors of the services registered on the trigger with the v1alpha server
+// reflection protocol, the descriptors come from the services rather than from the proto registry so that services
+// generated from proto files of the same name in different packages are described separately
+type reflectionServer struct {
+	services []string
+	symbols  map[string]*descriptor.FileDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+// registerReflection registers the reflection service for the services of the trigger
+func (t *Trigger) registerReflection(services []ServerService) error {
+	r := &reflectionServer{
+		symbols: make(map[string]*descriptor.FileDescriptorProto),
+		files:   make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, service := range services {
+		d, err := serviceDescriptors(service)
+		if err != nil {
+			return fmt.Errorf("Reflection not available for service [%s]: %s", service.ServiceInfo().ServiceName, err.Error())
+		}
+		name, _ := d.Service(service.ServiceInfo().ServiceName)
+		r.services = append(r.services, name)
+		for i, file := range d.Files {
+			if _, ok := r.files[file.GetName()]; !ok {
+				r.files[file.GetName()] = file
+			}
+			// the first file is the one of the service, the others are its dependencies
+			if i == 0 {
+				r.addSymbols(file)
+			}
+		}
+	}
+	rpb.RegisterServerReflectionServer(t.server, r)
+	return nil
+}
+
+func (r *reflectionServer) addSymbols(file *descriptor.FileDescriptorProto) {
+	prefix := file.GetPackage()
+	if prefix != "" {
+		prefix += "."
+	}
+	for _, service := range file.Service {
+		r.symbols[prefix+service.GetName()] = file
+	}
+	for _, enum := range file.EnumType {
+		r.symbols[prefix+enum.GetName()] = file
+	}
+	var addMessages func(prefix string, messages []*descriptor.DescriptorProto)
+	addMessages = func(prefix string, messages []*descriptor.DescriptorProto) {
+		for _, message := range messages {
+			name := prefix + message.GetName()
+			r.symbols[name] = file
+			for _, enum := range message.EnumType {
+				r.symbols[name+"."+enum.GetName()] = file
+			}
+			addMessages(name+".", message.NestedType)
+		}
+	}
+	addMessages(prefix, file.MessageType)
+}
+
+// fileContainingSymbol returns the file of a service, method, message or enum
+func (r *reflectionServer) fileContainingSymbol(symbol string) *descriptor.FileDescriptorProto {
+	if file, ok := r.symbols[symbol]; ok {
+		return file
+	}
+	// a method is looked up with the file of its service
+	if i := strings.LastIndex(symbol, "."); i > 0 {
+		return r.symbols[symbol[:i]]
+	}
+	return nil
+}
+
+// fileDescriptors returns the file followed by its dependencies, as marshalled file descriptors
+func (r *reflectionServer) fileDescriptors(file *descriptor.FileDescriptorProto) ([][]byte, error) {
+	var result [][]byte
+	sent := make(map[string]bool)
+	pending := []*descriptor.FileDescriptorProto{file}
+	for len(pending) > 0 {
+		fd := pending[0]
+		pending = pending[1:]
+		b, err := proto.Marshal(fd)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, b)
+		sent[fd.GetName()] = true
+		for _, name := range fd.Dependency {
+			if dep, ok := r.files[name]; ok && !sent[name] {
+				sent[name] = true
+				pending = append(pending, dep)
+			}
+		}
+	}
+	return result, nil
+}
+
+// ServerReflectionInfo implements rpb.ServerReflectionServer
+func (r *reflectionServer) ServerReflectionInfo(stream rpb.ServerReflection_ServerReflectionInfoServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		res := &rpb.ServerReflectionResponse{
+			ValidHost:       req.GetHost(),
+			OriginalRequest: req,
+		}
+		var file *descriptor.FileDescriptorProto
+		switch request := req.GetMessageRequest().(type) {
+		case *rpb.ServerReflectionRequest_ListServices:
+			services := make([]*rpb.ServiceResponse, len(r.services))
+			for i, name := range r.services {
+				services[i] = &rpb.ServiceResponse{Name: name}
+			}
+			res.MessageResponse = &rpb.ServerReflectionResponse_ListServicesResponse{
+				ListServicesResponse: &rpb.ListServiceResponse{Service: services},
+			}
+		case *rpb.ServerReflectionRequest_FileContainingSymbol:
+			if file = r.fileContainingSymbol(request.FileContainingSymbol); file == nil {
+				res.MessageResponse = reflectionError(codes.NotFound, "symbol not found: "+request.FileContainingSymbol)
+			}
+		case *rpb.ServerReflectionRequest_FileByFilename:
+			if file = r.files[request.FileByFilename]; file == nil {
+				res.MessageResponse = reflectionError(codes.NotFound, "file not found: "+request.FileByFilename)
+			}
+		default:
+			res.MessageResponse = reflectionError(codes.Unimplemented, "request not supported")
+		}
+		if file != nil {
+			files, err := r.fileDescriptors(file)
+			if err != nil {
+				res.MessageResponse = reflectionError(codes.Internal, err.Error())
+			} else {
+				res.MessageResponse = &rpb.ServerReflectionResponse_FileDescriptorResponse{
+					FileDescriptorResponse: &rpb.FileDescriptorResponse{FileDescriptorProto: files},
+				}
+			}
+		}
+
+		if err := stream.Send(res); err != nil {
+			return err
+		}
+	}
+}
+
+func reflectionError(code codes.Code, message string) *rpb.ServerReflectionResponse_ErrorResponse {
+	return &rpb.ServerReflectionResponse_ErrorResponse{
+		ErrorResponse: &rpb.ErrorResponse{ErrorCode: int32(code), ErrorMessage: message},
+	}
+}
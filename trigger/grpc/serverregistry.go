@@ -17,6 +17,17 @@ type ServerService interface {
 	RunRegisterServerService(s *grpc.Server, t *Trigger)
 }
 
+// DescriptorService is implemented by server services which expose the descriptor of their proto file
+type DescriptorService interface {
+	FileDescriptor() []byte
+}
+
+// StreamService is implemented by server services whose stream handlers can be invoked in-process, it returns the
+// description of the service and its implementation for the trigger
+type StreamService interface {
+	ServiceDesc(t *Trigger) (*grpc.ServiceDesc, interface{})
+}
+
 // ServiceInfo holds name of service and name of proto
 type ServiceInfo struct {
 	ServiceName string
@@ -0,0 +1,163 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// PriorityCritical methods may use the whole concurrency limit and are shed last
+	PriorityCritical = "critical"
+	// PriorityNormal methods may use 90% of the concurrency limit
+	PriorityNormal = "normal"
+	// PriorityLow methods may use half of the concurrency limit and are shed first
+	PriorityLow = "low"
+
+	defaultSheddingLatency  = 500
+	defaultSheddingMinLimit = 4
+	defaultSheddingMaxLimit = 1000
+	initialSheddingLimit    = 20
+	// sheddingBackoff is the factor applied to the limit when a call exceeds the target latency
+	sheddingBackoff = 0.9
+)
+
+var priorityShares = map[string]float64{
+	PriorityCritical: 1,
+	PriorityNormal:   0.9,
+	PriorityLow:      0.5,
+}
+
+// adaptiveLimiter is an AIMD limit of the calls in flight, the limit grows by one call per limit calls completed
+// within the target latency while the calls use at least half of it and shrinks by 10% for each call slower than the
+// target, calls over the share of the limit of their priority are shed
+type adaptiveLimiter struct {
+	target   time.Duration
+	minLimit float64
+	maxLimit float64
+	shares   map[string]float64
+
+	mutex    sync.Mutex
+	limit    float64
+	inFlight int
+	shed     map[string]int64
+}
+
+// newAdaptiveLimiter returns the limiter of the adaptiveShedding setting with the priorities of the handlers, or nil
+// when the setting is not set
+func (t *Trigger) newAdaptiveLimiter() (*adaptiveLimiter, error) {
+	if !t.settings.AdaptiveShedding {
+		for _, handler := range t.handlers {
+			if handler.settings.Priority != "" {
+				t.Logger.Warnf("Priority of method [%s] ignored without adaptiveShedding", handler.settings.MethodName)
+			}
+		}
+		return nil, nil
+	}
+	latency, minLimit, maxLimit := t.settings.SheddingLatency, t.settings.SheddingMinLimit, t.settings.SheddingMaxLimit
+	if latency == 0 {
+		latency = defaultSheddingLatency
+	}
+	if minLimit == 0 {
+		minLimit = defaultSheddingMinLimit
+	}
+	if maxLimit == 0 {
+		maxLimit = defaultSheddingMaxLimit
+	}
+	if latency < 0 || minLimit < 0 || maxLimit < minLimit {
+		return nil, fmt.Errorf("Invalid adaptive shedding settings")
+	}
+
+	l := &adaptiveLimiter{
+		target:   time.Duration(latency) * time.Millisecond,
+		minLimit: float64(minLimit),
+		maxLimit: float64(maxLimit),
+		shares:   make(map[string]float64),
+		limit:    math.Max(float64(minLimit), math.Min(float64(maxLimit), initialSheddingLimit)),
+		shed:     make(map[string]int64),
+	}
+	for key, handler := range t.handlers {
+		if handler.settings.Priority == "" {
+			continue
+		}
+		share, ok := priorityShares[handler.settings.Priority]
+		if !ok {
+			return nil, fmt.Errorf("Invalid priority [%s] for method [%s]", handler.settings.Priority, handler.settings.MethodName)
+		}
+		l.shares[key] = share
+	}
+	return l, nil
+}
+
+// acquire admits a call to a method unless the calls in flight reach the share of the limit of its priority
+func (l *adaptiveLimiter) acquire(fullMethod string) error {
+	share, ok := l.shares[handlerKey(fullMethod)]
+	if !ok {
+		share = priorityShares[PriorityNormal]
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	allowed := int(math.Max(1, math.Floor(l.limit*share)))
+	if l.inFlight >= allowed {
+		l.shed[strings.TrimPrefix(fullMethod, "/")]++
+		return status.Error(codes.Unavailable, "server overloaded, call shed")
+	}
+	l.inFlight++
+	return nil
+}
+
+// release ends a call, the latency of unary calls adjusts the limit
+func (l *adaptiveLimiter) release(latency time.Duration, sample bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	inFlight := l.inFlight
+	l.inFlight--
+	if !sample {
+		return
+	}
+	if latency > l.target {
+		l.limit = math.Max(l.minLimit, l.limit*sheddingBackoff)
+	} else if float64(inFlight) >= l.limit/2 {
+		l.limit = math.Min(l.maxLimit, l.limit+1/l.limit)
+	}
+}
+
+// currentLimit returns the limit and the calls shed per method
+func (l *adaptiveLimiter) currentLimit() (float64, map[string]int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	shed := make(map[string]int64, len(l.shed))
+	for method, count := range l.shed {
+		shed[method] = count
+	}
+	return l.limit, shed
+}
+
+// unaryInterceptor sheds the unary calls over the limit and samples the latency of the others
+func (l *adaptiveLimiter) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := l.acquire(info.FullMethod); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	defer func() {
+		l.release(time.Since(start), true)
+	}()
+	return handler(ctx, req)
+}
+
+// streamInterceptor sheds the streaming calls over the limit, an open stream counts against the limit but its
+// duration is not a latency sample
+func (l *adaptiveLimiter) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := l.acquire(info.FullMethod); err != nil {
+		return err
+	}
+	defer l.release(0, false)
+	return handler(srv, stream)
+}
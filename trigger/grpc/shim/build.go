@@ -33,8 +33,9 @@ import (
 	"strings"
 	"golang.org/x/net/context"
 	{{end}}
-	"errors"
+	{{if .UnaryMethodInfo}}"errors"{{end}}
 	servInfo "github.com/project-flogo/grpc/trigger/grpc"
+	{{if or .ServerStreamMethodInfo .ClientStreamMethodInfo .BiDiStreamMethodInfo}}"github.com/golang/protobuf/proto"{{end}}
 	"github.com/golang/protobuf/jsonpb"
 	"google.golang.org/grpc"
 )
@@ -126,16 +127,20 @@ func (s *serviceImpl{{$protoName}}{{$serviceName}}{{$option}}) {{.MethodName}}(c
 
 {{- range .ServerStreamMethodInfo }}
 
-func (s *serviceImpl{{$protoName}}{{$serviceName}}{{$option}}) {{.MethodName}}(req *EmptyReq, sReq {{$serviceName}}_{{.MethodName}}Server) error {
+func (s *serviceImpl{{$protoName}}{{$serviceName}}{{$option}}) {{.MethodName}}(req *{{.MethodReqName}}, sReq {{$serviceName}}_{{.MethodName}}Server) error {
 
 	methodName := "{{.MethodName}}"
 	serviceName := "{{$serviceName}}"
 
+	writer := servInfo.NewServerStreamWriter(sReq, func() proto.Message { return &{{.MethodResName}}{} })
+	defer writer.Close()
+
 	grpcData := make(map[string]interface{})
 	grpcData["methodName"] = methodName
 	grpcData["serviceName"] = serviceName
 	grpcData["reqdata"] = req
 	grpcData["strmReq"] = sReq
+	grpcData["streamWriter"] = writer
 
 	_, data, err := s.trigger.CallHandler(grpcData)
 
@@ -144,11 +149,13 @@ func (s *serviceImpl{{$protoName}}{{$serviceName}}{{$option}}) {{.MethodName}}(r
 		return err
 	}
 
-	if data != nil && data.(map[string]interface{})["error"] != nil {
-		s.trigger.Logger.Error("ServerStubError from end server: ", data.(map[string]interface{})["error"])
-		return errors.New(data.(map[string]interface{})["error"].(string))
+	if err = servInfo.ReplyError(data); err != nil {
+		s.trigger.Logger.Error("ServerStubError from end server: ", err.Error())
+		return err
 	}
-	return nil
+
+	// each element of a reply array is sent as a message of the stream
+	return writer.SendReply(data)
 }
 
 {{- end }}
@@ -160,10 +167,13 @@ func (s *serviceImpl{{$protoName}}{{$serviceName}}{{$option}}) {{.MethodName}}(c
 	methodName := "{{.MethodName}}"
 	serviceName := "{{$serviceName}}"
 
+	reader := servInfo.NewClientStreamReader(cReq, func() proto.Message { return &{{.MethodReqName}}{} })
+
 	grpcData := make(map[string]interface{})
 	grpcData["methodName"] = methodName
 	grpcData["serviceName"] = serviceName
 	grpcData["strmReq"] = cReq
+	grpcData["streamReader"] = reader
 
 	_, data, err := s.trigger.CallHandler(grpcData)
 
@@ -172,11 +182,21 @@ func (s *serviceImpl{{$protoName}}{{$serviceName}}{{$option}}) {{.MethodName}}(c
 		return err
 	}
 
-	if data != nil && data.(map[string]interface{})["error"] != nil {
-		s.trigger.Logger.Error("ServerStubError from end server: ", data.(map[string]interface{})["error"])
-		return errors.New(data.(map[string]interface{})["error"].(string))
+	if err = servInfo.ReplyError(data); err != nil {
+		s.trigger.Logger.Error("ServerStubError from end server: ", err.Error())
+		return err
 	}
-	return nil
+
+	if !reader.Drained() {
+		// the stream was passed through and the response was sent by the handler
+		return nil
+	}
+	res, err := servInfo.ToMessage(data, &{{.MethodResName}}{})
+	if err != nil {
+		s.trigger.Logger.Error("ServerStubError: ", err.Error())
+		return err
+	}
+	return cReq.SendAndClose(res.(*{{.MethodResName}}))
 }
 
 {{- end }}
@@ -188,10 +208,16 @@ func (s *serviceImpl{{$protoName}}{{$serviceName}}{{$option}}) {{.MethodName}}(b
 	methodName := "{{.MethodName}}"
 	serviceName := "{{$serviceName}}"
 
+	reader := servInfo.NewClientStreamReader(bdReq, func() proto.Message { return &{{.MethodReqName}}{} })
+	writer := servInfo.NewServerStreamWriter(bdReq, func() proto.Message { return &{{.MethodResName}}{} })
+	defer writer.Close()
+
 	grpcData := make(map[string]interface{})
 	grpcData["methodName"] = methodName
 	grpcData["serviceName"] = serviceName
 	grpcData["strmReq"] = bdReq
+	grpcData["streamReader"] = reader
+	grpcData["streamWriter"] = writer
 
 	_, data, err := s.trigger.CallHandler(grpcData)
 
@@ -200,9 +226,9 @@ func (s *serviceImpl{{$protoName}}{{$serviceName}}{{$option}}) {{.MethodName}}(b
 		return err
 	}
 
-	if data != nil && data.(map[string]interface{})["error"] != nil {
-		s.trigger.Logger.Error("ServerStubError from end server: ", data.(map[string]interface{})["error"])
-		return errors.New(data.(map[string]interface{})["error"].(string))
+	if err = servInfo.ReplyError(data); err != nil {
+		s.trigger.Logger.Error("ServerStubError from end server: ", err.Error())
+		return err
 	}
 	return nil
 }
@@ -213,6 +239,12 @@ func (s *serviceImpl{{$protoName}}{{$serviceName}}{{$option}}) ServiceInfo() *se
 	return s.serviceInfo
 }
 
+// FileDescriptor returns the compressed descriptor of the proto file
+func (s *serviceImpl{{$protoName}}{{$serviceName}}{{$option}}) FileDescriptor() []byte {
+	descriptor, _ := (&{{.DescriptorMessage}}{}).Descriptor()
+	return descriptor
+}
+
 `))
 
 //client template to create grpc service support file
@@ -509,6 +541,7 @@ type ProtoData struct {
 	ProtoName              string
 	Option                 string
 	Stream                 bool
+	DescriptorMessage      string
 }
 
 var (
@@ -677,6 +710,9 @@ func arrangeProtoData(pdArr []ProtoData) []ProtoData {
 				servrStrm = true
 				protoData.Stream = true
 			}
+			if protoData.DescriptorMessage == "" {
+				protoData.DescriptorMessage = mthdInfo.MethodReqName
+			}
 			if !clientStrm && !servrStrm {
 				protoData.UnaryMethodInfo = append(protoData.UnaryMethodInfo, mthdInfo)
 			} else if clientStrm && servrStrm {
@@ -706,7 +742,7 @@ func getProtoData() ([]ProtoData, error) {
 		tempString = tempString[strings.Index(tempString, serviceName):]
 
 		//getting entire service declaration
-		temp := tempString[:strings.Index(tempString, "}")+1]
+		temp := serviceDeclaration(tempString)
 
 		regServiceName = strings.TrimSpace(temp[strings.Index(temp, serviceName)+len(serviceName) : strings.Index(temp, "{")])
 		regServiceName = generator.CamelCase(regServiceName)
@@ -719,8 +755,8 @@ func getProtoData() ([]ProtoData, error) {
 			methodInfo := MethodInfoTree{}
 			mthdDtls := strings.Split(mthd, "(")
 			methodInfo.MethodName = generator.CamelCase(strings.TrimSpace(mthdDtls[0]))
-			methodInfo.MethodReqName = generator.CamelCase(strings.TrimSpace(strings.Split(mthdDtls[1], ")")[0]))
-			methodInfo.MethodResName = generator.CamelCase(strings.TrimSpace(strings.Split(mthdDtls[2], ")")[0]))
+			methodInfo.MethodReqName = rpcTypeName(strings.Split(mthdDtls[1], ")")[0])
+			methodInfo.MethodResName = rpcTypeName(strings.Split(mthdDtls[2], ")")[0])
 			methodInfo.serviceName = regServiceName
 			methodInfoList = append(methodInfoList, methodInfo)
 		}
@@ -743,6 +779,42 @@ func getProtoData() ([]ProtoData, error) {
 	return ProtodataArr, nil
 }
 
+// serviceDeclaration returns the service declaration at the start of the given string,
+// the option blocks of its rpc methods are left out
+func serviceDeclaration(value string) string {
+	var declaration strings.Builder
+	depth := 0
+	for _, r := range value {
+		switch r {
+		case '{':
+			depth++
+			if depth == 1 {
+				declaration.WriteRune(r)
+			}
+		case '}':
+			depth--
+			if depth == 0 {
+				declaration.WriteRune(r)
+				return declaration.String()
+			}
+		default:
+			if depth <= 1 {
+				declaration.WriteRune(r)
+			}
+		}
+	}
+	return declaration.String()
+}
+
+// rpcTypeName camel cases a rpc request or response type while keeping the stream keyword
+func rpcTypeName(value string) string {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "stream ") {
+		return "stream " + generator.CamelCase(strings.TrimSpace(value[len("stream "):]))
+	}
+	return generator.CamelCase(value)
+}
+
 // generateServiceImplFile creates implementation files supported for grpc trigger and grpc service
 func generateServiceImplFile(pdArr []ProtoData, option string) error {
 	dirPath := filepath.Join(appPath)
@@ -0,0 +1,176 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StreamWriter sends data as messages of a response stream
+type StreamWriter interface {
+	Send(data interface{}) error
+}
+
+// ServerStreamWriter sends handler data as messages of the response stream of a server streaming method,
+// it is passed to the handler in grpcData["streamWriter"]
+type ServerStreamWriter struct {
+	mutex      sync.Mutex
+	stream     grpc.ServerStream
+	newMessage func() proto.Message
+	closed     bool
+}
+
+// NewServerStreamWriter creates a writer converting data to messages created by newMessage
+func NewServerStreamWriter(stream grpc.ServerStream, newMessage func() proto.Message) *ServerStreamWriter {
+	return &ServerStreamWriter{stream: stream, newMessage: newMessage}
+}
+
+// Send converts the data to a message and sends it
+func (w *ServerStreamWriter) Send(data interface{}) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return errors.New("stream is closed")
+	}
+	msg, err := ToMessage(data, w.newMessage())
+	if err != nil {
+		return err
+	}
+	return w.stream.SendMsg(msg)
+}
+
+// SendReply sends each element of a reply array as a message, any other reply is sent as a single message
+func (w *ServerStreamWriter) SendReply(data interface{}) error {
+	return sendReply(w, data)
+}
+
+// Context returns the context of the stream, which is canceled when the client goes away
+func (w *ServerStreamWriter) Context() context.Context {
+	return w.stream.Context()
+}
+
+// Close ends the writer, the stream is ended by returning from the method
+func (w *ServerStreamWriter) Close() {
+	w.mutex.Lock()
+	w.closed = true
+	w.mutex.Unlock()
+}
+
+// ClientStreamReader receives the messages of the request stream of a client streaming method,
+// it is passed to the trigger in grpcData["streamReader"]
+type ClientStreamReader struct {
+	stream     grpc.ServerStream
+	newMessage func() proto.Message
+	drained    bool
+}
+
+// NewClientStreamReader creates a reader receiving messages created by newMessage
+func NewClientStreamReader(stream grpc.ServerStream, newMessage func() proto.Message) *ClientStreamReader {
+	return &ClientStreamReader{stream: stream, newMessage: newMessage}
+}
+
+// Read receives the next message, io.EOF is returned at the end of the stream
+func (r *ClientStreamReader) Read() (proto.Message, error) {
+	r.drained = true
+	msg := r.newMessage()
+	if err := r.stream.RecvMsg(msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// ReadAll receives the messages until the end of the stream and converts them to an array,
+// a stream exceeding maxMessages or maxBytes fails with ResourceExhausted, limits of zero are ignored
+func (r *ClientStreamReader) ReadAll(maxMessages, maxBytes int) ([]interface{}, error) {
+	messages := make([]interface{}, 0)
+	size := 0
+	m := jsonpb.Marshaler{OrigName: true}
+	for {
+		msg, err := r.Read()
+		if err == io.EOF {
+			return messages, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if maxMessages > 0 && len(messages) == maxMessages {
+			return nil, status.Errorf(codes.ResourceExhausted, "request stream exceeds the limit of %d messages", maxMessages)
+		}
+		size += proto.Size(msg)
+		if maxBytes > 0 && size > maxBytes {
+			return nil, status.Errorf(codes.ResourceExhausted, "request stream exceeds the limit of %d bytes", maxBytes)
+		}
+
+		b, err := m.MarshalToString(msg)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if err = json.Unmarshal([]byte(b), &value); err != nil {
+			return nil, err
+		}
+		messages = append(messages, value)
+	}
+}
+
+// Drained reports whether the trigger received the stream, otherwise it was left to the handler
+func (r *ClientStreamReader) Drained() bool {
+	return r.drained
+}
+
+func sendReply(w StreamWriter, data interface{}) error {
+	if data == nil {
+		return nil
+	}
+	value := reflect.ValueOf(data)
+	if value.Kind() != reflect.Slice {
+		return w.Send(data)
+	}
+	for i := 0; i < value.Len(); i++ {
+		if err := w.Send(value.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ToMessage converts handler data following the proto3 JSON mapping to the given message
+func ToMessage(data interface{}, msg proto.Message) (proto.Message, error) {
+	if reflect.TypeOf(data) == reflect.TypeOf(msg) {
+		return data.(proto.Message), nil
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	err = jsonpb.Unmarshal(bytes.NewReader(b), msg)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// ReplyError returns the error of a handler reply, which is a status error or an object with an error field
+func ReplyError(data interface{}) error {
+	if err, ok := data.(error); ok {
+		return err
+	}
+	if reply, ok := data.(map[string]interface{}); ok && reply["error"] != nil {
+		if message, ok := reply["error"].(string); ok {
+			return errors.New(message)
+		}
+		b, _ := json.Marshal(reply["error"])
+		return errors.New(string(b))
+	}
+	return nil
+}
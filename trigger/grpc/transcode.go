@@ -0,0 +1,94 @@
+package grpc
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/project-flogo/grpc/support"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// httpRoute is a REST exposure of a unary method
+type httpRoute struct {
+	*support.HTTPRoute
+	descriptors *support.Descriptors
+	fullMethod  string
+	serviceName string
+	methodName  string
+	inputType   string
+	outputType  string
+}
+
+// serveTranscoded transcodes the HTTP requests matching the google.api.http bindings of unary methods to calls of
+// their handlers, methods without the option are bound to POST /{serviceName}/{methodName}
+func (t *Trigger) serveTranscoded(routes []*httpRoute) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var route *httpRoute
+		var variables map[string]string
+		allowed := false
+		for _, candidate := range routes {
+			vars, ok := candidate.Match(r.URL.EscapedPath())
+			if !ok {
+				continue
+			}
+			allowed = true
+			if candidate.Verb == r.Method {
+				route, variables = candidate, vars
+				break
+			}
+		}
+		if route == nil {
+			if allowed {
+				writeHTTPError(w, status.New(codes.Unimplemented, "method not allowed"), http.StatusMethodNotAllowed)
+				return
+			}
+			writeHTTPError(w, status.New(codes.NotFound, "no method bound to "+r.URL.Path), http.StatusNotFound)
+			return
+		}
+
+		body, ok := t.readBody(w, r)
+		if !ok {
+			return
+		}
+		req, err := route.descriptors.TranscodeRequest(route.inputType, route.HTTPBinding, variables, r.URL.Query(), body)
+		if err != nil {
+			writeHTTPError(w, status.New(codes.InvalidArgument, err.Error()), 0)
+			return
+		}
+
+		grpcData := make(map[string]interface{})
+		grpcData["methodName"] = route.methodName
+		grpcData["serviceName"] = route.serviceName
+
+		var code int
+		ctx := httpCallContext(r)
+		data, err := t.invokeUnary(ctx, route.fullMethod, req, func(ctx context.Context, req interface{}) (interface{}, error) {
+			grpcData["contextdata"] = ctx
+			grpcData["reqdata"] = req
+			var data interface{}
+			var err error
+			code, data, err = t.CallHandler(grpcData)
+			if err == nil {
+				err = ReplyError(data)
+			}
+			return data, err
+		})
+		var b []byte
+		if err == nil {
+			b, err = route.descriptors.TranscodeResponse(route.outputType, route.HTTPBinding, data)
+		}
+		if err != nil {
+			t.Logger.Errorf("Method [%s] over HTTP failed: %s", route.methodName, err.Error())
+			writeHTTPError(w, status.Convert(err), 0)
+			return
+		}
+
+		if code < 200 || code > 599 {
+			code = http.StatusOK
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		w.Write(b)
+	}
+}
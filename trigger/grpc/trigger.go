@@ -2,34 +2,48 @@ package grpc
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"net"
+	"net/http"
 	"os"
 	"reflect"
-	"strconv"
 	"strings"
 
 	"github.com/golang/protobuf/jsonpb"
 
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/project-flogo/core/data/coerce"
 	"github.com/project-flogo/core/data/metadata"
 	"github.com/project-flogo/core/support/log"
 	"github.com/project-flogo/core/trigger"
+	"github.com/project-flogo/grpc/support"
 )
 
 var (
 	triggerMetadata = trigger.NewMetadata(&Settings{}, &HandlerSettings{}, &Output{}, &Reply{})
 )
 
+const (
+	// StreamModeAggregate drains the request stream of a client streaming method and passes its messages as one array
+	StreamModeAggregate = "aggregate"
+	// StreamModeMessage invokes the handler once per message of the request stream of a bidirectional streaming method
+	StreamModeMessage = "message"
+
+	// OrderingSequential handles the next message once the reply to the previous one is sent
+	OrderingSequential = "sequential"
+	// OrderingParallel handles up to maxParallel messages at once and sends the replies in the order of the messages
+	OrderingParallel = "parallel"
+
+	defaultMaxMessages = 1000
+	defaultMaxBytes    = 4 * 1024 * 1024
+	defaultMaxParallel = 10
+)
+
 func init() {
 	trigger.Register(&Trigger{}, &Factory{})
 }
@@ -62,12 +76,24 @@ type Handler struct {
 
 // Trigger is a stub for your gRPC Trigger implementation
 type Trigger struct {
-	config         *trigger.Config
-	settings       *Settings
-	handlers       map[string]*Handler
-	defaultHandler *Handler
-	server         *grpc.Server
-	Logger         log.Logger
+	config          *trigger.Config
+	settings        *Settings
+	handlers        map[string]*Handler
+	defaultHandler  *Handler
+	server          *grpc.Server
+	httpServer      *http.Server
+	portServer      *http.Server
+	metrics         *serverMetrics
+	policy          *policy
+	shedder         *adaptiveLimiter
+	serverOptions   []grpc.ServerOption
+	listenerConfigs []*listenerConfig
+	certificates    *certificateStore
+	lifecycle       lifecycle
+
+	unaryInterceptor  grpc.UnaryServerInterceptor
+	streamInterceptor grpc.StreamServerInterceptor
+	Logger            log.Logger
 }
 
 // Metadata implements trigger.Trigger.Metadata
@@ -87,6 +113,14 @@ func (t *Trigger) Initialize(ctx trigger.InitContext) error {
 		if err != nil {
 			return err
 		}
+		err = validateStreamSettings(settings)
+		if err != nil {
+			return err
+		}
+		err = t.checkStreamMode(settings)
+		if err != nil {
+			return err
+		}
 		if settings.MethodName == "" && t.defaultHandler == nil {
 			t.defaultHandler = &Handler{
 				handler:  handler,
@@ -100,52 +134,137 @@ func (t *Trigger) Initialize(ctx trigger.InitContext) error {
 		}
 	}
 	t.handlers = handlers
+
 	t.Logger.Debugf("Enable TLS: %t", t.settings.EnableTLS)
 	if t.settings.EnableTLS {
-		// decode server cert and server key
-		serverCert, err := t.decodeCertificate(t.settings.ServerCert)
+		certificates, err := t.newCertificateStore(t.settings.ServerCert, t.settings.ServerKey, "")
 		if err != nil {
-			t.Logger.Errorf("Error decoding server certificate: %s", err.Error())
+			t.Logger.Errorf("Invalid TLS settings: %s", err.Error())
 			return err
 		}
-		serverKey, err := t.decodeCertificate(t.settings.ServerKey)
-		if err != nil {
-			t.Logger.Errorf("Error decoding server key: %s", err.Error())
-			return err
-		}
-		t.settings.ServerCert = string(serverCert)
-		t.settings.ServerKey = string(serverKey)
+		t.certificates = certificates
 	}
-	return nil
-}
 
-// Stop implements trigger.Trigger.Start
-func (t *Trigger) Stop() error {
-	// stop the trigger
-	t.server.GracefulStop()
+	serverOptions, err := t.tuningOptions()
+	if err != nil {
+		t.Logger.Errorf("Invalid server settings: %s", err.Error())
+		return err
+	}
+	t.serverOptions = serverOptions
+
+	listenerConfigs, err := t.parseListeners()
+	if err != nil {
+		t.Logger.Errorf("Invalid listeners: %s", err.Error())
+		return err
+	}
+	t.listenerConfigs = listenerConfigs
+
+	unary, stream, err := registeredInterceptors(t.settings.Interceptors)
+	if err != nil {
+		t.Logger.Error(err)
+		return err
+	}
+	verifier, err := t.newJWTVerifier()
+	if err != nil {
+		t.Logger.Errorf("Invalid JWT settings: %s", err.Error())
+		return err
+	}
+	keyStore, err := t.newAPIKeyStore()
+	if err != nil {
+		t.Logger.Errorf("Invalid API keys: %s", err.Error())
+		return err
+	}
+	if t.shedder, err = t.newAdaptiveLimiter(); err != nil {
+		t.Logger.Error(err)
+		return err
+	}
+	// the latency of the calls is measured once they are authenticated
+	if t.shedder != nil {
+		unary = append([]grpc.UnaryServerInterceptor{t.shedder.unaryInterceptor}, unary...)
+		stream = append([]grpc.StreamServerInterceptor{t.shedder.streamInterceptor}, stream...)
+	}
+	limits, err := t.newCallLimits()
+	if err != nil {
+		t.Logger.Error(err)
+		return err
+	}
+	// callers are limited on their authenticated identity
+	if limits != nil && limits.callerLimited() {
+		unary = append([]grpc.UnaryServerInterceptor{limits.callerUnaryInterceptor}, unary...)
+		stream = append([]grpc.StreamServerInterceptor{limits.callerStreamInterceptor}, stream...)
+	}
+	// callers are authenticated before any registered interceptor runs
+	if keyStore != nil {
+		unary = append([]grpc.UnaryServerInterceptor{keyStore.unaryInterceptor}, unary...)
+		stream = append([]grpc.StreamServerInterceptor{keyStore.streamInterceptor}, stream...)
+	}
+	if verifier != nil {
+		unary = append([]grpc.UnaryServerInterceptor{verifier.unaryInterceptor}, unary...)
+		stream = append([]grpc.StreamServerInterceptor{verifier.streamInterceptor}, stream...)
+	}
+	// calls over the global and method limits are refused before anything else is done for them
+	if limits != nil && (limits.global != nil || len(limits.methods) > 0) {
+		unary = append([]grpc.UnaryServerInterceptor{limits.unaryInterceptor}, unary...)
+		stream = append([]grpc.StreamServerInterceptor{limits.streamInterceptor}, stream...)
+	}
+	t.unaryInterceptor, t.streamInterceptor = chainUnary(unary), chainStream(stream)
+
+	if t.policy, err = t.loadPolicy(); err != nil {
+		t.Logger.Error(err)
+		return err
+	}
 	return nil
 }
 
 // Start implements trigger.Trigger.Start
-func (t *Trigger) Start() error {
+func (t *Trigger) Start() (err error) {
 	// start the trigger
-	addr := ":" + strconv.Itoa(t.settings.Port)
-	lis, err := net.Listen("tcp", addr)
+	if t.server != nil {
+		return errors.New("gRPC server already started")
+	}
+	listeners, err := listen(t.listenerConfigs)
 	if err != nil {
 		t.Logger.Error(err)
 		return err
 	}
-
-	opts := []grpc.ServerOption{}
-
-	if t.settings.EnableTLS {
-		cert, err := tls.X509KeyPair([]byte(t.settings.ServerCert), []byte(t.settings.ServerKey))
+	// the trigger can be started again once a failed start is undone
+	defer func() {
 		if err != nil {
+			for _, lis := range listeners {
+				lis.Close()
+			}
+			t.Stop()
+		}
+	}()
+	if t.policy != nil {
+		if err = t.policy.audit.open(); err != nil {
 			t.Logger.Error(err)
 			return err
 		}
-		creds := credentials.NewServerTLSFromCert(&cert)
-		opts = []grpc.ServerOption{grpc.Creds(creds)}
+	}
+
+	opts := []grpc.ServerOption{}
+
+	for _, config := range t.listenerConfigs {
+		if config.tlsConfig != nil {
+			// the TLS configuration is the one of the endpoint of each connection
+			opts = []grpc.ServerOption{grpc.Creds(newListenerCredentials(t.listenerConfigs))}
+		}
+	}
+
+	opts = append(opts, t.serverOptions...)
+
+	// the metrics are served by the multiplexed listeners and by the HTTP port
+	t.metrics = newServerMetrics()
+	t.metrics.shedder = t.shedder
+	opts = append(opts, grpc.StatsHandler(t.metrics))
+
+	unary, stream := unauthenticatedHealth(t.unaryInterceptor, t.streamInterceptor)
+	if unary != nil {
+		opts = append(opts, grpc.UnaryInterceptor(unary))
+	}
+	if stream != nil {
+		opts = append(opts, grpc.StreamInterceptor(stream))
 	}
 
 	t.server = grpc.NewServer(opts...)
@@ -154,12 +273,14 @@ func (t *Trigger) Start() error {
 	protoName = strings.Split(protoName, ".")[0]
 
 	// Register each serviceName + protoName
+	var registered []ServerService
 	if len(ServiceRegistery.ServerServices) != 0 {
 		for k, service := range ServiceRegistery.ServerServices {
 			servRegFlag := false
 			if strings.Compare(k, protoName+service.ServiceInfo().ServiceName) == 0 {
 				t.Logger.Infof("Registered Proto [%v] and Service [%v]", protoName, service.ServiceInfo().ServiceName)
 				service.RunRegisterServerService(t.server, t)
+				registered = append(registered, service)
 				servRegFlag = true
 			}
 			if !servRegFlag {
@@ -172,14 +293,44 @@ func (t *Trigger) Start() error {
 		t.Logger.Error("gRPC server services not registered")
 		return errors.New("gRPC server services not registered")
 	}
+	t.registerHealth(registered)
+	if t.settings.Reflection {
+		if err = t.registerReflection(registered); err != nil {
+			t.Logger.Error(err)
+			return err
+		}
+	}
 
-	t.Logger.Debug("Starting server on port", addr)
+	t.lifecycle.start()
+	if t.settings.HTTPPort != 0 {
+		err = t.startHTTP()
+		if err != nil {
+			t.Logger.Error(err)
+			return err
+		}
+	}
 
-	go func() {
-		t.server.Serve(lis)
-	}()
+	if t.settings.GRPCWeb || t.settings.Multiplex {
+		err = t.serveMultiplexed(listeners)
+		if err != nil {
+			t.Logger.Error(err)
+			return err
+		}
+	} else {
+		for i, lis := range listeners {
+			if config := t.listenerConfigs[i].tlsConfig; config != nil {
+				lis = &handshakeListener{Listener: lis, config: config}
+			}
+			server, lis := t.server, lis
+			t.serve(fmt.Sprintf("gRPC server on [%s]", t.listenerConfigs[i]), func() error {
+				return server.Serve(lis)
+			})
+		}
+	}
 
-	t.Logger.Infof("Server started on port: [%d]", t.settings.Port)
+	for _, config := range t.listenerConfigs {
+		t.Logger.Infof("Server started on [%s], TLS: %t", config, config.tlsConfig != nil)
+	}
 	return nil
 }
 
@@ -189,43 +340,11 @@ func (t *Trigger) CallHandler(grpcData map[string]interface{}) (int, interface{}
 
 	params := make(map[string]interface{})
 	var content interface{}
-	m := jsonpb.Marshaler{OrigName: true, EmitDefaults: true}
-	// blocking the code for streaming requests
-	if grpcData["contextdata"] != nil {
-		// getting values from inputrequestdata and mapping it to params which can be used in different services like HTTP pathparams etc.
-		s := reflect.ValueOf(grpcData["reqdata"]).Elem()
-		typeOfS := s.Type()
-		for i := 0; i < s.NumField(); i++ {
-			f := s.Field(i)
-			fieldName := proto.GetProperties(typeOfS).Prop[i].OrigName
-			if !strings.HasPrefix(fieldName, "XXX_") {
-				// XXX_ fields will not be mapped
-				if _, ok := f.Interface().(proto.Message); ok {
-					jsonString, err := m.MarshalToString(f.Interface().(proto.Message))
-					if err != nil {
-						t.Logger.Errorf("Marshal failed on field: %s with value: %v", fieldName, f.Interface())
-					}
-					t.Logger.Debugf("Marshaled FieldName: [%s] Value: [%s]", fieldName, jsonString)
-					var paramValue map[string]interface{}
-					json.Unmarshal([]byte(jsonString), &paramValue)
-					params[fieldName] = paramValue
-				} else {
-					t.Logger.Debugf("Field name: [%s] Value: [%v]", fieldName, f.Interface())
-					params[fieldName] = f.Interface()
-				}
-			}
-		}
-
-		// assign req data content to trigger content
-		dataBytes, err := json.Marshal(grpcData["reqdata"])
-		if err != nil {
-			t.Logger.Error("Marshal failed on grpc request data")
-			return 0, nil, err
-		}
-
-		err = json.Unmarshal(dataBytes, &content)
+	var err error
+	// client and bidirectional streams have no request data
+	if grpcData["reqdata"] != nil {
+		params, content, err = t.requestData(grpcData["reqdata"])
 		if err != nil {
-			t.Logger.Error("Unmarshal failed on grpc request data")
 			return 0, nil, err
 		}
 	}
@@ -237,6 +356,51 @@ func (t *Trigger) CallHandler(grpcData map[string]interface{}) (int, interface{}
 
 	if handler != nil {
 		grpcData["protoName"] = t.settings.ProtoName
+		claims := callClaims(grpcData)
+		if claims != nil {
+			grpcData["claims"] = claims
+		}
+		if identity := callIdentity(grpcData); identity != "" {
+			grpcData["identity"] = identity
+		}
+		if handler.settings.RequiredScopes != "" {
+			if err := checkScopes(handler.settings.RequiredScopes, claims); err != nil {
+				t.Logger.Errorf("Method [%s] denied: %s", grpcData["methodName"], err.Error())
+				return 0, nil, err
+			}
+		}
+		// the policy sees the messages of an aggregated request stream and each message in message mode, a request
+		// stream the handler receives itself is not available to it
+		reader, streaming := grpcData["streamReader"].(*ClientStreamReader)
+		if t.policy != nil && (!streaming || handler.settings.StreamMode == "") {
+			if err := t.policy.authorize(grpcData, content); err != nil {
+				t.Logger.Errorf("Method [%s] denied: %s", grpcData["methodName"], err.Error())
+				return 0, nil, err
+			}
+		}
+
+		if streaming && handler.settings.StreamMode == StreamModeAggregate {
+			messages, err := reader.ReadAll(handler.settings.MaxMessages, handler.settings.MaxBytes)
+			if err != nil {
+				t.Logger.Errorf("Receiving request stream failed: %s", err.Error())
+				return 0, nil, err
+			}
+			t.Logger.Debugf("Received %d messages from request stream", len(messages))
+			content = messages
+			if t.policy != nil {
+				if err := t.policy.authorize(grpcData, content); err != nil {
+					t.Logger.Errorf("Method [%s] denied: %s", grpcData["methodName"], err.Error())
+					return 0, nil, err
+				}
+			}
+		}
+		if streaming && handler.settings.StreamMode == StreamModeMessage {
+			writer, ok := grpcData["streamWriter"].(*ServerStreamWriter)
+			if !ok {
+				return 0, nil, fmt.Errorf("Stream mode [%s] requires a bidirectional streaming method", StreamModeMessage)
+			}
+			return 0, nil, t.handleMessages(handler, grpcData, reader, writer)
+		}
 
 		out := &Output{
 			Params:   params,
@@ -260,6 +424,129 @@ func (t *Trigger) CallHandler(grpcData map[string]interface{}) (int, interface{}
 	return 0, nil, errors.New("Dispatch not found")
 }
 
+// requestData maps the fields of a request message to params and converts the message to content
+func (t *Trigger) requestData(req interface{}) (map[string]interface{}, interface{}, error) {
+	params := make(map[string]interface{})
+	var content interface{}
+	m := jsonpb.Marshaler{OrigName: true, EmitDefaults: true}
+
+	// messages of HTTP requests are only known by their descriptor
+	if msg, ok := req.(*support.DynamicMessage); ok {
+		if fields, ok := msg.Value.(map[string]interface{}); ok {
+			for fieldName, value := range fields {
+				params[fieldName] = value
+			}
+		}
+		return params, msg.Value, nil
+	}
+
+	// getting values from inputrequestdata and mapping it to params which can be used in different services like HTTP pathparams etc.
+	s := reflect.ValueOf(req).Elem()
+	typeOfS := s.Type()
+	for i := 0; i < s.NumField(); i++ {
+		f := s.Field(i)
+		fieldName := proto.GetProperties(typeOfS).Prop[i].OrigName
+		if !strings.HasPrefix(fieldName, "XXX_") {
+			// XXX_ fields will not be mapped
+			if _, ok := f.Interface().(proto.Message); ok {
+				jsonString, err := m.MarshalToString(f.Interface().(proto.Message))
+				if err != nil {
+					t.Logger.Errorf("Marshal failed on field: %s with value: %v", fieldName, f.Interface())
+				}
+				t.Logger.Debugf("Marshaled FieldName: [%s] Value: [%s]", fieldName, jsonString)
+				var paramValue map[string]interface{}
+				json.Unmarshal([]byte(jsonString), &paramValue)
+				params[fieldName] = paramValue
+			} else {
+				t.Logger.Debugf("Field name: [%s] Value: [%v]", fieldName, f.Interface())
+				params[fieldName] = f.Interface()
+			}
+		}
+	}
+
+	// assign req data content to trigger content
+	dataBytes, err := json.Marshal(req)
+	if err != nil {
+		t.Logger.Error("Marshal failed on grpc request data")
+		return nil, nil, err
+	}
+
+	err = json.Unmarshal(dataBytes, &content)
+	if err != nil {
+		t.Logger.Error("Unmarshal failed on grpc request data")
+		return nil, nil, err
+	}
+	return params, content, nil
+}
+
+// validateStreamSettings checks the stream mode of a handler and applies the default stream limits
+func validateStreamSettings(settings *HandlerSettings) error {
+	switch settings.StreamMode {
+	case "":
+	case StreamModeAggregate:
+		if settings.MaxMessages == 0 {
+			settings.MaxMessages = defaultMaxMessages
+		}
+		if settings.MaxBytes == 0 {
+			settings.MaxBytes = defaultMaxBytes
+		}
+	case StreamModeMessage:
+		switch settings.Ordering {
+		case "":
+			settings.Ordering = OrderingSequential
+		case OrderingSequential, OrderingParallel:
+		default:
+			return fmt.Errorf("Invalid ordering [%s] for method [%s]", settings.Ordering, settings.MethodName)
+		}
+		if settings.MaxParallel == 0 {
+			settings.MaxParallel = defaultMaxParallel
+		}
+		if settings.MaxParallel < 0 {
+			return fmt.Errorf("Invalid maxParallel [%d] for method [%s]", settings.MaxParallel, settings.MethodName)
+		}
+	default:
+		return fmt.Errorf("Invalid stream mode [%s] for method [%s]", settings.StreamMode, settings.MethodName)
+	}
+	if settings.MaxMessages < 0 || settings.MaxBytes < 0 {
+		return fmt.Errorf("Invalid stream limits for method [%s]", settings.MethodName)
+	}
+	return nil
+}
+
+// checkStreamMode checks the stream mode of a handler against the streaming kind of its method, aggregate requires a
+// client streaming method and message a bidirectional one, the methods of services not registered are not checked
+func (t *Trigger) checkStreamMode(settings *HandlerSettings) error {
+	if settings.StreamMode == "" || settings.MethodName == "" {
+		return nil
+	}
+	protoName := strings.Split(t.settings.ProtoName, ".")[0]
+	service, ok := ServiceRegistery.ServerServices[protoName+settings.ServiceName]
+	if !ok {
+		return nil
+	}
+	d, err := serviceDescriptors(service)
+	if err != nil {
+		t.Logger.Debugf("Stream mode of method [%s] not checked: %s", settings.MethodName, err.Error())
+		return nil
+	}
+	method := d.Method(settings.ServiceName, settings.MethodName)
+	if method == nil {
+		return nil
+	}
+
+	switch settings.StreamMode {
+	case StreamModeAggregate:
+		if !method.GetClientStreaming() || method.GetServerStreaming() {
+			return fmt.Errorf("Stream mode [%s] requires a client streaming method, [%s] is not", StreamModeAggregate, settings.MethodName)
+		}
+	case StreamModeMessage:
+		if !method.GetClientStreaming() || !method.GetServerStreaming() {
+			return fmt.Errorf("Stream mode [%s] requires a bidirectional streaming method, [%s] is not", StreamModeMessage, settings.MethodName)
+		}
+	}
+	return nil
+}
+
 func (t *Trigger) decodeCertificate(cert string) ([]byte, error) {
 	if cert == "" {
 		return nil, fmt.Errorf("Certificate is Empty")
@@ -0,0 +1,148 @@
+package grpc
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// minWindowSize is the smallest HTTP/2 flow control window, gRPC ignores smaller initial window sizes
+const minWindowSize = 65535
+
+// defaultMaxRecvMsgSize is the size of the largest message a gRPC server receives by default
+const defaultMaxRecvMsgSize = 4 * 1024 * 1024
+
+// tuningOptions returns the options of the gRPC server for the tuning settings of the trigger, durations are in
+// seconds and zero keeps the default of gRPC
+func (t *Trigger) tuningOptions() ([]grpc.ServerOption, error) {
+	s := t.settings
+	for name, value := range map[string]int{
+		"maxRecvMsgSize":        s.MaxRecvMsgSize,
+		"maxSendMsgSize":        s.MaxSendMsgSize,
+		"maxConcurrentStreams":  s.MaxConcurrentStreams,
+		"keepaliveTime":         s.KeepaliveTime,
+		"keepaliveTimeout":      s.KeepaliveTimeout,
+		"keepaliveMinTime":      s.KeepaliveMinTime,
+		"maxConnectionIdle":     s.MaxConnectionIdle,
+		"maxConnectionAge":      s.MaxConnectionAge,
+		"maxConnectionAgeGrace": s.MaxConnectionAgeGrace,
+		"connectionTimeout":     s.ConnectionTimeout,
+		"drainTimeout":          s.DrainTimeout,
+	} {
+		if value < 0 {
+			return nil, fmt.Errorf("Invalid %s [%d]", name, value)
+		}
+	}
+	for name, value := range map[string]int{
+		"initialWindowSize":     s.InitialWindowSize,
+		"initialConnWindowSize": s.InitialConnWindowSize,
+	} {
+		if value != 0 && (value < minWindowSize || value > math.MaxInt32) {
+			return nil, fmt.Errorf("Invalid %s [%d], expected between %d and %d", name, value, minWindowSize, math.MaxInt32)
+		}
+	}
+	if s.MaxConnectionAgeGrace != 0 && s.MaxConnectionAge == 0 {
+		return nil, fmt.Errorf("Invalid maxConnectionAgeGrace [%d] without maxConnectionAge", s.MaxConnectionAgeGrace)
+	}
+	if s.KeepaliveTime != 0 && s.KeepaliveMinTime != 0 && s.KeepaliveTime < s.KeepaliveMinTime {
+		t.Logger.Warnf("keepaliveTime [%d] is below keepaliveMinTime [%d], clients of other gateways with the same settings would be disconnected", s.KeepaliveTime, s.KeepaliveMinTime)
+	}
+	if s.GRPCWeb || s.Multiplex {
+		var ignored []string
+		for name, set := range map[string]bool{
+			"keepaliveTime":                s.KeepaliveTime != 0,
+			"keepaliveTimeout":             s.KeepaliveTimeout != 0,
+			"keepaliveMinTime":             s.KeepaliveMinTime != 0,
+			"keepalivePermitWithoutStream": s.KeepalivePermitWithoutStream,
+			"maxConnectionAge":             s.MaxConnectionAge != 0,
+			"maxConnectionAgeGrace":        s.MaxConnectionAgeGrace != 0,
+		} {
+			if set {
+				ignored = append(ignored, name)
+			}
+		}
+		if len(ignored) > 0 {
+			sort.Strings(ignored)
+			t.Logger.Warnf("%s not supported by the HTTP server of the multiplexed listeners, ignored", strings.Join(ignored, ", "))
+		}
+	}
+
+	var opts []grpc.ServerOption
+	if s.MaxRecvMsgSize != 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(s.MaxRecvMsgSize))
+	}
+	if s.MaxSendMsgSize != 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(s.MaxSendMsgSize))
+	}
+	if s.MaxConcurrentStreams != 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(uint32(s.MaxConcurrentStreams)))
+	}
+	if s.KeepaliveTime != 0 || s.KeepaliveTimeout != 0 || s.MaxConnectionIdle != 0 || s.MaxConnectionAge != 0 {
+		opts = append(opts, grpc.KeepaliveParams(keepalive.ServerParameters{
+			MaxConnectionIdle:     seconds(s.MaxConnectionIdle),
+			MaxConnectionAge:      seconds(s.MaxConnectionAge),
+			MaxConnectionAgeGrace: seconds(s.MaxConnectionAgeGrace),
+			Time:                  seconds(s.KeepaliveTime),
+			Timeout:               seconds(s.KeepaliveTimeout),
+		}))
+	}
+	if s.KeepaliveMinTime != 0 || s.KeepalivePermitWithoutStream {
+		opts = append(opts, grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             seconds(s.KeepaliveMinTime),
+			PermitWithoutStream: s.KeepalivePermitWithoutStream,
+		}))
+	}
+	if s.ConnectionTimeout != 0 {
+		opts = append(opts, grpc.ConnectionTimeout(seconds(s.ConnectionTimeout)))
+	}
+	if s.InitialWindowSize != 0 {
+		opts = append(opts, grpc.InitialWindowSize(int32(s.InitialWindowSize)))
+	}
+	if s.InitialConnWindowSize != 0 {
+		opts = append(opts, grpc.InitialConnWindowSize(int32(s.InitialConnWindowSize)))
+	}
+	return opts, nil
+}
+
+// tuneMultiplexed applies the tuning settings to the HTTP servers of the multiplexed listeners, the connections of
+// these listeners are managed by the HTTP servers rather than by the gRPC server whose transport options do not apply
+// to them, the keepalive and the connection age have no equivalent and are ignored
+func (t *Trigger) tuneMultiplexed(server *http.Server, h2s *http2.Server) {
+	s := t.settings
+	if s.MaxConcurrentStreams != 0 {
+		h2s.MaxConcurrentStreams = uint32(s.MaxConcurrentStreams)
+	}
+	if s.MaxConnectionIdle != 0 {
+		h2s.IdleTimeout = seconds(s.MaxConnectionIdle)
+		server.IdleTimeout = seconds(s.MaxConnectionIdle)
+	}
+	if s.ConnectionTimeout != 0 {
+		// the deadline of the TLS handshake and of the first request, the HTTP/2 preface of h2c included
+		server.ReadHeaderTimeout = seconds(s.ConnectionTimeout)
+	}
+	if s.InitialWindowSize != 0 {
+		h2s.MaxUploadBufferPerStream = int32(s.InitialWindowSize)
+	}
+	if s.InitialConnWindowSize != 0 {
+		h2s.MaxUploadBufferPerConnection = int32(s.InitialConnWindowSize)
+	}
+}
+
+// maxRecvMsgSize returns the size of the largest message the server receives, the default of gRPC when not set
+func (t *Trigger) maxRecvMsgSize() int {
+	if t.settings.MaxRecvMsgSize != 0 {
+		return t.settings.MaxRecvMsgSize
+	}
+	return defaultMaxRecvMsgSize
+}
+
+func seconds(value int) time.Duration {
+	return time.Duration(value) * time.Second
+}
@@ -0,0 +1,269 @@
+package grpc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/project-flogo/grpc/support"
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// errHalfClose is returned for the empty text frame a WebSocket client sends to end its request stream
+var errHalfClose = errors.New("end of request stream")
+
+// frameError is a frame which is not a message of the method
+type frameError struct {
+	err error
+}
+
+func (e frameError) Error() string {
+	return e.err.Error()
+}
+
+// frameCodec converts text frames to JSON messages and binary frames to protobuf messages
+var frameCodec = websocket.Codec{
+	Marshal: func(v interface{}) ([]byte, byte, error) {
+		msg := v.(*support.DynamicMessage)
+		b, err := msg.Marshal()
+		return b, websocket.BinaryFrame, err
+	},
+	Unmarshal: func(data []byte, payloadType byte, v interface{}) error {
+		msg := v.(*support.DynamicMessage)
+		if payloadType == websocket.TextFrame {
+			if len(data) == 0 {
+				return errHalfClose
+			}
+			if err := msg.UnmarshalJSON(data); err != nil {
+				return frameError{err}
+			}
+			// the JSON value is checked against the descriptor
+			b, err := msg.Marshal()
+			if err != nil {
+				return frameError{err}
+			}
+			data = b
+		}
+		if err := msg.Unmarshal(data); err != nil {
+			return frameError{err}
+		}
+		return nil
+	},
+}
+
+// WebSocketCloseCode maps a gRPC status code to the code of the close frame ending a WebSocket bridge,
+// OK is a normal closure and any other code is sent as 4000 plus the gRPC code
+func WebSocketCloseCode(code codes.Code) int {
+	if code == codes.OK {
+		return 1000
+	}
+	return 4000 + int(code)
+}
+
+// webSocketStream presents a WebSocket to the stream handler of a client streaming or bidirectional method, the frames
+// sent by the client are the request messages and the response messages are written back as frames, binary ones with
+// ?format=binary
+type webSocketStream struct {
+	ws           *websocket.Conn
+	ctx          context.Context
+	cancel       context.CancelFunc
+	descriptors  *support.Descriptors
+	inputType    string
+	outputType   string
+	binaryFrames bool
+	maxSendSize  int
+
+	mutex sync.Mutex
+	// invalid is the frame error that ended the request stream
+	invalid error
+}
+
+// SetHeader implements grpc.ServerStream.SetHeader, the headers of the WebSocket are sent with its handshake
+func (s *webSocketStream) SetHeader(metadata.MD) error {
+	return nil
+}
+
+func (s *webSocketStream) SendHeader(metadata.MD) error {
+	return nil
+}
+
+func (s *webSocketStream) SetTrailer(metadata.MD) {
+}
+
+func (s *webSocketStream) Context() context.Context {
+	return s.ctx
+}
+
+// RecvMsg receives the next frame, the empty text frame of the client ends the request stream
+func (s *webSocketStream) RecvMsg(m interface{}) error {
+	req, err := s.descriptors.NewDynamicMessage(s.inputType)
+	if err == nil {
+		err = frameCodec.Receive(s.ws, req)
+	}
+	switch e := err.(type) {
+	case nil:
+	case frameError:
+		s.mutex.Lock()
+		s.invalid = e
+		s.mutex.Unlock()
+		return status.Error(codes.InvalidArgument, e.Error())
+	default:
+		if err == errHalfClose {
+			return io.EOF
+		}
+		if err == websocket.ErrFrameTooLarge {
+			return status.Errorf(codes.ResourceExhausted, "frame larger than max (%d)", s.ws.MaxPayloadBytes)
+		}
+		// the client closed the WebSocket
+		s.cancel()
+		return status.Error(codes.Canceled, "WebSocket closed by the client")
+	}
+
+	if msg, ok := m.(*support.DynamicMessage); ok {
+		msg.Value = req.Value
+		return nil
+	}
+	b, err := req.Marshal()
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, m.(proto.Message))
+}
+
+// SendMsg writes a response message as a JSON text frame, or as a binary frame
+func (s *webSocketStream) SendMsg(m interface{}) error {
+	var b []byte
+	var err error
+	if msg, ok := m.(*support.DynamicMessage); ok {
+		b, err = msg.Marshal()
+	} else {
+		b, err = proto.Marshal(m.(proto.Message))
+	}
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if s.maxSendSize > 0 && len(b) > s.maxSendSize {
+		return status.Errorf(codes.ResourceExhausted, "trying to send message larger than max (%d vs. %d)", len(b), s.maxSendSize)
+	}
+
+	if s.binaryFrames {
+		err = websocket.Message.Send(s.ws, b)
+	} else {
+		var res *support.DynamicMessage
+		if res, err = s.descriptors.NewDynamicMessage(s.outputType); err == nil {
+			if err = res.Unmarshal(b); err == nil {
+				if b, err = res.MarshalJSON(); err == nil {
+					err = websocket.Message.Send(s.ws, string(b))
+				}
+			}
+		}
+	}
+	if err != nil {
+		s.cancel()
+		return status.Error(codes.Canceled, "WebSocket closed: "+err.Error())
+	}
+	return nil
+}
+
+// invalidFrame returns the frame error that ended the request stream
+func (s *webSocketStream) invalidFrame() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.invalid
+}
+
+// serveWebSocket bridges a WebSocket to a client streaming or bidirectional method, its stream handler is invoked
+// in-process through the interceptors of the trigger like the calls over HTTP, with the HTTP client as peer and the TLS
+// state of its connection
+func (t *Trigger) serveWebSocket(d *support.Descriptors, fullServiceName, methodName, inputType, outputType string, serverStreams bool, desc grpc.StreamDesc, srv interface{}) websocket.Handler {
+	return func(ws *websocket.Conn) {
+		ws.MaxPayloadBytes = t.maxRecvMsgSize()
+		ctx, cancel := context.WithCancel(httpCallContext(ws.Request()))
+		defer cancel()
+
+		stream := &webSocketStream{
+			ws:           ws,
+			ctx:          ctx,
+			cancel:       cancel,
+			descriptors:  d,
+			inputType:    inputType,
+			outputType:   outputType,
+			binaryFrames: ws.Request().URL.Query().Get("format") == "binary",
+			maxSendSize:  t.settings.MaxSendMsgSize,
+		}
+		err := t.invokeStream("/"+fullServiceName+"/"+methodName, stream, true, serverStreams, func(_ interface{}, stream grpc.ServerStream) error {
+			return desc.Handler(srv, stream)
+		})
+		if invalid := stream.invalidFrame(); invalid != nil {
+			t.Logger.Debugf("Invalid frame for method [%s]: %s", methodName, invalid.Error())
+			writeClose(ws, 1007, invalid.Error())
+			return
+		}
+		if err != nil && ctx.Err() != nil {
+			t.Logger.Debugf("WebSocket of method [%s] closed: %s", methodName, err.Error())
+		}
+		closeWebSocket(ws, err)
+	}
+}
+
+// streamHandler returns the description of a streaming method of a service and the implementation its handler is
+// invoked with, services generated before the WebSocket bridges were invoked in-process do not provide them
+func streamHandler(service ServerService, t *Trigger, methodName string) (grpc.StreamDesc, interface{}, bool) {
+	s, ok := service.(StreamService)
+	if !ok {
+		return grpc.StreamDesc{}, nil, false
+	}
+	desc, srv := s.ServiceDesc(t)
+	for _, stream := range desc.Streams {
+		if stream.StreamName == methodName {
+			return stream, srv, true
+		}
+	}
+	return grpc.StreamDesc{}, nil, false
+}
+
+// headerMetadata passes the headers of the WebSocket handshake as request metadata
+func headerMetadata(header http.Header) metadata.MD {
+	md := metadata.MD{}
+	for key, values := range header {
+		key = strings.ToLower(key)
+		switch {
+		case key == "connection", key == "upgrade", key == "host", key == "origin", strings.HasPrefix(key, "sec-websocket-"):
+			continue
+		}
+		md[key] = values
+	}
+	return md
+}
+
+// closeWebSocket ends the WebSocket with the close code of the status of the call
+func closeWebSocket(ws *websocket.Conn, err error) {
+	s := status.Convert(err)
+	writeClose(ws, WebSocketCloseCode(s.Code()), s.Message())
+}
+
+func writeClose(ws *websocket.Conn, code int, reason string) {
+	// the payload of a control frame is limited to 125 bytes, the reason is cut between two characters
+	if len(reason) > 123 {
+		cut := 123
+		for cut > 0 && !utf8.RuneStart(reason[cut]) {
+			cut--
+		}
+		reason = reason[:cut]
+	}
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	ws.PayloadType = websocket.CloseFrame
+	ws.Write(payload)
+}
@@ -1,15 +1,55 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/project-flogo/core/support/log"
 	"github.com/project-flogo/core/trigger"
+	grpcactivity "github.com/project-flogo/grpc/activity"
 	"github.com/project-flogo/grpc/proto/grpc2grpc"
-	_ "github.com/project-flogo/grpc/trigger/grpc"
+	"github.com/project-flogo/grpc/support"
+	"github.com/project-flogo/grpc/trigger/grpc"
 	"github.com/project-flogo/grpc/util"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 type handler struct {
@@ -39,6 +79,152 @@ func (h *handler) Handle(ctx context.Context, triggerData interface{}) (map[stri
 	}, nil
 }
 
+const triggerRef = "github.com/project-flogo/grpc/trigger/grpc"
+
+type handleFunc func(ctx context.Context, output *grpc.Output) (map[string]interface{}, error)
+
+// testHandler is a handler of the trigger which records the last output it was invoked with, it replies the pet 2
+// unless it is given a handle function
+type testHandler struct {
+	settings map[string]interface{}
+	handle   handleFunc
+
+	mutex  sync.Mutex
+	output *grpc.Output
+}
+
+// newTestHandler returns a handler of the methods of the settings, of all the methods of the PetStoreService when
+// the settings are nil
+func newTestHandler(settings map[string]interface{}, handle handleFunc) *testHandler {
+	if settings == nil {
+		settings = map[string]interface{}{"serviceName": "PetStoreService"}
+	}
+	if handle == nil {
+		handle = replyPet
+	}
+	return &testHandler{settings: settings, handle: handle}
+}
+
+func (h *testHandler) Name() string {
+	return "test"
+}
+
+func (h *testHandler) Settings() map[string]interface{} {
+	return h.settings
+}
+
+func (h *testHandler) Handle(ctx context.Context, triggerData interface{}) (map[string]interface{}, error) {
+	output := triggerData.(*grpc.Output)
+	h.mutex.Lock()
+	h.output = output
+	h.mutex.Unlock()
+	return h.handle(ctx, output)
+}
+
+func (h *testHandler) handled() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.output != nil
+}
+
+// grpcData returns a value of the gRPC data of the last call handled
+func (h *testHandler) grpcData(key string) interface{} {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.output == nil {
+		return nil
+	}
+	return h.output.GrpcData[key]
+}
+
+func (h *testHandler) reset() {
+	h.mutex.Lock()
+	h.output = nil
+	h.mutex.Unlock()
+}
+
+func replyPet(ctx context.Context, output *grpc.Output) (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"code": 200,
+		"data": map[string]interface{}{
+			"pet": map[string]interface{}{
+				"id":   2,
+				"name": "pet2",
+			},
+		},
+	}, nil
+}
+
+// streamUsers sends a user with the stream writer and replies two more
+func streamUsers(ctx context.Context, output *grpc.Output) (map[string]interface{}, error) {
+	writer := output.GrpcData["streamWriter"].(grpc.StreamWriter)
+	err := writer.Send(map[string]interface{}{"id": 1, "username": "user1"})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"code": 200,
+		"data": []interface{}{
+			map[string]interface{}{"id": 2, "username": "user2"},
+			&grpc2grpc.User{Id: 3, Username: "user3"},
+		},
+	}, nil
+}
+
+// storeUsers replies the names of the users of an aggregated stream
+func storeUsers(ctx context.Context, output *grpc.Output) (map[string]interface{}, error) {
+	users := output.Content.([]interface{})
+	names := ""
+	for _, user := range users {
+		names += user.(map[string]interface{})["username"].(string) + " "
+	}
+	return map[string]interface{}{
+		"code": 200,
+		"data": map[string]interface{}{"msg": "stored " + names},
+	}, nil
+}
+
+// echoUsers replies each user of a stream with the tenant of the call
+func echoUsers(ctx context.Context, output *grpc.Output) (map[string]interface{}, error) {
+	sequence := output.GrpcData["sequence"].(int)
+	username := output.Params["username"].(string)
+	// later messages are handled faster to check the replies keep the order of the messages
+	time.Sleep(time.Duration(4-sequence) * 10 * time.Millisecond)
+	switch username {
+	case "fail":
+		return nil, errors.New("invalid user")
+	case "skip":
+		return map[string]interface{}{"code": 200}, nil
+	}
+	tenant := output.GrpcData["metadata"].(map[string]interface{})["tenant"].(string)
+	return map[string]interface{}{
+		"code": 200,
+		"data": []interface{}{
+			map[string]interface{}{"id": sequence, "username": username + "@" + tenant},
+		},
+	}, nil
+}
+
+// blockUntil signals each call on started and replies a user once release is closed
+func blockUntil(started, release chan struct{}) handleFunc {
+	return func(ctx context.Context, output *grpc.Output) (map[string]interface{}, error) {
+		started <- struct{}{}
+		<-release
+		return map[string]interface{}{
+			"code": 200,
+			"data": []interface{}{map[string]interface{}{"id": 1, "username": "user1"}},
+		}, nil
+	}
+}
+
+// delayed replies a user after the delay
+func delayed(delay time.Duration) handleFunc {
+	return func(ctx context.Context, output *grpc.Output) (map[string]interface{}, error) {
+		time.Sleep(delay)
+		return map[string]interface{}{"code": 200, "data": map[string]interface{}{"user": map[string]interface{}{"id": 2}}}, nil
+	}
+}
+
 type triggerInitContext struct {
 	handlers []trigger.Handler
 }
@@ -51,6 +237,62 @@ func (i *triggerInitContext) GetHandlers() []trigger.Handler {
 	return i.handlers
 }
 
+// freePort returns a port no listener is bound to
+func freePort(t *testing.T) int {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	return lis.Addr().(*net.TCPAddr).Port
+}
+
+// newTrigger creates and initializes a trigger of the petstore proto with the handlers, the port defaults to a free
+// port unless listeners are given
+func newTrigger(t *testing.T, settings map[string]interface{}, handlers ...*testHandler) (trigger.Trigger, error) {
+	if _, ok := settings["protoName"]; !ok {
+		settings["protoName"] = "petstore"
+	}
+	if settings["port"] == nil && settings["listeners"] == nil {
+		settings["port"] = freePort(t)
+	}
+	config := &trigger.Config{Id: "test", Settings: settings}
+	initContext := &triggerInitContext{}
+	for _, h := range handlers {
+		config.Handlers = append(config.Handlers, &trigger.HandlerConfig{Settings: h.Settings()})
+		initContext.handlers = append(initContext.handlers, h)
+	}
+	instance, err := trigger.GetFactory(triggerRef).New(config)
+	if err != nil {
+		return nil, err
+	}
+	return instance, instance.Initialize(initContext)
+}
+
+// startTrigger starts a trigger created by newTrigger until the end of the test and returns the address of its port
+func startTrigger(t *testing.T, settings map[string]interface{}, handlers ...*testHandler) string {
+	instance, err := newTrigger(t, settings, handlers...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := instance.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		instance.Stop()
+	})
+
+	if httpPort, ok := settings["httpPort"].(int); ok {
+		util.Pour(strconv.Itoa(httpPort))
+	}
+	port, ok := settings["port"].(int)
+	if !ok {
+		return ""
+	}
+	util.Pour(strconv.Itoa(port))
+	return "localhost:" + strconv.Itoa(port)
+}
+
 func TestGRPCTrigger(t *testing.T) {
 	factory := trigger.GetFactory("github.com/project-flogo/grpc/trigger/grpc")
 	assert.NotNil(t, factory)
@@ -91,3 +333,1350 @@ func TestGRPCTrigger(t *testing.T) {
 	assert.Nil(t, err)
 	assert.True(t, h.handled)
 }
+
+func TestGRPCTriggerServerStream(t *testing.T) {
+	h := newTestHandler(map[string]interface{}{
+		"serviceName": "PetStoreService",
+		"methodName":  "ListUsers",
+	}, streamUsers)
+	addr := startTrigger(t, map[string]interface{}{}, h)
+
+	conn, err := ggrpc.Dial(addr, ggrpc.WithInsecure())
+	assert.Nil(t, err)
+	defer conn.Close()
+	users, err := grpc2grpc.NewPetStoreServiceHarnessClient(conn).ListUsers(context.Background(), `{}`)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		`{"id":1,"username":"user1"}`,
+		`{"id":2,"username":"user2"}`,
+		`{"id":3,"username":"user3"}`,
+	}, users)
+}
+
+func TestGRPCTriggerClientStreamAggregate(t *testing.T) {
+	h := newTestHandler(map[string]interface{}{
+		"serviceName": "PetStoreService",
+		"methodName":  "StoreUsers",
+		"streamMode":  "aggregate",
+		"maxMessages": 2,
+	}, storeUsers)
+	addr := startTrigger(t, map[string]interface{}{}, h)
+
+	conn, err := ggrpc.Dial(addr, ggrpc.WithInsecure())
+	assert.Nil(t, err)
+	defer conn.Close()
+	client := grpc2grpc.NewPetStoreServiceHarnessClient(conn)
+	res, err := client.StoreUsers(context.Background(), []string{`{"id":1,"username":"user1"}`, `{"id":2,"username":"user2"}`})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"msg":"stored user1 user2 "}`, res)
+	// the messages follow the proto3 JSON mapping like the content of the other methods
+	h.mutex.Lock()
+	content, err := json.Marshal(h.output.Content)
+	h.mutex.Unlock()
+	assert.Nil(t, err)
+	assert.JSONEq(t, `[{"id":1,"username":"user1"},{"id":2,"username":"user2"}]`, string(content))
+
+	_, err = client.StoreUsers(context.Background(), []string{`{"id":1}`, `{"id":2}`, `{"id":3}`})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// the stream modes which do not fit the streaming kind of the method fail the initialization
+	for method, mode := range map[string]string{
+		"PetById":    "aggregate",
+		"BulkUsers":  "aggregate",
+		"ListUsers":  "message",
+		"StoreUsers": "message",
+	} {
+		_, err = newTrigger(t, map[string]interface{}{}, newTestHandler(map[string]interface{}{
+			"serviceName": "PetStoreService",
+			"methodName":  method,
+			"streamMode":  mode,
+		}, nil))
+		assert.NotNil(t, err, method)
+	}
+}
+
+func TestGRPCTriggerBidiStreamMessages(t *testing.T) {
+	h := newTestHandler(map[string]interface{}{
+		"serviceName": "PetStoreService",
+		"methodName":  "BulkUsers",
+		"streamMode":  "message",
+		"ordering":    "parallel",
+		"maxParallel": 3,
+	}, echoUsers)
+	addr := startTrigger(t, map[string]interface{}{}, h)
+
+	conn, err := ggrpc.Dial(addr, ggrpc.WithInsecure())
+	assert.Nil(t, err)
+	defer conn.Close()
+	client := grpc2grpc.NewPetStoreServiceHarnessClient(conn)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "tenant", "acme")
+	users, err := client.BulkUsers(ctx, []string{`{"username":"user1"}`, `{"username":"skip"}`, `{"username":"user3"}`})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		`{"id":1,"username":"user1@acme"}`,
+		`{"id":3,"username":"user3@acme"}`,
+	}, users)
+
+	users, err = client.BulkUsers(ctx, []string{`{"username":"user1"}`, `{"username":"fail"}`, `{"username":"user3"}`})
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{`{"id":1,"username":"user1@acme"}`}, users)
+}
+
+func TestGRPCTriggerHTTPStream(t *testing.T) {
+	harness, err := grpc2grpc.NewPetStoreServiceHarness(filepath.FromSlash("./proto/grpc2grpc/petstore.PetStoreService.fixtures.json"))
+	assert.Nil(t, err)
+	addr, err := harness.Start("localhost:0")
+	assert.Nil(t, err)
+	defer harness.Stop()
+	act, err := grpcactivity.New(newInitContext(map[string]interface{}{
+		"operatingMode": "rest-to-grpc",
+		"hosturl":       addr,
+	}))
+	assert.Nil(t, err)
+
+	// the stream is forwarded to the harness by the activity
+	h := newTestHandler(map[string]interface{}{
+		"serviceName": "PetStoreService",
+		"methodName":  "ListUsers",
+	}, func(ctx context.Context, output *grpc.Output) (map[string]interface{}, error) {
+		actx := newActivityContext(map[string]interface{}{
+			"protoName":    "petstore",
+			"serviceName":  "PetStoreService",
+			"methodName":   "ListUsers",
+			"content":      output.Content,
+			"streamWriter": output.GrpcData["streamWriter"],
+		})
+		_, err := act.Eval(actx)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"code": 200}, nil
+	})
+	httpPort := freePort(t)
+	startTrigger(t, map[string]interface{}{"httpPort": httpPort}, h)
+	url := "http://localhost:" + strconv.Itoa(httpPort)
+
+	request, err := http.NewRequest(http.MethodPost, url+"/PetStoreService/ListUsers", strings.NewReader(`{"msg": "all"}`))
+	assert.Nil(t, err)
+	request.Header.Set("Accept", "text/event-stream")
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+	assert.Equal(t, "data: {\"id\":2,\"username\":\"user2\"}\n\n"+
+		"data: {\"id\":3,\"username\":\"user3\"}\n\n"+
+		"data: {\"id\":4,\"username\":\"user4\"}\n\n", string(body))
+
+	response, err = http.Post(url+"/PetStoreService/ListUsers", "application/json", strings.NewReader(`{}`))
+	assert.Nil(t, err)
+	body, err = ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, "application/x-ndjson", response.Header.Get("Content-Type"))
+	assert.Equal(t, 3, strings.Count(string(body), "\n"))
+
+	response, err = http.Post(url+"/PetStoreService/ListUsers", "application/json", strings.NewReader(`{"msg": 1}`))
+	assert.Nil(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestGRPCTriggerWebSocket(t *testing.T) {
+	h := newTestHandler(map[string]interface{}{
+		"serviceName": "PetStoreService",
+		"methodName":  "BulkUsers",
+		"streamMode":  "message",
+	}, echoUsers)
+	httpPort := freePort(t)
+	startTrigger(t, map[string]interface{}{"httpPort": httpPort}, h)
+	url := "ws://localhost:" + strconv.Itoa(httpPort)
+
+	dial := func(url string) *websocket.Conn {
+		wsConfig, err := websocket.NewConfig(url, "http://localhost/")
+		assert.Nil(t, err)
+		wsConfig.Header.Set("Tenant", "acme")
+		ws, err := websocket.DialConfig(wsConfig)
+		assert.Nil(t, err)
+		return ws
+	}
+
+	ws := dial(url + "/PetStoreService/BulkUsers")
+	assert.Nil(t, websocket.Message.Send(ws, `{"username": "user1"}`))
+	assert.Nil(t, websocket.Message.Send(ws, `{"username": "user2"}`))
+	assert.Nil(t, websocket.Message.Send(ws, ""))
+	var frame string
+	assert.Nil(t, websocket.Message.Receive(ws, &frame))
+	assert.Equal(t, `{"id":1,"username":"user1@acme"}`, frame)
+	assert.Nil(t, websocket.Message.Receive(ws, &frame))
+	assert.Equal(t, `{"id":2,"username":"user2@acme"}`, frame)
+	assert.Equal(t, io.EOF, websocket.Message.Receive(ws, &frame))
+	ws.Close()
+
+	ws = dial(url + "/PetStoreService/BulkUsers?format=binary")
+	b, err := proto.Marshal(&grpc2grpc.User{Username: "user1"})
+	assert.Nil(t, err)
+	assert.Nil(t, websocket.Message.Send(ws, b))
+	var binaryFrame []byte
+	assert.Nil(t, websocket.Message.Receive(ws, &binaryFrame))
+	user := &grpc2grpc.User{}
+	assert.Nil(t, proto.Unmarshal(binaryFrame, user))
+	assert.Equal(t, "user1@acme", user.Username)
+	ws.Close()
+
+	assert.Equal(t, 1000, grpc.WebSocketCloseCode(codes.OK))
+	assert.Equal(t, 4007, grpc.WebSocketCloseCode(codes.PermissionDenied))
+
+	// the stream handler is invoked in-process with the client as peer, also when every listener requires a client
+	// certificate
+	certPEM, keyPEM := selfSignedCert(t)
+	tlsPort := freePort(t)
+	startTrigger(t, map[string]interface{}{
+		"httpPort":   tlsPort,
+		"enableTLS":  true,
+		"serverCert": string(certPEM),
+		"serverKey":  string(keyPEM),
+		"listeners": []interface{}{
+			map[string]interface{}{
+				"address":      "tcp://127.0.0.1:" + strconv.Itoa(freePort(t)),
+				"enableTLS":    true,
+				"serverCert":   string(certPEM),
+				"serverKey":    string(keyPEM),
+				"clientCACert": string(certPEM),
+			},
+		},
+	}, h)
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(certPEM)
+	wsConfig, err := websocket.NewConfig("wss://localhost:"+strconv.Itoa(tlsPort)+"/PetStoreService/BulkUsers", "https://localhost/")
+	assert.Nil(t, err)
+	wsConfig.Header.Set("Tenant", "acme")
+	wsConfig.TlsConfig = &tls.Config{RootCAs: pool}
+	ws, err = websocket.DialConfig(wsConfig)
+	assert.Nil(t, err)
+	assert.Nil(t, websocket.Message.Send(ws, `{"username": "user1"}`))
+	assert.Nil(t, websocket.Message.Receive(ws, &frame))
+	assert.Equal(t, `{"id":1,"username":"user1@acme"}`, frame)
+	p, ok := peer.FromContext(h.grpcData("strmReq").(ggrpc.ServerStream).Context())
+	assert.True(t, ok)
+	assert.Equal(t, "127.0.0.1", p.Addr.(*net.TCPAddr).IP.String())
+	_, ok = p.AuthInfo.(credentials.TLSInfo)
+	assert.True(t, ok)
+	ws.Close()
+}
+
+// grpcWebFrames splits a gRPC-Web response body into its message frames and its trailer frame
+func grpcWebFrames(t *testing.T, body []byte) ([][]byte, string) {
+	var messages [][]byte
+	for len(body) >= 5 {
+		size := binary.BigEndian.Uint32(body[1:5])
+		frame := body[5 : 5+size]
+		if body[0]&0x80 != 0 {
+			return messages, string(frame)
+		}
+		messages = append(messages, frame)
+		body = body[5+size:]
+	}
+	t.Fatal("gRPC-Web response without trailers")
+	return nil, ""
+}
+
+func grpcWebFrame(msg proto.Message) []byte {
+	b, _ := proto.Marshal(msg)
+	frame := make([]byte, 5, 5+len(b))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(b)))
+	return append(frame, b...)
+}
+
+func TestGRPCTriggerGRPCWeb(t *testing.T) {
+	h := newTestHandler(nil, nil)
+	addr := startTrigger(t, map[string]interface{}{
+		"grpcWeb":        true,
+		"allowedOrigins": "http://app.example.com",
+		"allowedHeaders": "x-tenant",
+	}, h, newTestHandler(map[string]interface{}{
+		"serviceName": "PetStoreService",
+		"methodName":  "ListUsers",
+	}, streamUsers))
+	url := "http://" + addr
+
+	request, err := http.NewRequest(http.MethodOptions, url+"/grpc2grpc.PetStoreService/PetById", nil)
+	assert.Nil(t, err)
+	request.Header.Set("Origin", "http://app.example.com")
+	request.Header.Set("Access-Control-Request-Method", "POST")
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, "http://app.example.com", response.Header.Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, response.Header.Get("Access-Control-Allow-Headers"), "x-grpc-web")
+	assert.Contains(t, response.Header.Get("Access-Control-Allow-Headers"), "x-tenant")
+
+	request, err = http.NewRequest(http.MethodPost, url+"/grpc2grpc.PetStoreService/PetById", bytes.NewReader(grpcWebFrame(&grpc2grpc.PetByIdRequest{Id: 2})))
+	assert.Nil(t, err)
+	request.Header.Set("Content-Type", "application/grpc-web+proto")
+	request.Header.Set("Origin", "http://app.example.com")
+	response, err = http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, "application/grpc-web+proto", response.Header.Get("Content-Type"))
+	assert.Equal(t, "http://app.example.com", response.Header.Get("Access-Control-Allow-Origin"))
+	messages, trailers := grpcWebFrames(t, body)
+	assert.Contains(t, trailers, "grpc-status: 0\r\n")
+	assert.Len(t, messages, 1)
+	pet := &grpc2grpc.PetResponse{}
+	assert.Nil(t, proto.Unmarshal(messages[0], pet))
+	assert.Equal(t, "pet2", pet.GetPet().GetName())
+	assert.True(t, h.handled())
+
+	text := base64.StdEncoding.EncodeToString(grpcWebFrame(&grpc2grpc.EmptyReq{}))
+	response, err = http.Post(url+"/grpc2grpc.PetStoreService/ListUsers", "application/grpc-web-text", strings.NewReader(text))
+	assert.Nil(t, err)
+	body, err = ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, "application/grpc-web-text+proto", response.Header.Get("Content-Type"))
+	assert.Empty(t, response.Header.Get("Access-Control-Allow-Origin"))
+	// each flush of the response is a base64 chunk of its own
+	var decoded []byte
+	for _, chunk := range regexp.MustCompile(`[^=]+=*`).FindAllString(string(body), -1) {
+		b, err := base64.StdEncoding.DecodeString(chunk)
+		assert.Nil(t, err)
+		decoded = append(decoded, b...)
+	}
+	messages, trailers = grpcWebFrames(t, decoded)
+	assert.Contains(t, trailers, "grpc-status: 0\r\n")
+	assert.Len(t, messages, 3)
+	user := &grpc2grpc.User{}
+	assert.Nil(t, proto.Unmarshal(messages[2], user))
+	assert.Equal(t, "user3", user.GetUsername())
+
+	_, port, _ := net.SplitHostPort(addr)
+	method := "pet"
+	_, err = grpc2grpc.CallClient(&port, &method, "2", nil)
+	assert.Nil(t, err)
+}
+
+func TestGRPCTriggerTranscoding(t *testing.T) {
+	h := newTestHandler(nil, nil)
+	httpPort := freePort(t)
+	startTrigger(t, map[string]interface{}{"httpPort": httpPort, "maxRecvMsgSize": 64}, h)
+	url := "http://localhost:" + strconv.Itoa(httpPort)
+
+	response, err := http.Post(url+"/PetStoreService/PetById", "application/json", strings.NewReader(`{"id": 2}`))
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"pet": {"id": 2, "name": "pet2"}}`, string(body))
+	assert.True(t, h.handled())
+
+	response, err = http.Post(url+"/PetStoreService/PetById?id=2", "application/json", nil)
+	assert.Nil(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	response, err = http.Post(url+"/PetStoreService/PetById", "application/json", strings.NewReader(`{"owner": "me"}`))
+	assert.Nil(t, err)
+	body, err = ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	assert.Contains(t, string(body), "InvalidArgument")
+
+	// bodies are limited to maxRecvMsgSize
+	response, err = http.Post(url+"/PetStoreService/PetById", "application/json", strings.NewReader(`{"id": 2, "padding": "`+strings.Repeat("x", 64)+`"}`))
+	assert.Nil(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, response.StatusCode)
+
+	response, err = http.Get(url + "/PetStoreService/PetById")
+	assert.Nil(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, response.StatusCode)
+
+	response, err = http.Get(url + "/v1/pets")
+	assert.Nil(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestGRPCTriggerMultiplex(t *testing.T) {
+	grpc.RegisterHTTPHandler("/api/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("co-hosted " + r.URL.Path))
+	}))
+
+	h := newTestHandler(nil, nil)
+	addr := startTrigger(t, map[string]interface{}{"multiplex": true}, h)
+	url := "http://" + addr
+
+	_, port, _ := net.SplitHostPort(addr)
+	method := "pet"
+	_, err := grpc2grpc.CallClient(&port, &method, "2", nil)
+	assert.Nil(t, err)
+	assert.True(t, h.handled())
+
+	response, err := http.Post(url+"/PetStoreService/PetById", "application/json", strings.NewReader(`{"id": 2}`))
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"pet": {"id": 2, "name": "pet2"}}`, string(body))
+
+	response, err = http.Get(url + "/health")
+	assert.Nil(t, err)
+	body, err = ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.JSONEq(t, `{"status": "SERVING"}`, string(body))
+
+	response, err = http.Get(url + "/metrics")
+	assert.Nil(t, err)
+	body, err = ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	assert.Nil(t, err)
+	assert.Contains(t, string(body), `grpc_server_handled_total{grpc_service="grpc2grpc.PetStoreService",grpc_method="PetById",grpc_code="OK"} 1`)
+
+	response, err = http.Get(url + "/api/pets")
+	assert.Nil(t, err)
+	body, err = ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, "co-hosted /api/pets", string(body))
+}
+
+func TestGRPCTriggerReflection(t *testing.T) {
+	addr := startTrigger(t, map[string]interface{}{"reflection": true}, newTestHandler(nil, nil))
+
+	conn, err := ggrpc.Dial(addr, ggrpc.WithInsecure())
+	assert.Nil(t, err)
+	defer conn.Close()
+	ctx := context.Background()
+
+	d, err := support.ReflectionDescriptors(ctx, conn, "")
+	assert.Nil(t, err)
+	assert.Contains(t, d.Services(), "grpc2grpc.PetStoreService")
+	result, err := support.Invoke(ctx, conn, d, "grpc2grpc.PetStoreService/PetById", []interface{}{map[string]interface{}{"id": 2}})
+	assert.Nil(t, err)
+	assert.Equal(t, "OK", result.Status.Code)
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"pet": map[string]interface{}{"id": int32(2), "name": "pet2"},
+	}}, result.Responses)
+
+	d, err = support.ReflectionDescriptors(ctx, conn, "grpc2grpc.PetStoreService.PetById")
+	assert.Nil(t, err)
+	assert.NotNil(t, d.Message("grpc2grpc.PetByIdRequest"))
+	_, err = support.ReflectionDescriptors(ctx, conn, "grpc2grpc.Unknown")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "symbol not found")
+	}
+}
+
+// selfSignedCert returns a certificate for localhost which signs itself, usable by servers and clients
+func selfSignedCert(t *testing.T) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestGRPCTriggerListeners(t *testing.T) {
+	certPEM, keyPEM := selfSignedCert(t)
+	dir, err := ioutil.TempDir("", "listeners")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "gw.sock")
+	// a socket left by a process which exited is replaced
+	stale, err := net.Listen("unix", socket)
+	assert.Nil(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	plainPort, tlsPort := strconv.Itoa(freePort(t)), strconv.Itoa(freePort(t))
+	startTrigger(t, map[string]interface{}{
+		"listeners": []interface{}{
+			"tcp://127.0.0.1:" + plainPort,
+			"unix://" + socket,
+			map[string]interface{}{
+				"address":      "tcp://:" + tlsPort,
+				"enableTLS":    true,
+				"serverCert":   "base64," + base64.StdEncoding.EncodeToString(certPEM),
+				"serverKey":    "base64," + base64.StdEncoding.EncodeToString(keyPEM),
+				"clientCACert": "base64," + base64.StdEncoding.EncodeToString(certPEM),
+			},
+		},
+	}, newTestHandler(nil, nil))
+
+	call := func(target string, opts ...ggrpc.DialOption) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		conn, err := ggrpc.DialContext(ctx, target, opts...)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		res, err := grpc2grpc.NewPetStoreServiceClient(conn).PetById(ctx, &grpc2grpc.PetByIdRequest{Id: 2})
+		if err == nil {
+			assert.Equal(t, "pet2", res.GetPet().GetName())
+		}
+		return err
+	}
+
+	assert.Nil(t, call("127.0.0.1:"+plainPort, ggrpc.WithInsecure()))
+	assert.Nil(t, call(socket, ggrpc.WithInsecure(), ggrpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+		return net.DialTimeout("unix", addr, timeout)
+	})))
+
+	// a socket in use is not taken over
+	instance, err := newTrigger(t, map[string]interface{}{"listeners": []interface{}{"unix://" + socket}}, newTestHandler(nil, nil))
+	assert.Nil(t, err)
+	assert.NotNil(t, instance.Start())
+	instance.Stop()
+	assert.Nil(t, call(socket, ggrpc.WithInsecure(), ggrpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+		return net.DialTimeout("unix", addr, timeout)
+	})))
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(certPEM)
+	clientCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	assert.Nil(t, err)
+	mutualTLS := credentials.NewTLS(&tls.Config{RootCAs: pool, Certificates: []tls.Certificate{clientCert}})
+	assert.Nil(t, call("localhost:"+tlsPort, ggrpc.WithTransportCredentials(mutualTLS)))
+	serverTLS := credentials.NewTLS(&tls.Config{RootCAs: pool})
+	assert.NotNil(t, call("localhost:"+tlsPort, ggrpc.WithTransportCredentials(serverTLS)))
+	assert.NotNil(t, call("localhost:"+tlsPort, ggrpc.WithInsecure()))
+
+	// sessions are not resumed when client certificates are required, so that each handshake verifies the certificate
+	multiplexPort := strconv.Itoa(freePort(t))
+	startTrigger(t, map[string]interface{}{
+		"multiplex": true,
+		"listeners": []interface{}{
+			map[string]interface{}{
+				"address":      "tcp://:" + multiplexPort,
+				"enableTLS":    true,
+				"serverCert":   "base64," + base64.StdEncoding.EncodeToString(certPEM),
+				"serverKey":    "base64," + base64.StdEncoding.EncodeToString(keyPEM),
+				"clientCACert": "base64," + base64.StdEncoding.EncodeToString(certPEM),
+			},
+		},
+	}, newTestHandler(nil, nil))
+	config := &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{clientCert}, NextProtos: []string{"h2"}, ClientSessionCache: tls.NewLRUClientSessionCache(1)}
+	for i := 0; i < 2; i++ {
+		conn, err := tls.Dial("tcp", "localhost:"+multiplexPort, config)
+		if !assert.Nil(t, err) {
+			break
+		}
+		// the session tickets follow the handshake, with the settings of the server
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		conn.Read(make([]byte, 64))
+		assert.False(t, conn.ConnectionState().DidResume)
+		conn.Close()
+	}
+}
+
+func TestGRPCTriggerInterceptors(t *testing.T) {
+	var calls []string
+	var mutex sync.Mutex
+	record := func(call string) {
+		mutex.Lock()
+		calls = append(calls, call)
+		mutex.Unlock()
+	}
+	grpc.RegisterUnaryInterceptor("audit", func(ctx context.Context, req interface{}, info *ggrpc.UnaryServerInfo, handler ggrpc.UnaryHandler) (interface{}, error) {
+		record("audit " + info.FullMethod)
+		return handler(ctx, req)
+	})
+	grpc.RegisterStreamInterceptor("audit", func(srv interface{}, stream ggrpc.ServerStream, info *ggrpc.StreamServerInfo, handler ggrpc.StreamHandler) error {
+		record("audit stream " + info.FullMethod)
+		return handler(srv, stream)
+	})
+	grpc.RegisterUnaryInterceptor("deny", func(ctx context.Context, req interface{}, info *ggrpc.UnaryServerInfo, handler ggrpc.UnaryHandler) (interface{}, error) {
+		record("deny")
+		if md, _ := metadata.FromIncomingContext(ctx); len(md["x-deny"]) > 0 {
+			return nil, status.Error(codes.PermissionDenied, "denied")
+		}
+		return handler(ctx, req)
+	})
+
+	httpPort := freePort(t)
+	settings := map[string]interface{}{
+		"httpPort":     httpPort,
+		"interceptors": "unknown",
+	}
+	_, err := newTrigger(t, settings, newTestHandler(nil, nil))
+	assert.NotNil(t, err)
+
+	settings["interceptors"] = "audit, deny"
+	addr := startTrigger(t, settings, newTestHandler(nil, nil), newTestHandler(map[string]interface{}{
+		"serviceName": "PetStoreService",
+		"methodName":  "ListUsers",
+	}, streamUsers))
+	url := "http://localhost:" + strconv.Itoa(httpPort)
+
+	conn, err := ggrpc.Dial(addr, ggrpc.WithInsecure())
+	assert.Nil(t, err)
+	defer conn.Close()
+	client := grpc2grpc.NewPetStoreServiceClient(conn)
+	_, err = client.PetById(context.Background(), &grpc2grpc.PetByIdRequest{Id: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"audit /grpc2grpc.PetStoreService/PetById", "deny"}, calls)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-deny", "1")
+	_, err = client.PetById(ctx, &grpc2grpc.PetByIdRequest{Id: 2})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	request, err := http.NewRequest(http.MethodPost, url+"/PetStoreService/PetById", strings.NewReader(`{"id": 2}`))
+	assert.Nil(t, err)
+	request.Header.Set("X-Deny", "1")
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusForbidden, response.StatusCode)
+
+	calls = nil
+	response, err = http.Post(url+"/PetStoreService/ListUsers", "application/json", strings.NewReader(`{}`))
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, strings.Count(string(body), "\n"))
+	assert.Equal(t, []string{"audit stream /grpc2grpc.PetStoreService/ListUsers"}, calls)
+}
+
+// signJWT returns a compact JWS token of the claims signed with the ES256 or RS256 algorithm
+func signJWT(t *testing.T, key crypto.Signer, kid string, claims map[string]interface{}) string {
+	header := map[string]interface{}{"alg": "RS256", "typ": "JWT"}
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		header["alg"] = "ES256"
+	}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	segment := func(value interface{}) string {
+		b, err := json.Marshal(value)
+		assert.Nil(t, err)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	input := segment(header) + "." + segment(claims)
+	digest := sha256.Sum256([]byte(input))
+	var signature []byte
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		assert.Nil(t, err)
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	case *rsa.PrivateKey:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		assert.Nil(t, err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestGRPCTriggerJWT(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	assert.Nil(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	jwks, err := json.Marshal(map[string]interface{}{"keys": []interface{}{map[string]interface{}{
+		"kty": "RSA",
+		"kid": "rsa1",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+	}}})
+	assert.Nil(t, err)
+	jwksFile := filepath.Join(os.TempDir(), "grpc-trigger-jwks.json")
+	assert.Nil(t, ioutil.WriteFile(jwksFile, jwks, 0600))
+	defer os.Remove(jwksFile)
+
+	h := newTestHandler(map[string]interface{}{
+		"serviceName":    "PetStoreService",
+		"methodName":     "PetById",
+		"requiredScopes": "pets.read",
+	}, nil)
+	httpPort := freePort(t)
+	addr := startTrigger(t, map[string]interface{}{
+		"httpPort":      httpPort,
+		"jwtIssuer":     "https://issuer.example.com",
+		"jwtAudience":   "petstore",
+		"jwtAlgorithms": "ES256,RS256",
+		"jwtKeys":       "base64," + base64.StdEncoding.EncodeToString(keyPEM),
+		"jwks":          "file://" + jwksFile,
+		"jwtRequireExp": true,
+	}, h)
+	url := "http://localhost:" + strconv.Itoa(httpPort)
+
+	conn, err := ggrpc.Dial(addr, ggrpc.WithInsecure())
+	assert.Nil(t, err)
+	defer conn.Close()
+	client := grpc2grpc.NewPetStoreServiceClient(conn)
+	call := func(token string) error {
+		ctx := context.Background()
+		if token != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+		}
+		_, err := client.PetById(ctx, &grpc2grpc.PetByIdRequest{Id: 2})
+		return err
+	}
+	claims := func(scope string) map[string]interface{} {
+		return map[string]interface{}{
+			"iss":   "https://issuer.example.com",
+			"aud":   []string{"petstore"},
+			"sub":   "user1",
+			"scope": scope,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}
+	}
+	subject := func() interface{} {
+		claims, _ := h.grpcData("claims").(map[string]interface{})
+		return claims["sub"]
+	}
+
+	assert.Equal(t, codes.Unauthenticated, status.Code(call("")))
+	assert.False(t, h.handled())
+
+	assert.Nil(t, call(signJWT(t, ecKey, "", claims("pets.read pets.write"))))
+	assert.Equal(t, "user1", subject())
+
+	h.reset()
+	assert.Nil(t, call(signJWT(t, rsaKey, "rsa1", claims("pets.read"))))
+	assert.Equal(t, "user1", subject())
+
+	assert.Equal(t, codes.PermissionDenied, status.Code(call(signJWT(t, ecKey, "", claims("pets.write")))))
+
+	expired := claims("pets.read")
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	assert.Equal(t, codes.Unauthenticated, status.Code(call(signJWT(t, ecKey, "", expired))))
+	delete(expired, "exp")
+	assert.Equal(t, codes.Unauthenticated, status.Code(call(signJWT(t, ecKey, "", expired))))
+
+	// a key missing from the JWKS is looked up again, the other tokens keep being verified meanwhile
+	assert.Equal(t, codes.Unauthenticated, status.Code(call(signJWT(t, rsaKey, "rsa2", claims("pets.read")))))
+	assert.Nil(t, call(signJWT(t, rsaKey, "rsa1", claims("pets.read"))))
+
+	wrongAudience := claims("pets.read")
+	wrongAudience["aud"] = "other"
+	assert.Equal(t, codes.Unauthenticated, status.Code(call(signJWT(t, rsaKey, "rsa1", wrongAudience))))
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(call(signJWT(t, otherKey, "", claims("pets.read")))))
+
+	request, err := http.NewRequest(http.MethodPost, url+"/PetStoreService/PetById", strings.NewReader(`{"id": 2}`))
+	assert.Nil(t, err)
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+
+	request, err = http.NewRequest(http.MethodPost, url+"/PetStoreService/PetById", strings.NewReader(`{"id": 2}`))
+	assert.Nil(t, err)
+	request.Header.Set("Authorization", "Bearer "+signJWT(t, ecKey, "", claims("pets.read")))
+	response, err = http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
+
+func TestGRPCTriggerAPIKeys(t *testing.T) {
+	_, err := newTrigger(t, map[string]interface{}{
+		"apiKeys": map[string]interface{}{
+			"key-a": map[string]interface{}{"identity": "partner-a", "quota": -1},
+		},
+	}, newTestHandler(nil, nil))
+	assert.NotNil(t, err)
+
+	keysFile := filepath.Join(os.TempDir(), "grpc-trigger-apikeys.json")
+	assert.Nil(t, ioutil.WriteFile(keysFile, []byte(`{
+		"key-a": {"identity": "partner-a", "methods": ["PetStoreService/PetById"], "quota": 2, "quotaInterval": "1h"},
+		"key-b": {"identity": "partner-b", "methods": ["grpc2grpc.PetStoreService/UserByName"]}
+	}`), 0600))
+	defer os.Remove(keysFile)
+	defer func(unit time.Duration) { grpc.ReloadUnit = unit }(grpc.ReloadUnit)
+	grpc.ReloadUnit = 200 * time.Millisecond
+	h := newTestHandler(nil, nil)
+	addr := startTrigger(t, map[string]interface{}{
+		"apiKeys":       keysFile,
+		"apiKeysReload": 1,
+	}, h)
+
+	conn, err := ggrpc.Dial(addr, ggrpc.WithInsecure())
+	assert.Nil(t, err)
+	defer conn.Close()
+	client := grpc2grpc.NewPetStoreServiceClient(conn)
+	call := func(key string) error {
+		ctx := context.Background()
+		if key != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "x-api-key", key)
+		}
+		_, err := client.PetById(ctx, &grpc2grpc.PetByIdRequest{Id: 2})
+		return err
+	}
+
+	assert.Equal(t, codes.Unauthenticated, status.Code(call("")))
+	assert.Equal(t, codes.Unauthenticated, status.Code(call("key-c")))
+	assert.Equal(t, codes.PermissionDenied, status.Code(call("key-b")))
+	assert.False(t, h.handled())
+
+	assert.Nil(t, call("key-a"))
+	assert.Equal(t, "partner-a", h.grpcData("identity"))
+	assert.Nil(t, call("key-a"))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(call("key-a")))
+
+	assert.Nil(t, ioutil.WriteFile(keysFile, []byte(`{
+		"key-a": {"identity": "partner-a", "methods": ["PetStoreService/PetById"], "quota": 2, "quotaInterval": "1h"},
+		"key-c": {"identity": "partner-c", "methods": ["PetStoreService/*"]}
+	}`), 0600))
+	modTime := time.Now().Add(time.Minute)
+	assert.Nil(t, os.Chtimes(keysFile, modTime, modTime))
+	time.Sleep(250 * time.Millisecond)
+
+	assert.Nil(t, call("key-c"))
+	assert.Equal(t, "partner-c", h.grpcData("identity"))
+	assert.Equal(t, codes.Unauthenticated, status.Code(call("key-b")))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(call("key-a")))
+}
+
+func TestGRPCTriggerPolicy(t *testing.T) {
+	policyFile := filepath.Join(os.TempDir(), "grpc-trigger-policy.json")
+	assert.Nil(t, ioutil.WriteFile(policyFile, []byte(`{
+		"default": "deny",
+		"rules": [
+			{"name": "admins", "serviceName": "PetStoreService", "methodName": "PetById", "condition": "$.metadata[\"x-role\"] == \"admin\" && $.request.id < 10"},
+			{"name": "tenant", "serviceName": "PetStoreService", "condition": "$.metadata[\"x-tenant\"] == $.identity"}
+		]
+	}`), 0600))
+	defer os.Remove(policyFile)
+	auditFile := filepath.Join(os.TempDir(), "grpc-trigger-audit.log")
+	os.Remove(auditFile)
+	defer os.Remove(auditFile)
+
+	h := newTestHandler(nil, nil)
+	settings := map[string]interface{}{
+		"apiKeys":    map[string]interface{}{"key-a": map[string]interface{}{"identity": "t1"}},
+		"policyFile": policyFile,
+		"auditLog":   auditFile,
+	}
+	instance, err := newTrigger(t, settings, h)
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Start())
+	defer instance.Stop()
+
+	conn, err := ggrpc.Dial("localhost:"+strconv.Itoa(settings["port"].(int)), ggrpc.WithInsecure())
+	assert.Nil(t, err)
+	defer conn.Close()
+	client := grpc2grpc.NewPetStoreServiceClient(conn)
+	call := func(id int32, pairs ...string) error {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), append([]string{"x-api-key", "key-a"}, pairs...)...)
+		_, err := client.PetById(ctx, &grpc2grpc.PetByIdRequest{Id: id})
+		return err
+	}
+
+	assert.Nil(t, call(2, "x-role", "admin", "x-tenant", "t1"))
+	assert.True(t, h.handled())
+	h.reset()
+	assert.Equal(t, codes.PermissionDenied, status.Code(call(2, "x-role", "user", "x-tenant", "t1")))
+	assert.Equal(t, codes.PermissionDenied, status.Code(call(12, "x-role", "admin", "x-tenant", "t1")))
+	assert.Equal(t, codes.PermissionDenied, status.Code(call(2, "x-role", "admin", "x-tenant", "t2")))
+	assert.False(t, h.handled())
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "key-a", "x-tenant", "t1")
+	_, err = grpc2grpc.NewPetStoreServiceClient(conn).UserByName(ctx, &grpc2grpc.UserByNameRequest{Username: "user2"})
+	assert.NotEqual(t, codes.PermissionDenied, status.Code(err))
+
+	instance.Stop()
+	b, err := ioutil.ReadFile(auditFile)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	assert.Equal(t, 5, len(lines))
+	var entries []map[string]interface{}
+	for _, line := range lines {
+		var entry map[string]interface{}
+		assert.Nil(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+	assert.Equal(t, "allow", entries[0]["decision"])
+	assert.Equal(t, "t1", entries[0]["identity"])
+	assert.Equal(t, "deny", entries[1]["decision"])
+	assert.Equal(t, "admins", entries[1]["rule"])
+	assert.Equal(t, "admins", entries[2]["rule"])
+	assert.Equal(t, "tenant", entries[3]["rule"])
+	assert.Equal(t, "allow", entries[4]["decision"])
+	assert.Equal(t, "UserByName", entries[4]["methodName"])
+
+	// the request of a stream is the array of its messages when aggregated and each message in message mode
+	assert.Nil(t, ioutil.WriteFile(policyFile, []byte(`{
+		"rules": [
+			{"name": "first", "methodName": "StoreUsers", "condition": "$.request[0].username == \"user1\""},
+			{"name": "blocked", "methodName": "BulkUsers", "condition": "$.request.username != \"blocked\""}
+		]
+	}`), 0600))
+	addr := startTrigger(t, map[string]interface{}{"policyFile": policyFile}, newTestHandler(map[string]interface{}{
+		"serviceName": "PetStoreService",
+		"methodName":  "StoreUsers",
+		"streamMode":  "aggregate",
+	}, storeUsers), newTestHandler(map[string]interface{}{
+		"serviceName": "PetStoreService",
+		"methodName":  "BulkUsers",
+		"streamMode":  "message",
+	}, echoUsers))
+	streamConn, err := ggrpc.Dial(addr, ggrpc.WithInsecure())
+	assert.Nil(t, err)
+	defer streamConn.Close()
+	harness := grpc2grpc.NewPetStoreServiceHarnessClient(streamConn)
+	_, err = harness.StoreUsers(context.Background(), []string{`{"username":"user1"}`, `{"username":"user2"}`})
+	assert.Nil(t, err)
+	_, err = harness.StoreUsers(context.Background(), []string{`{"username":"user2"}`, `{"username":"user1"}`})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "tenant", "acme")
+	users, err := harness.BulkUsers(ctx, []string{`{"username":"user1"}`, `{"username":"blocked"}`, `{"username":"user3"}`})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Equal(t, []string{`{"id":1,"username":"user1@acme"}`}, users)
+
+	// a request stream received by the handler itself is not available to the rules referring to the request, which
+	// fail to resolve it, while the other rules are evaluated
+	assert.Nil(t, ioutil.WriteFile(policyFile, []byte(`{
+		"rules": [
+			{"name": "literal", "methodName": "StoreUsers", "condition": "$.identity != \"$.request\""},
+			{"name": "blocked", "methodName": "BulkUsers", "condition": "$.request.username != \"blocked\""}
+		]
+	}`), 0600))
+	addr = startTrigger(t, map[string]interface{}{"policyFile": policyFile}, newTestHandler(map[string]interface{}{
+		"serviceName": "PetStoreService",
+		"methodName":  "StoreUsers",
+	}, nil), newTestHandler(map[string]interface{}{
+		"serviceName": "PetStoreService",
+		"methodName":  "BulkUsers",
+	}, nil))
+	rawConn, err := ggrpc.Dial(addr, ggrpc.WithInsecure())
+	assert.Nil(t, err)
+	defer rawConn.Close()
+	raw := grpc2grpc.NewPetStoreServiceHarnessClient(rawConn)
+	_, err = raw.StoreUsers(context.Background(), []string{`{"username":"user1"}`})
+	assert.NotEqual(t, codes.PermissionDenied, status.Code(err))
+	_, err = raw.BulkUsers(context.Background(), []string{`{"username":"user1"}`})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestGRPCTriggerLimits(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	addr := startTrigger(t, map[string]interface{}{
+		"apiKeys": map[string]interface{}{
+			"key-a":  map[string]interface{}{"identity": "a"},
+			"key-a2": map[string]interface{}{"identity": "a"},
+			"key-b":  map[string]interface{}{"identity": "b"},
+			"key-c":  map[string]interface{}{"identity": "c"},
+		},
+		"callerMaxConcurrent": 1,
+	}, newTestHandler(map[string]interface{}{
+		"serviceName": "PetStoreService",
+		"methodName":  "PetById",
+		"rateLimit":   0.5,
+		"rateBurst":   2,
+	}, nil), newTestHandler(map[string]interface{}{
+		"serviceName": "PetStoreService",
+		"methodName":  "ListUsers",
+	}, blockUntil(started, release)))
+
+	conn, err := ggrpc.Dial(addr, ggrpc.WithInsecure())
+	assert.Nil(t, err)
+	defer conn.Close()
+	client := grpc2grpc.NewPetStoreServiceClient(conn)
+	withClient := func(name string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "key-"+name)
+	}
+
+	// the stream of caller a holds its only slot while it is open, whatever key of a is used
+	stream, err := client.ListUsers(withClient("a"), &grpc2grpc.EmptyReq{})
+	assert.Nil(t, err)
+	<-started
+	_, err = client.PetById(withClient("a2"), &grpc2grpc.PetByIdRequest{Id: 2})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	details := status.Convert(err).Details()
+	if assert.Equal(t, 1, len(details)) {
+		retry, ok := details[0].(*errdetails.RetryInfo)
+		assert.True(t, ok)
+		assert.Equal(t, int64(1), retry.RetryDelay.Seconds)
+	}
+	close(release)
+	_, err = stream.Recv()
+	assert.Nil(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, io.EOF, err)
+	// the slot is released once the method returns on the server
+	time.Sleep(100 * time.Millisecond)
+
+	// the rate limit of the method allows bursts of 2 calls then one call every 2 seconds
+	_, err = client.PetById(withClient("a"), &grpc2grpc.PetByIdRequest{Id: 2})
+	assert.NotEqual(t, codes.ResourceExhausted, status.Code(err))
+	_, err = client.PetById(withClient("b"), &grpc2grpc.PetByIdRequest{Id: 2})
+	assert.NotEqual(t, codes.ResourceExhausted, status.Code(err))
+	_, err = client.PetById(withClient("c"), &grpc2grpc.PetByIdRequest{Id: 2})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	details = status.Convert(err).Details()
+	if assert.Equal(t, 1, len(details)) {
+		retry := details[0].(*errdetails.RetryInfo)
+		delay, err := ptypes.Duration(retry.RetryDelay)
+		assert.Nil(t, err)
+		assert.True(t, delay > time.Second && delay <= 2*time.Second, delay.String())
+	}
+
+	// without API keys the callers are identified by the value of their callerKey metadata
+	started, release = make(chan struct{}), make(chan struct{})
+	addr = startTrigger(t, map[string]interface{}{
+		"callerKey":           "X-Tenant",
+		"callerMaxConcurrent": 1,
+	}, newTestHandler(map[string]interface{}{
+		"serviceName": "PetStoreService",
+		"methodName":  "ListUsers",
+	}, blockUntil(started, release)), newTestHandler(map[string]interface{}{
+		"serviceName": "PetStoreService",
+		"methodName":  "PetById",
+	}, nil))
+	tenantConn, err := ggrpc.Dial(addr, ggrpc.WithInsecure())
+	assert.Nil(t, err)
+	defer tenantConn.Close()
+	client = grpc2grpc.NewPetStoreServiceClient(tenantConn)
+	withTenant := func(name string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "x-tenant", name)
+	}
+	stream, err = client.ListUsers(withTenant("a"), &grpc2grpc.EmptyReq{})
+	assert.Nil(t, err)
+	<-started
+	_, err = client.PetById(withTenant("a"), &grpc2grpc.PetByIdRequest{Id: 2})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	_, err = client.PetById(withTenant("b"), &grpc2grpc.PetByIdRequest{Id: 2})
+	assert.NotEqual(t, codes.ResourceExhausted, status.Code(err))
+	close(release)
+	_, err = stream.Recv()
+	assert.Nil(t, err)
+}
+
+func TestGRPCTriggerShedding(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	// the metrics are served on the HTTP port without multiplexing
+	httpPort := freePort(t)
+	addr := startTrigger(t, map[string]interface{}{
+		"httpPort":         httpPort,
+		"adaptiveShedding": true,
+		"sheddingLatency":  20,
+		"sheddingMinLimit": 1,
+		"sheddingMaxLimit": 4,
+	}, newTestHandler(map[string]interface{}{
+		"serviceName": "PetStoreService",
+		"methodName":  "ListUsers",
+		"priority":    "low",
+	}, blockUntil(started, release)), newTestHandler(map[string]interface{}{
+		"serviceName": "PetStoreService",
+		"methodName":  "PetById",
+		"priority":    "critical",
+	}, nil), newTestHandler(map[string]interface{}{
+		"serviceName": "PetStoreService",
+		"methodName":  "UserByName",
+	}, delayed(40*time.Millisecond)))
+
+	conn, err := ggrpc.Dial(addr, ggrpc.WithInsecure())
+	assert.Nil(t, err)
+	defer conn.Close()
+	client := grpc2grpc.NewPetStoreServiceClient(conn)
+
+	// low priority calls may use half of the initial limit of 4 calls
+	var streams []grpc2grpc.PetStoreService_ListUsersClient
+	for i := 0; i < 2; i++ {
+		stream, err := client.ListUsers(context.Background(), &grpc2grpc.EmptyReq{})
+		assert.Nil(t, err)
+		<-started
+		streams = append(streams, stream)
+	}
+	stream, err := client.ListUsers(context.Background(), &grpc2grpc.EmptyReq{})
+	assert.Nil(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	_, err = client.PetById(context.Background(), &grpc2grpc.PetByIdRequest{Id: 2})
+	assert.Nil(t, err)
+	close(release)
+	for _, stream := range streams {
+		_, err = stream.Recv()
+		assert.Nil(t, err)
+	}
+
+	// calls slower than the target latency shrink the limit
+	for i := 0; i < 10; i++ {
+		_, err = client.UserByName(context.Background(), &grpc2grpc.UserByNameRequest{Username: "user2"})
+		assert.Nil(t, err)
+	}
+	response, err := http.Get("http://localhost:" + strconv.Itoa(httpPort) + "/metrics")
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	assert.Nil(t, err)
+	match := regexp.MustCompile(`grpc_server_concurrency_limit ([0-9.]+)`).FindSubmatch(body)
+	if assert.NotNil(t, match) {
+		limit, err := strconv.ParseFloat(string(match[1]), 64)
+		assert.Nil(t, err)
+		assert.True(t, limit < 2, string(match[1]))
+	}
+	assert.Contains(t, string(body), `grpc_server_shed_total{grpc_service="grpc2grpc.PetStoreService",grpc_method="ListUsers"} 1`)
+}
+
+func TestGRPCTriggerServerTuning(t *testing.T) {
+	userSettings := map[string]interface{}{"serviceName": "PetStoreService", "methodName": "UserByName"}
+	for _, invalid := range []map[string]interface{}{
+		{"maxRecvMsgSize": -1},
+		{"initialWindowSize": 1024},
+		{"maxConnectionAgeGrace": 10},
+	} {
+		settings := map[string]interface{}{}
+		for key, value := range invalid {
+			settings[key] = value
+		}
+		_, err := newTrigger(t, settings, newTestHandler(userSettings, delayed(0)))
+		assert.NotNil(t, err, "%v", invalid)
+	}
+
+	addr := startTrigger(t, map[string]interface{}{
+		"maxRecvMsgSize":               8 * 1024 * 1024,
+		"maxSendMsgSize":               8 * 1024 * 1024,
+		"maxConcurrentStreams":         100,
+		"keepaliveTime":                60,
+		"keepaliveTimeout":             10,
+		"keepaliveMinTime":             30,
+		"keepalivePermitWithoutStream": true,
+		"maxConnectionIdle":            300,
+		"maxConnectionAge":             3600,
+		"maxConnectionAgeGrace":        30,
+		"connectionTimeout":            5,
+		"initialWindowSize":            1024 * 1024,
+		"initialConnWindowSize":        4 * 1024 * 1024,
+	}, newTestHandler(userSettings, delayed(0)))
+
+	conn, err := ggrpc.Dial(addr, ggrpc.WithInsecure())
+	assert.Nil(t, err)
+	defer conn.Close()
+	// larger than the 4MB default of gRPC
+	username := strings.Repeat("u", 5*1024*1024)
+	_, err = grpc2grpc.NewPetStoreServiceClient(conn).UserByName(context.Background(), &grpc2grpc.UserByNameRequest{Username: username})
+	assert.Nil(t, err)
+
+	// the multiplexed listeners are served by an HTTP/2 server tuned with the same settings
+	started, release := make(chan struct{}), make(chan struct{})
+	addr = startTrigger(t, map[string]interface{}{
+		"multiplex":            true,
+		"maxRecvMsgSize":       8 * 1024 * 1024,
+		"maxConcurrentStreams": 1,
+		"maxConnectionIdle":    300,
+		"connectionTimeout":    5,
+		"initialWindowSize":    1024 * 1024,
+	}, newTestHandler(userSettings, delayed(0)), newTestHandler(map[string]interface{}{
+		"serviceName": "PetStoreService",
+		"methodName":  "ListUsers",
+	}, blockUntil(started, release)))
+
+	conn, err = ggrpc.Dial(addr, ggrpc.WithInsecure())
+	assert.Nil(t, err)
+	defer conn.Close()
+	client := grpc2grpc.NewPetStoreServiceClient(conn)
+	_, err = client.UserByName(context.Background(), &grpc2grpc.UserByNameRequest{Username: username})
+	assert.Nil(t, err)
+
+	stream, err := client.ListUsers(context.Background(), &grpc2grpc.EmptyReq{})
+	assert.Nil(t, err)
+	<-started
+	// the only stream of the connection is taken
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	_, err = client.UserByName(ctx, &grpc2grpc.UserByNameRequest{Username: "user2"})
+	cancel()
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	close(release)
+	_, err = stream.Recv()
+	assert.Nil(t, err)
+	_, err = client.UserByName(context.Background(), &grpc2grpc.UserByNameRequest{Username: "user2"})
+	assert.Nil(t, err)
+}
+
+func TestGRPCTriggerHealth(t *testing.T) {
+	addr := startTrigger(t, map[string]interface{}{
+		"apiKeys": map[string]interface{}{"key-a": map[string]interface{}{"identity": "a"}},
+	}, newTestHandler(nil, nil))
+
+	conn, err := ggrpc.Dial(addr, ggrpc.WithInsecure())
+	assert.Nil(t, err)
+	defer conn.Close()
+	health := healthpb.NewHealthClient(conn)
+
+	// the health checks need no API key, unlike the calls
+	for _, service := range []string{"", "PetStoreService", "grpc2grpc.PetStoreService"} {
+		res, err := health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		assert.Nil(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.GetStatus())
+	}
+	_, err = health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "grpc2grpc.Unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = grpc2grpc.NewPetStoreServiceClient(conn).PetById(context.Background(), &grpc2grpc.PetByIdRequest{Id: 2})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestGRPCTriggerDrain(t *testing.T) {
+	for _, multiplex := range []bool{false, true} {
+		started, release := make(chan struct{}), make(chan struct{})
+		port := freePort(t)
+		addr := "localhost:" + strconv.Itoa(port)
+		instance, err := newTrigger(t, map[string]interface{}{
+			"port":         port,
+			"multiplex":    multiplex,
+			"drainTimeout": 1,
+		}, newTestHandler(map[string]interface{}{
+			"serviceName": "PetStoreService",
+			"methodName":  "ListUsers",
+		}, blockUntil(started, release)), newTestHandler(map[string]interface{}{
+			"serviceName": "PetStoreService",
+			"methodName":  "UserByName",
+		}, delayed(300*time.Millisecond)))
+		assert.Nil(t, err)
+
+		// the trigger serves again once stopped
+		for i := 0; i < 2; i++ {
+			util.Drain(strconv.Itoa(port))
+			assert.Nil(t, instance.Start())
+			util.Pour(strconv.Itoa(port))
+			assert.NotNil(t, instance.Start())
+
+			if multiplex {
+				response, err := http.Get("http://" + addr + "/health")
+				assert.Nil(t, err)
+				body, err := ioutil.ReadAll(response.Body)
+				response.Body.Close()
+				assert.Nil(t, err)
+				assert.Equal(t, http.StatusOK, response.StatusCode)
+				assert.Equal(t, `{"status":"SERVING"}`, string(body))
+			}
+
+			conn, err := ggrpc.Dial(addr, ggrpc.WithInsecure())
+			assert.Nil(t, err)
+			client := grpc2grpc.NewPetStoreServiceClient(conn)
+			watch, err := healthpb.NewHealthClient(conn).Watch(context.Background(), &healthpb.HealthCheckRequest{Service: "grpc2grpc.PetStoreService"})
+			assert.Nil(t, err)
+			health, err := watch.Recv()
+			assert.Nil(t, err)
+			assert.Equal(t, healthpb.HealthCheckResponse_SERVING, health.GetStatus())
+
+			// a stream that never ends and a unary call are in flight when the trigger stops
+			stream, err := client.ListUsers(context.Background(), &grpc2grpc.EmptyReq{})
+			assert.Nil(t, err)
+			<-started
+			unary := make(chan error, 1)
+			go func() {
+				_, err := client.UserByName(context.Background(), &grpc2grpc.UserByNameRequest{Username: "user2"})
+				unary <- err
+			}()
+			time.Sleep(100 * time.Millisecond)
+
+			start := time.Now()
+			assert.Nil(t, instance.Stop())
+			elapsed := time.Since(start)
+			// the unary call ends while draining, the stream is cut when the drain timeout expires
+			assert.Nil(t, <-unary)
+			assert.True(t, elapsed >= time.Second && elapsed < 3*time.Second, elapsed.String())
+			// the health watchers are told and their streams end with the drain
+			health, err = watch.Recv()
+			assert.Nil(t, err)
+			assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, health.GetStatus())
+			_, err = watch.Recv()
+			assert.Equal(t, io.EOF, err)
+			_, err = stream.Recv()
+			assert.NotNil(t, err)
+
+			_, err = client.UserByName(context.Background(), &grpc2grpc.UserByNameRequest{Username: "user2"})
+			assert.Equal(t, codes.Unavailable, status.Code(err))
+			conn.Close()
+		}
+		close(release)
+	}
+}
+
+func TestGRPCTriggerCertificateReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certificates")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	rotate := func(certPEM, keyPEM []byte, modTime time.Time) {
+		assert.Nil(t, ioutil.WriteFile(certFile, certPEM, 0600))
+		assert.Nil(t, ioutil.WriteFile(keyFile, keyPEM, 0600))
+		assert.Nil(t, os.Chtimes(certFile, modTime, modTime))
+		assert.Nil(t, os.Chtimes(keyFile, modTime, modTime))
+	}
+	firstCert, firstKey := selfSignedCert(t)
+	rotate(firstCert, firstKey, time.Now().Add(-time.Minute))
+
+	defer func(unit time.Duration) { grpc.ReloadUnit = unit }(grpc.ReloadUnit)
+	grpc.ReloadUnit = 200 * time.Millisecond
+	addr := startTrigger(t, map[string]interface{}{
+		"enableTLS":  true,
+		"serverCert": certFile,
+		"serverKey":  "file://" + keyFile,
+		"certReload": 1,
+	}, newTestHandler(nil, nil))
+
+	// served returns the certificate of a new handshake
+	served := func() []byte {
+		conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2"}})
+		if !assert.Nil(t, err) {
+			return nil
+		}
+		defer conn.Close()
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: conn.ConnectionState().PeerCertificates[0].Raw})
+	}
+	call := func(conn *ggrpc.ClientConn) error {
+		_, err := grpc2grpc.NewPetStoreServiceClient(conn).PetById(context.Background(), &grpc2grpc.PetByIdRequest{Id: 2})
+		return err
+	}
+	assert.Equal(t, firstCert, served())
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(firstCert)
+	conn, err := ggrpc.Dial(addr, ggrpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: pool})))
+	assert.Nil(t, err)
+	defer conn.Close()
+	assert.Nil(t, call(conn))
+
+	// new handshakes get the rotated certificate once the files are checked again, the connections established keep
+	// the previous one
+	secondCert, secondKey := selfSignedCert(t)
+	rotate(secondCert, secondKey, time.Now())
+	assert.Equal(t, firstCert, served())
+	time.Sleep(250 * time.Millisecond)
+	assert.Equal(t, secondCert, served())
+	assert.Nil(t, call(conn))
+
+	// an invalid rotation keeps the certificate
+	rotate(firstCert, secondKey, time.Now().Add(time.Minute))
+	time.Sleep(250 * time.Millisecond)
+	assert.Equal(t, secondCert, served())
+}